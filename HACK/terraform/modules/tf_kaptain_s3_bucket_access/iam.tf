@@ -4,7 +4,14 @@ resource "aws_iam_role" "kaptain_role" {
 }
 
 resource "aws_iam_role" "sailor_role" {
-  name               = "${var.prefix}_${var.cluster_name}_sailor_role"
+  count              = "${length(var.roles)}"
+  name               = "${var.prefix}_${var.cluster_name}_sailor_${element(var.roles, count.index)}_role"
+  assume_role_policy = "${data.aws_iam_policy_document.assume_role_policy.json}"
+}
+
+resource "aws_iam_role" "sailor_node_role" {
+  count              = "${length(var.nodes)}"
+  name               = "${var.prefix}_${var.cluster_name}_sailor_${replace(element(var.nodes, count.index), "/", "_")}_role"
   assume_role_policy = "${data.aws_iam_policy_document.assume_role_policy.json}"
 }
 
@@ -15,9 +22,17 @@ resource "aws_iam_role_policy" "kaptain_policy" {
 }
 
 resource "aws_iam_role_policy" "sailor_policy" {
+  count  = "${length(var.roles)}"
+  name   = "KaptainPolicyReadOnly"
+  role   = "${element(aws_iam_role.sailor_role.*.id, count.index)}"
+  policy = "${element(data.aws_iam_policy_document.sailor_policy.*.json, count.index)}"
+}
+
+resource "aws_iam_role_policy" "sailor_node_policy" {
+  count  = "${length(var.nodes)}"
   name   = "KaptainPolicyReadOnly"
-  role   = "${aws_iam_role.sailor_role.id}"
-  policy = "${data.aws_iam_policy_document.sailor_policy.json}"
+  role   = "${element(aws_iam_role.sailor_node_role.*.id, count.index)}"
+  policy = "${element(data.aws_iam_policy_document.sailor_node_policy.*.json, count.index)}"
 }
 
 data "aws_iam_policy_document" "assume_role_policy" {
@@ -61,17 +76,73 @@ data "aws_iam_policy_document" "kaptain_policy" {
   }
 }
 
+# sailor only reads the files shared by its role, cluster.yaml is only readable by kaptain
 data "aws_iam_policy_document" "sailor_policy" {
+  count = "${length(var.roles)}"
+
   statement {
-    sid = "KaptainClusterReadOnly"
+    sid = "KaptainRoleReadOnly"
 
     actions = [
       "s3:GetObject",
     ]
 
     resources = [
-      "arn:aws:s3:::${var.state_bucket}/${var.cluster_name}",
-      "arn:aws:s3:::${var.state_bucket}/${var.cluster_name}/*",
+      "arn:aws:s3:::${var.state_bucket}/${var.cluster_name}/roles/${element(var.roles, count.index)}/files.yaml",
+    ]
+  }
+
+  statement {
+    sid = "KaptainRoleList"
+
+    actions = [
+      "s3:ListBucket",
+    ]
+
+    resources = [
+      "arn:aws:s3:::${var.state_bucket}",
     ]
+
+    condition {
+      test     = "StringLike"
+      variable = "s3:prefix"
+      values   = ["${var.cluster_name}/roles/${element(var.roles, count.index)}/*"]
+    }
+  }
+}
+
+# sailor on a node also reads the files of the node, but not those of the other nodes
+data "aws_iam_policy_document" "sailor_node_policy" {
+  count = "${length(var.nodes)}"
+
+  statement {
+    sid = "KaptainRoleReadOnly"
+
+    actions = [
+      "s3:GetObject",
+    ]
+
+    resources = [
+      "arn:aws:s3:::${var.state_bucket}/${var.cluster_name}/roles/${element(split("/", element(var.nodes, count.index)), 0)}/files.yaml",
+      "arn:aws:s3:::${var.state_bucket}/${var.cluster_name}/roles/${element(split("/", element(var.nodes, count.index)), 0)}/nodes/${element(split("/", element(var.nodes, count.index)), 1)}.yaml",
+    ]
+  }
+
+  statement {
+    sid = "KaptainRoleList"
+
+    actions = [
+      "s3:ListBucket",
+    ]
+
+    resources = [
+      "arn:aws:s3:::${var.state_bucket}",
+    ]
+
+    condition {
+      test     = "StringLike"
+      variable = "s3:prefix"
+      values   = ["${var.cluster_name}/roles/${element(split("/", element(var.nodes, count.index)), 0)}/*"]
+    }
   }
 }
@@ -2,6 +2,12 @@ output "kaptain_role_arn" {
   value = "${aws_iam_role.kaptain_role.arn}"
 }
 
-output "sailor_role_arn" {
-  value = "${aws_iam_role.sailor_role.arn}"
+# sailor role ARNs, in the order of var.roles
+output "sailor_role_arns" {
+  value = ["${aws_iam_role.sailor_role.*.arn}"]
+}
+
+# sailor node role ARNs, in the order of var.nodes
+output "sailor_node_role_arns" {
+  value = ["${aws_iam_role.sailor_node_role.*.arn}"]
 }
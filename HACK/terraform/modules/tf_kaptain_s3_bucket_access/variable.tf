@@ -5,3 +5,16 @@ variable "prefix" {}
 variable "cluster_name" {}
 
 variable "cluster_aws_account" {}
+
+# roles whose nodes share a sailor role, which can only read the files shared by the role
+variable "roles" {
+  type    = "list"
+  default = ["etcd", "master", "worker"]
+}
+
+# nodes with their own sailor role, as "<role>/<hostname>", which can also read the files
+# of the node (etcd members and masters)
+variable "nodes" {
+  type    = "list"
+  default = []
+}
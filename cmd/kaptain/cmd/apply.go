@@ -0,0 +1,86 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/ghodss/yaml"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/kaptain"
+)
+
+var applyOpts kaptain.ApplyOptions
+
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Apply a cluster spec to an existing cluster",
+	Long: `Apply a cluster spec from a file to an existing cluster.
+For example, to update cluster 'dev.example.com' with the spec in 'cluster.yaml':
+
+$ kaptain export -n dev.example.com > cluster.yaml
+$ vi cluster.yaml
+$ kaptain apply -f cluster.yaml
+
+The spec in the file replaces the stored spec and all config files are rendered
+again. Existing PKIs and tokens are kept, missing ones are generated. Changes that
+would invalidate existing certs (e.g. changing the apiserver name) are refused,
+unless --reissue-certs is set to reissue the affected certs.
+
+To change a few settings without editing a file, see 'kaptain update -h'.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flagset := cmd.Flags()
+
+		inFile, err := flagset.GetString("file")
+		if err != nil {
+			panic(err)
+		}
+
+		// read the file
+		data, err := ioutil.ReadFile(inFile)
+		if err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+
+		// parse the cluster
+		cluster := api.Cluster{}
+		if err = yaml.Unmarshal(data, &cluster); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+
+		client := kaptain.KaptainClient{
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
+		}
+
+		if err := client.Apply(&cluster, &applyOpts); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(applyCmd)
+
+	applyCmd.Flags().StringP("file", "f", "", "Cluster spec file to be applied")
+	applyCmd.Flags().BoolVar(&applyOpts.ReissueCerts, "reissue-certs", false, "Reissue certs invalidated by the change")
+	applyCmd.MarkFlagRequired("file")
+}
@@ -0,0 +1,96 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/kaptain"
+)
+
+// auditTokensCmd represents the audit-tokens command
+var auditTokensCmd = &cobra.Command{
+	Use:   "audit-tokens",
+	Short: "Find clusters with predictable tokens and regenerate them",
+	Long: `Find clusters whose tokens were generated by a kaptain version that used a
+predictable random generator. Without --name, all clusters are audited.
+
+$ kaptain audit-tokens
+$ kaptain audit-tokens -n dev.example.com
+
+The command exits with code 1 if any cluster has weak tokens, 0 if there are none
+and 2 on errors.
+
+To replace all tokens of a cluster, use --regenerate. The token.csv of masters and
+the bootstrap.kubeconfig of workers are re-rendered and saved as a new revision.
+Run 'sailor provision' on all masters and workers afterwards, and export the
+kubeconfigs of users again (see 'kaptain export-config -h').
+
+$ kaptain audit-tokens -n dev.example.com --regenerate
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flagset := cmd.Flags()
+
+		clusterName, err := flagset.GetString("name")
+		if err != nil {
+			panic(err)
+		}
+
+		regenerate, err := flagset.GetBool("regenerate")
+		if err != nil {
+			panic(err)
+		}
+
+		client := kaptain.KaptainClient{
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
+		}
+
+		if regenerate {
+			if clusterName == "" {
+				log.Error("--regenerate requires --name")
+				os.Exit(2)
+			}
+			if err := client.RegenerateTokens(clusterName); err != nil {
+				log.Error(err)
+				os.Exit(2)
+			}
+			return
+		}
+
+		clusterNames := []string{}
+		if clusterName != "" {
+			clusterNames = append(clusterNames, clusterName)
+		}
+
+		weak, err := client.AuditTokens(clusterNames, os.Stdout)
+		if err != nil {
+			log.Error(err)
+			os.Exit(2)
+		}
+
+		if weak {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(auditTokensCmd)
+
+	auditTokensCmd.Flags().StringP("name", "n", "", "Cluster Name (default all clusters)")
+	auditTokensCmd.Flags().Bool("regenerate", false, "Regenerate all tokens of the cluster")
+}
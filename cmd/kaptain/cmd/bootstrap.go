@@ -45,7 +45,7 @@ $ kaptain bootstrap -n dev.example.com
 		}
 
 		client := kaptain.KaptainClient{
-			Registry: api.NewClusterRegistry(storeUrl),
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
 		}
 
 		if err := client.Bootstrap(clusterName); err != nil {
@@ -0,0 +1,80 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/kaptain"
+)
+
+var certsOpts kaptain.CertsOptions
+
+// certsCmd represents the certs command
+var certsCmd = &cobra.Command{
+	Use:   "certs",
+	Short: "Show the certs of a cluster and when they expire",
+	Long: `Show subject, SANs, issuer, serial, validity, key and profile of every cert
+of a cluster. Each cert is checked to be signed by a CA of the cluster and to
+match its key, and its expiry is checked against --warn-days and --fail-days.
+
+$ kaptain certs -n dev.example.com
+$ kaptain certs -n dev.example.com --warn-days 60 -o json
+
+The command exits with code 1 if a cert expires within --warn-days, 2 if a cert
+expires within --fail-days or fails a check, and 3 on errors. To check all
+clusters in a nightly job:
+
+$ for c in $(kaptain list | tail -n +3); do kaptain certs -n $c -o json; done
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flagset := cmd.Flags()
+
+		clusterName, err := flagset.GetString("name")
+		if err != nil {
+			panic(err)
+		}
+
+		client := kaptain.KaptainClient{
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
+		}
+
+		status, err := client.Certs(clusterName, &certsOpts, os.Stdout)
+		if err != nil {
+			log.Error(err)
+			os.Exit(3)
+		}
+
+		switch status {
+		case kaptain.CertStatusWarn:
+			os.Exit(1)
+		case kaptain.CertStatusFail:
+			os.Exit(2)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(certsCmd)
+
+	certsCmd.Flags().StringP("name", "n", "", "Cluster Name")
+	certsCmd.Flags().IntVar(&certsOpts.WarnDays, "warn-days", 30, "Warn about certs expiring within this number of days")
+	certsCmd.Flags().IntVar(&certsOpts.FailDays, "fail-days", 7, "Fail on certs expiring within this number of days")
+	certsCmd.Flags().StringVarP(&certsOpts.Output, "output", "o", "table", "Output format (table, json)")
+
+	certsCmd.MarkFlagRequired("name")
+}
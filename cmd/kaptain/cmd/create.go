@@ -19,6 +19,7 @@ import (
 	"io/ioutil"
 	"os"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -28,7 +29,12 @@ import (
 
 var newCluster api.Cluster
 var etcdServers string
+var masters string
 var authenticationTokenWebhookConfigFile string
+var kubeCACertFile, kubeCAKeyFile string
+var etcdCACertFile, etcdCAKeyFile string
+var caChainFile string
+var signers string
 
 // createCmd represents the create command
 var createCmd = &cobra.Command{
@@ -93,15 +99,40 @@ to be used later.`,
 			newCluster.Spec.AuthenticationTokenWebhookOpts.ConfigDataBase64 = webhookConfigData
 		}
 
+		// Imported CAs
+		if (kubeCACertFile == "") != (kubeCAKeyFile == "") {
+			return fmt.Errorf("--kube-ca-cert and --kube-ca-key must be set together")
+		}
+		if (etcdCACertFile == "") != (etcdCAKeyFile == "") {
+			return fmt.Errorf("--etcd-ca-cert and --etcd-ca-key must be set together")
+		}
+		if caChainFile != "" && kubeCACertFile == "" && etcdCACertFile == "" {
+			return fmt.Errorf("--ca-chain requires --kube-ca-cert or --etcd-ca-cert")
+		}
+
 		return nil
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		// create cluster
 		client := kaptain.KaptainClient{
-			Registry: api.NewClusterRegistry(storeUrl),
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
 		}
 
 		newCluster.Spec.EtcdCluster = newEtcdCluster(etcdServers)
+		newCluster.Spec.Masters = newMasterNodes(masters)
+
+		if err := kaptain.SetSigners(&newCluster, makeArrayFromCommaSeparatedString(signers)); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+		if err := importCA(&newCluster, "kube-ca", kubeCACertFile, kubeCAKeyFile, caChainFile); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+		if err := importCA(&newCluster, "etcd-ca", etcdCACertFile, etcdCAKeyFile, caChainFile); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
 
 		inflateOptions := kaptain.InflateClusterOptions{
 			UpdateSpec:          true,
@@ -109,7 +140,10 @@ to be used later.`,
 			UpdateTokens:        true,
 			UpdateAssetManifest: true,
 		}
-		kaptain.InflateCluster(&newCluster, &inflateOptions)
+		if err := kaptain.InflateCluster(&newCluster, &inflateOptions); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
 
 		if err := client.Create(&newCluster, false); err != nil {
 			log.Fatal(err)
@@ -134,7 +168,8 @@ func init() {
 	createCmd.Flags().StringVar(&newCluster.Spec.KubeVersion, "kube-version", kaptain.DefaultKubeVersion, "Specify Kubernetes Version")
 	createCmd.Flags().StringVar(&newCluster.Spec.DNSDomain, "dns-domain", "", "DNS Domain (default <cluster_name>)")
 	createCmd.Flags().StringVar(&newCluster.Spec.CloudProvider, "cloud-provider", kaptain.DefaultCloudProvider, "Cloud Provider (aws or vsphere)")
-	createCmd.Flags().StringVar(&etcdServers, "etcd-servers", "etcd-k8s-0,etcd-k8s-1,etcd-k8s-2", "Comma-separated ETCD server hostnames")
+	createCmd.Flags().StringVar(&etcdServers, "etcd-servers", "etcd-k8s-0,etcd-k8s-1,etcd-k8s-2", "Comma-separated ETCD server hostnames, each optionally with its IP as hostname=ip")
+	createCmd.Flags().StringVar(&masters, "masters", "", "Comma-separated master hostnames, each optionally with its IP as hostname=ip, every master gets its own apiserver serving cert")
 	createCmd.Flags().StringVar(&newCluster.Spec.DockerOpts.KubeImageProxy, "docker-kube-image-proxy", kaptain.DefaultKubeImageProxy, "Set this flag to use a proxy to download gcr.io images (e.g. gcr.io/google_containers/kube-apiserver)")
 	createCmd.Flags().StringArrayVar(&newCluster.Spec.DockerOpts.InsecureRegistries, "docker-insecure-registry", []string{}, "Insecure Docker registries to allow")
 	createCmd.Flags().StringArrayVar(&newCluster.Spec.DockerOpts.RegistryMirrors, "docker-registry-mirror", []string{}, "Docker registry mirror to add")
@@ -149,6 +184,43 @@ func init() {
 	createCmd.Flags().StringVar(&authenticationTokenWebhookConfigFile, "authentication-token-webhook-config-file", "", "Kubernetes Authentication Webhook Config File, see https://kubernetes.io/docs/admin/authentication/#webhook-token-authentication")
 	createCmd.Flags().StringVar(&newCluster.Spec.AuthenticationTokenWebhookOpts.CacheTTL, "authentication-token-webhook-cache-ttl", "2m0s", "Kubernetes Authentication Webhook Cache TTL")
 	createCmd.Flags().BoolVar(&newCluster.Spec.PodSecurityPolicyOpts.Enabled, "enable-pod-security-policy", false, "Enable PodSecurityPolicy, see 'cluster/pod-security-policy' for set up details")
+	createCmd.Flags().StringVar(&newCluster.Spec.PKIOpts.KeyAlgorithm, "key-algorithm", kaptain.DefaultKeyAlgorithm, "Key type of certs (rsa-2048, rsa-4096, ecdsa-p256, ecdsa-p384)")
+	createCmd.Flags().StringVar(&newCluster.Spec.PKIOpts.CAKeyAlgorithm, "ca-key-algorithm", kaptain.DefaultCAKeyAlgorithm, "Key type of CAs (rsa-2048, rsa-4096, ecdsa-p256, ecdsa-p384)")
+	createCmd.Flags().StringVar(&newCluster.Spec.PKIOpts.CertValidity, "cert-validity", kaptain.DefaultCertValidity, "Validity of certs, e.g. 8760h")
+	createCmd.Flags().StringVar(&newCluster.Spec.PKIOpts.CAValidity, "ca-validity", kaptain.DefaultCAValidity, "Validity of CAs, e.g. 43800h")
+	createCmd.Flags().BoolVar(&newCluster.Spec.PKIOpts.NodeCertsOnly, "node-certs-only", false, "Only provision the etcd and apiserver serving certs issued to each node (requires 'sailor provision --hostname')")
+	createCmd.Flags().StringVar(&kubeCACertFile, "kube-ca-cert", "", "PEM cert of an existing CA to use as the Kubernetes CA")
+	createCmd.Flags().StringVar(&kubeCAKeyFile, "kube-ca-key", "", "PEM key of the CA of --kube-ca-cert")
+	createCmd.Flags().StringVar(&etcdCACertFile, "etcd-ca-cert", "", "PEM cert of an existing CA to use as the ETCD CA")
+	createCmd.Flags().StringVar(&etcdCAKeyFile, "etcd-ca-key", "", "PEM key of the CA of --etcd-ca-cert")
+	createCmd.Flags().StringVar(&caChainFile, "ca-chain", "", "PEM certs that issued the imported CAs, up to the root")
+	createCmd.Flags().StringVar(&signers, "signer", "", "Comma separated signers of the CAs, as ca-name=url (local://, or vault-pki://<mount>/<role> which reads VAULT_ADDR and VAULT_TOKEN, one CA per signer, not kube-ca)")
+	createCmd.Flags().StringVar(&newCluster.Spec.KubeletBootstrapOpts.Mode, "kubelet-bootstrap-mode", kaptain.DefaultKubeletBootstrapMode, "How workers join the cluster (static-token or bootstrap-token)")
+	createCmd.Flags().BoolVar(&newCluster.Spec.KubeletBootstrapOpts.ServerTLSBootstrap, "kubelet-server-tls-bootstrap", false, "Serve kubelets with certs signed by the cluster CA and verify them from the apiserver (Kubernetes 1.10+)")
+}
+
+// importCA imports the CA of the cert and key files into the cluster, nothing is imported
+// if no cert file is given
+func importCA(cluster *api.Cluster, caName string, certFile string, keyFile string, chainFile string) error {
+	if certFile == "" {
+		return nil
+	}
+
+	var err error
+	ca := kaptain.CAImport{}
+	if ca.CertPEM, err = ioutil.ReadFile(certFile); err != nil {
+		return err
+	}
+	if ca.KeyPEM, err = ioutil.ReadFile(keyFile); err != nil {
+		return err
+	}
+	if chainFile != "" {
+		if ca.ChainPEM, err = ioutil.ReadFile(chainFile); err != nil {
+			return err
+		}
+	}
+
+	return kaptain.ImportCA(cluster, caName, &ca, time.Now())
 }
 
 func newEtcdCluster(etcdServers string) api.EtcdCluster {
@@ -158,14 +230,37 @@ func newEtcdCluster(etcdServers string) api.EtcdCluster {
 	etcdCluster.Members = make([]api.EtcdMember, len(servers))
 
 	for i, s := range servers {
+		hostname, ip := parseNode(s)
 		etcdCluster.Members[i] = api.EtcdMember{
-			Hostname: s,
+			Hostname: hostname,
+			IP:       ip,
 		}
 	}
 
 	return etcdCluster
 }
 
+func newMasterNodes(masters string) []api.MasterNode {
+	nodes := []api.MasterNode{}
+	for _, s := range makeArrayFromCommaSeparatedString(masters) {
+		hostname, ip := parseNode(s)
+		nodes = append(nodes, api.MasterNode{
+			Hostname: hostname,
+			IP:       ip,
+		})
+	}
+	return nodes
+}
+
+// parseNode splits a node of the form hostname[=ip]
+func parseNode(node string) (string, string) {
+	parts := strings.SplitN(node, "=", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
 func makeArrayFromCommaSeparatedString(commaSeparatedString string) []string {
 	if commaSeparatedString == "" {
 		return make([]string, 0)
@@ -41,7 +41,7 @@ $ kaptain delete -n dev.example.com
 		}
 
 		client := kaptain.KaptainClient{
-			Registry: api.NewClusterRegistry(storeUrl),
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
 		}
 
 		if _, err := client.Get(clusterName); err != nil {
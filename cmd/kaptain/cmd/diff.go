@@ -0,0 +1,137 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/ghodss/yaml"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/kaptain"
+)
+
+var diffOpts kaptain.ApplyOptions
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show changes between a cluster and a cluster spec or between revisions",
+	Long: `Show what would change by applying a cluster spec file to a cluster.
+Both the cluster spec and the config files rendered for every role are compared.
+PKIs, tokens and files containing them are only marked as changed or unchanged.
+Nothing is issued, certs and keys that applying would create are marked as
+would be issued.
+
+$ kaptain diff -n dev.example.com -f cluster.yaml
+
+To compare two revisions of a cluster (see 'kaptain history -h'), use --from and
+--to. If --to is omitted, the current revision is used. The files saved in each
+revision are compared, which are the files a rollback restores.
+
+$ kaptain diff -n dev.example.com --from 3 --to 4
+
+The command exits with code 1 if there are differences, 0 if there are none and
+2 on errors, so it can be used to gate changes in CI.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flagset := cmd.Flags()
+
+		clusterName, err := flagset.GetString("name")
+		if err != nil {
+			panic(err)
+		}
+
+		inFile, err := flagset.GetString("file")
+		if err != nil {
+			panic(err)
+		}
+
+		from, err := flagset.GetInt("from")
+		if err != nil {
+			panic(err)
+		}
+
+		to, err := flagset.GetInt("to")
+		if err != nil {
+			panic(err)
+		}
+
+		client := kaptain.KaptainClient{
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
+		}
+
+		var changed bool
+		if inFile != "" {
+			// read the file
+			data, err := ioutil.ReadFile(inFile)
+			if err != nil {
+				log.Error(err)
+				os.Exit(2)
+			}
+
+			// parse the cluster
+			cluster := api.Cluster{}
+			if err = yaml.Unmarshal(data, &cluster); err != nil {
+				log.Error(err)
+				os.Exit(2)
+			}
+			if cluster.Name != clusterName {
+				log.Errorf("Cluster name '%s' in '%s' does not match '%s'", cluster.Name, inFile, clusterName)
+				os.Exit(2)
+			}
+
+			changed, err = client.Diff(&cluster, &diffOpts, os.Stdout)
+			if err != nil {
+				log.Error(err)
+				os.Exit(2)
+			}
+		} else {
+			if from == 0 {
+				log.Errorf("Either --file or --from must be set")
+				os.Exit(2)
+			}
+			if to == 0 {
+				if to, err = client.Registry.CurrentRevision(clusterName); err != nil {
+					log.Error(err)
+					os.Exit(2)
+				}
+			}
+
+			changed, err = client.DiffRevisions(clusterName, from, to, os.Stdout)
+			if err != nil {
+				log.Error(err)
+				os.Exit(2)
+			}
+		}
+
+		if changed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().StringP("name", "n", "", "Cluster Name")
+	diffCmd.Flags().StringP("file", "f", "", "Cluster spec file to compare with")
+	diffCmd.Flags().Int("from", 0, "Revision to compare from")
+	diffCmd.Flags().Int("to", 0, "Revision to compare to (default current revision)")
+	diffCmd.Flags().BoolVar(&diffOpts.ReissueCerts, "reissue-certs", false, "Show certs invalidated by the change as reissued")
+
+	diffCmd.MarkFlagRequired("name")
+}
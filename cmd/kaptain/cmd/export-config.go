@@ -17,6 +17,7 @@ import (
 	"os"
 	"os/user"
 	"path"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -37,6 +38,13 @@ $ kaptain export-config --name=dev.example.com --user=admin
 By default, the config is exported to '~/.kube/config'. If the target file
 already exists, Kaptain will merge in the new config and set the context.
 Otherwise, it will create a new file.
+
+With --auth=cert, a new client cert is signed by the cluster CA instead of
+using a token. The cert has the user as common name and the groups as
+organisations, and expires after --valid-for. Its serial and expiry are saved
+in the cluster as a new revision (see 'kaptain user list --auth=cert').
+
+$ kaptain export-config --name=dev.example.com --auth=cert --user=alice --group=dev-team --valid-for=72h
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		flagset := cmd.Flags()
@@ -61,8 +69,36 @@ Otherwise, it will create a new file.
 			panic(err)
 		}
 
+		auth, err := flagset.GetString("auth")
+		if err != nil {
+			panic(err)
+		}
+
+		groups, err := flagset.GetStringSlice("group")
+		if err != nil {
+			panic(err)
+		}
+
+		validFor, err := flagset.GetDuration("valid-for")
+		if err != nil {
+			panic(err)
+		}
+
 		client := kaptain.KaptainClient{
-			Registry: api.NewClusterRegistry(storeUrl),
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
+		}
+
+		switch auth {
+		case "token":
+		case "cert":
+			if err := client.ExportClientCertConfig(clusterName, kubeconfig, username, groups, validFor, force); err != nil {
+				log.Fatal(err)
+				os.Exit(1)
+			}
+			return
+		default:
+			log.Fatalf("invalid auth '%s' (must be one of token, cert)", auth)
+			os.Exit(1)
 		}
 
 		if err := client.ExportConfig(clusterName, kubeconfig, username, force); err != nil {
@@ -95,6 +131,9 @@ func init() {
 	exportConfigCmd.Flags().StringP("user", "u", "admin", "Username of the credential to be exported")
 	exportConfigCmd.Flags().StringP("kubeconfig", "k", defaultKubeConfigFile, "specify path to the output kubeconfig")
 	exportConfigCmd.Flags().BoolP("force", "f", false, "overwrite existing kubeconfig")
+	exportConfigCmd.Flags().String("auth", "token", "Authentication of the user (token or cert)")
+	exportConfigCmd.Flags().StringSlice("group", []string{}, "Group of the user, with --auth=cert (can be repeated)")
+	exportConfigCmd.Flags().Duration("valid-for", 24*time.Hour, "Validity of the client cert, with --auth=cert")
 
 	exportConfigCmd.MarkFlagRequired("name")
 }
@@ -0,0 +1,95 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/javefang/kaptain/pkg/kaptain"
+)
+
+// exportPolicyCmd represents the export-policy command
+var exportPolicyCmd = &cobra.Command{
+	Use:   "export-policy",
+	Short: "Export the store access policy of a role",
+	Long: `Export the policy that grants a role access to a cluster in the store.
+For S3 an IAM policy is printed, for Vault an ACL policy.
+
+The cluster spec and secrets (cluster.yaml) are only readable by the admin
+role, which is kaptain itself. The files of each node role are stored under
+their own prefix (<cluster>/roles/<role>/), so sailor on a node only needs
+read access to the files of its role. The files of a single node, such as the
+keys of etcd members and masters, are only readable by the policy of the node:
+
+$ kaptain export-policy -n dev.example.com --store s3 --role worker
+$ kaptain export-policy -n dev.example.com --store s3 --role etcd --hostname etcd-0
+$ kaptain export-policy -n dev.example.com --store s3 --role admin
+
+If data is encrypted with AWS KMS (KAPTAIN_ENCRYPTION_KEY), IAM policies also
+allow the role to decrypt with the key.
+
+--store is either the scheme of the configured store or a store url.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flagset := cmd.Flags()
+
+		clusterName, err := flagset.GetString("name")
+		if err != nil {
+			panic(err)
+		}
+
+		store, err := flagset.GetString("store")
+		if err != nil {
+			panic(err)
+		}
+
+		role, err := flagset.GetString("role")
+		if err != nil {
+			panic(err)
+		}
+
+		hostname, err := flagset.GetString("hostname")
+		if err != nil {
+			panic(err)
+		}
+
+		policyStoreUrl := storeUrl
+		if strings.Contains(store, "://") {
+			policyStoreUrl = store
+		} else if store != "" && !strings.HasPrefix(strings.ToLower(storeUrl), strings.ToLower(store)+"://") {
+			log.Fatalf("store '%s' is not the configured store '%s', set KAPTAIN_STORE or pass a store url", store, storeUrl)
+			os.Exit(1)
+		}
+
+		if err := kaptain.WritePolicy(policyStoreUrl, encryptionKeyUrl, clusterName, role, hostname, os.Stdout); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(exportPolicyCmd)
+
+	exportPolicyCmd.Flags().StringP("name", "n", "", "Name of the cluster")
+	exportPolicyCmd.Flags().String("store", "", "Store of the policy, scheme of the configured store (s3 or vault) or a store url")
+	exportPolicyCmd.Flags().String("role", "", "Role of the policy (admin, etcd, master, worker or bootstrapper)")
+	exportPolicyCmd.Flags().String("hostname", "", "Hostname of the node of the policy, to read the files of the node (etcd and master)")
+
+	exportPolicyCmd.MarkFlagRequired("name")
+	exportPolicyCmd.MarkFlagRequired("role")
+}
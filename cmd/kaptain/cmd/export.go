@@ -50,7 +50,7 @@ var exportCmd = &cobra.Command{
 		}
 
 		client := kaptain.KaptainClient{
-			Registry: api.NewClusterRegistry(storeUrl),
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
 		}
 
 		cluster, err := client.Get(clusterName)
@@ -0,0 +1,62 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/kaptain"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List all revisions of a cluster",
+	Long: `List all revisions of a cluster.
+Each change to a cluster is saved as an immutable revision, together with the
+time, the Kaptain version and the user that made the change.
+
+$ kaptain history -n dev.example.com
+
+To restore a previous revision, see 'kaptain rollback -h'.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flagset := cmd.Flags()
+
+		clusterName, err := flagset.GetString("name")
+		if err != nil {
+			panic(err)
+		}
+
+		client := kaptain.KaptainClient{
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
+		}
+
+		if err := client.History(clusterName); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(historyCmd)
+
+	historyCmd.Flags().StringP("name", "n", "", "Cluster Name")
+
+	historyCmd.MarkFlagRequired("name")
+}
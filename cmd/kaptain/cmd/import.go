@@ -62,11 +62,14 @@ For how to generate the 'cluster.yaml' file, see 'kaptain export -h'.
 			log.Fatal(err)
 			os.Exit(1)
 		}
-		kaptain.InflateCluster(&cluster, &importInflateClusterOpts)
+		if err := kaptain.InflateCluster(&cluster, &importInflateClusterOpts); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
 
 		// create the cluster if it doesn't exist on the registry yet
 		client := kaptain.KaptainClient{
-			Registry: api.NewClusterRegistry(storeUrl),
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
 		}
 
 		if _, err := client.Get(cluster.Name); err == nil {
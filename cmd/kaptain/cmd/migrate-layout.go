@@ -0,0 +1,66 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/kaptain"
+)
+
+// migrateLayoutCmd represents the migrate-layout command
+var migrateLayoutCmd = &cobra.Command{
+	Use:   "migrate-layout",
+	Short: "Delete the role files of a cluster saved before role prefixes",
+	Long: `Delete the role files of a cluster saved before role prefixes.
+Clusters created by older versions keep their role files at
+<cluster>/roles/<role>.yaml. Saving such a cluster writes the files under the
+role prefixes and keeps the old files up to date, so sailors that have not been
+upgraded yet still provision their nodes. Once every sailor of the cluster has
+been upgraded, delete the old files:
+
+$ kaptain migrate-layout -n dev.example.com
+
+Sailors that have not been upgraded read the old files, so they need access to
+the whole cluster instead of the policy of their role (see 'kaptain export-policy').
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flagset := cmd.Flags()
+
+		clusterName, err := flagset.GetString("name")
+		if err != nil {
+			panic(err)
+		}
+
+		client := kaptain.KaptainClient{
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
+		}
+
+		if err := client.MigrateLayout(clusterName); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(migrateLayoutCmd)
+
+	migrateLayoutCmd.Flags().StringP("name", "n", "", "Cluster Name")
+
+	migrateLayoutCmd.MarkFlagRequired("name")
+}
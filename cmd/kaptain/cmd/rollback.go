@@ -0,0 +1,70 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/kaptain"
+)
+
+// rollbackCmd represents the rollback command
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Restore a previous revision of a cluster",
+	Long: `Restore a previous revision of a cluster.
+The cluster spec, PKIs, tokens and rendered config files of the revision are
+saved again as a new revision, so the rollback itself can be undone.
+
+$ kaptain history -n dev.example.com
+$ kaptain rollback -n dev.example.com --to 3
+
+Nodes pick up the restored files the next time 'sailor provision' runs.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flagset := cmd.Flags()
+
+		clusterName, err := flagset.GetString("name")
+		if err != nil {
+			panic(err)
+		}
+
+		revision, err := flagset.GetInt("to")
+		if err != nil {
+			panic(err)
+		}
+
+		client := kaptain.KaptainClient{
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
+		}
+
+		if err := client.Rollback(clusterName, revision); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(rollbackCmd)
+
+	rollbackCmd.Flags().StringP("name", "n", "", "Cluster Name")
+	rollbackCmd.Flags().Int("to", 0, "Revision to restore (see 'kaptain history')")
+
+	rollbackCmd.MarkFlagRequired("name")
+	rollbackCmd.MarkFlagRequired("to")
+}
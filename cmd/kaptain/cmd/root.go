@@ -25,6 +25,7 @@ import (
 
 var cfgFile string
 var storeUrl string
+var encryptionKeyUrl string
 
 // RootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
@@ -39,6 +40,15 @@ $ export VAULT_ADDR="https://vault.service.consul:8200"
 $ export KAPTAIN_STORE="vault://project/kaptain?role_id=1234-1234-1234-1234&secret_id=<redacted>"
 $ kaptain list
 
+To encrypt the secrets and role files of clusters in the store, set environment variable
+"KAPTAIN_ENCRYPTION_KEY" to a key file, a passphrase environment variable or an AWS KMS key.
+Clusters saved before are read in plaintext and encrypted when they are saved again.
+
+$ openssl rand -base64 32 > /etc/kaptain/cluster.key
+$ export KAPTAIN_ENCRYPTION_KEY="file:///etc/kaptain/cluster.key"
+$ export KAPTAIN_ENCRYPTION_KEY="passphrase://KAPTAIN_PASSPHRASE"
+$ export KAPTAIN_ENCRYPTION_KEY="awskms://alias/kaptain?region=eu-west-1"
+
 For details usage, please see help of each sub-command.
 `,
 	// Uncomment the following line if your bare application
@@ -93,6 +103,7 @@ func initConfig() {
 
 	// store settings
 	storeUrl = viper.GetString("store")
+	encryptionKeyUrl = viper.GetString("encryption_key")
 
 	// log settings
 	switch logLevel := viper.GetString("log"); logLevel {
@@ -0,0 +1,118 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/kaptain"
+)
+
+// rotateCertsCmd represents the rotate-certs command
+var rotateCertsCmd = &cobra.Command{
+	Use:   "rotate-certs",
+	Short: "Reissue the certs or rotate the CAs of a cluster",
+	Long: `Reissue leaf certs of a cluster with the existing CAs and re-render the
+config files and kubeconfigs using them. The change is saved as a new revision.
+
+$ kaptain rotate-certs -n dev.example.com --cert kubernetes,etcd-server
+$ kaptain rotate-certs -n dev.example.com --all-leaves
+
+Rotating a CA (etcd-ca or kube-ca) is done in three stages. Run 'sailor provision'
+on every node of the cluster after each stage before running the next one.
+
+$ kaptain rotate-certs -n dev.example.com --ca kube-ca --stage trust
+    creates the next CA and adds it to the CA bundles, so nodes trust both CAs
+$ kaptain rotate-certs -n dev.example.com --ca kube-ca --stage sign
+    signs with the next CA and reissues all certs signed by the CA
+$ kaptain rotate-certs -n dev.example.com --ca kube-ca --stage finish
+    removes the previous CA from the CA bundles
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flagset := cmd.Flags()
+
+		clusterName, err := flagset.GetString("name")
+		if err != nil {
+			panic(err)
+		}
+
+		certNames, err := flagset.GetStringSlice("cert")
+		if err != nil {
+			panic(err)
+		}
+
+		allLeaves, err := flagset.GetBool("all-leaves")
+		if err != nil {
+			panic(err)
+		}
+
+		caName, err := flagset.GetString("ca")
+		if err != nil {
+			panic(err)
+		}
+
+		stage, err := flagset.GetString("stage")
+		if err != nil {
+			panic(err)
+		}
+
+		client := kaptain.KaptainClient{
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
+		}
+
+		switch {
+		case caName != "":
+			if len(certNames) > 0 || allLeaves {
+				log.Fatal("--ca cannot be used with --cert or --all-leaves")
+				os.Exit(1)
+			}
+			if stage == "" {
+				log.Fatal("--stage is required to rotate a CA")
+				os.Exit(1)
+			}
+			err = client.RotateCA(clusterName, caName, stage)
+		case allLeaves:
+			if len(certNames) > 0 {
+				log.Fatal("--cert cannot be used with --all-leaves")
+				os.Exit(1)
+			}
+			err = client.RotateCerts(clusterName, nil)
+		case len(certNames) > 0:
+			err = client.RotateCerts(clusterName, certNames)
+		default:
+			log.Fatal("One of --cert, --all-leaves or --ca must be set")
+			os.Exit(1)
+		}
+
+		if err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(rotateCertsCmd)
+
+	rotateCertsCmd.Flags().StringP("name", "n", "", "Cluster Name")
+	rotateCertsCmd.Flags().StringSlice("cert", []string{}, "Leaf certs to reissue (etcd-server, etcd-client, kubernetes, kube-controller-manager, kube-scheduler, kube-proxy)")
+	rotateCertsCmd.Flags().Bool("all-leaves", false, "Reissue all leaf certs")
+	rotateCertsCmd.Flags().String("ca", "", "CA to rotate (etcd-ca, kube-ca)")
+	rotateCertsCmd.Flags().String("stage", "", "CA rotation stage (trust, sign, finish)")
+
+	rotateCertsCmd.MarkFlagRequired("name")
+}
@@ -0,0 +1,76 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/kaptain"
+)
+
+// tokenCreateCmd represents the token create command
+var tokenCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a bootstrap token for workers to join a cluster",
+	Long: `Create a bootstrap token that expires after --ttl and print it. The token is
+created in the running cluster and rendered in the bootstrap.kubeconfig of
+workers.
+
+$ kaptain token create -n dev.example.com --ttl 24h
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flagset := cmd.Flags()
+
+		clusterName, err := flagset.GetString("name")
+		if err != nil {
+			panic(err)
+		}
+
+		ttl, err := flagset.GetDuration("ttl")
+		if err != nil {
+			panic(err)
+		}
+
+		description, err := flagset.GetString("description")
+		if err != nil {
+			panic(err)
+		}
+
+		client := kaptain.KaptainClient{
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
+		}
+
+		token, err := client.CreateBootstrapToken(clusterName, ttl, description)
+		if err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+
+		fmt.Println(token)
+	},
+}
+
+func init() {
+	tokenCmd.AddCommand(tokenCreateCmd)
+
+	tokenCreateCmd.Flags().StringP("name", "n", "", "Cluster Name")
+	tokenCreateCmd.Flags().Duration("ttl", kaptain.DefaultBootstrapTokenTTL, "Time until the token expires")
+	tokenCreateCmd.Flags().String("description", "created by kaptain token create", "Description of the token")
+
+	tokenCreateCmd.MarkFlagRequired("name")
+}
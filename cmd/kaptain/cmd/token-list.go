@@ -0,0 +1,59 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/kaptain"
+)
+
+// tokenListCmd represents the token list command
+var tokenListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the bootstrap tokens of a cluster",
+	Long: `List the bootstrap tokens of a cluster with their expiration. Token secrets
+are not printed.
+
+$ kaptain token list -n dev.example.com
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flagset := cmd.Flags()
+
+		clusterName, err := flagset.GetString("name")
+		if err != nil {
+			panic(err)
+		}
+
+		client := kaptain.KaptainClient{
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
+		}
+
+		if err := client.ListBootstrapTokens(clusterName, os.Stdout); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	tokenCmd.AddCommand(tokenListCmd)
+
+	tokenListCmd.Flags().StringP("name", "n", "", "Cluster Name")
+
+	tokenListCmd.MarkFlagRequired("name")
+}
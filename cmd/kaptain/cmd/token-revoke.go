@@ -0,0 +1,67 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/kaptain"
+)
+
+// tokenRevokeCmd represents the token revoke command
+var tokenRevokeCmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "Revoke a bootstrap token of a cluster",
+	Long: `Revoke a bootstrap token of a cluster. The token is deleted from the running
+cluster, so workers can no longer join with it. The last valid token cannot be
+revoked, create a new one with 'kaptain token create' first.
+
+$ kaptain token revoke -n dev.example.com --id abcdef
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flagset := cmd.Flags()
+
+		clusterName, err := flagset.GetString("name")
+		if err != nil {
+			panic(err)
+		}
+
+		id, err := flagset.GetString("id")
+		if err != nil {
+			panic(err)
+		}
+
+		client := kaptain.KaptainClient{
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
+		}
+
+		if err := client.RevokeBootstrapToken(clusterName, id); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	tokenCmd.AddCommand(tokenRevokeCmd)
+
+	tokenRevokeCmd.Flags().StringP("name", "n", "", "Cluster Name")
+	tokenRevokeCmd.Flags().String("id", "", "ID of the token (see 'kaptain token list')")
+
+	tokenRevokeCmd.MarkFlagRequired("name")
+	tokenRevokeCmd.MarkFlagRequired("id")
+}
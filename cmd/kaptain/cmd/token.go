@@ -0,0 +1,40 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// tokenCmd represents the token command
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage the bootstrap tokens workers join a cluster with",
+	Long: `Manage the bootstrap tokens of a cluster created with
+--kubelet-bootstrap-mode=bootstrap-token. Workers join the cluster with the
+token that expires last, which is rendered in their bootstrap.kubeconfig.
+
+$ kaptain token list -n dev.example.com
+$ kaptain token create -n dev.example.com --ttl 24h
+$ kaptain token revoke -n dev.example.com --id abcdef
+
+Tokens are created and deleted in the running cluster with kubectl, using the
+context named after the cluster (see 'kaptain export-config -h'). Every change
+is saved as a new revision. Run 'sailor provision' on new workers afterwards.
+`,
+}
+
+func init() {
+	RootCmd.AddCommand(tokenCmd)
+}
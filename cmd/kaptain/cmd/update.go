@@ -0,0 +1,125 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/kaptain"
+)
+
+var updateSpec api.ClusterSpec
+var updateEtcdServers string
+var updateMasters string
+var updateOpts kaptain.ApplyOptions
+
+// updateCmd represents the update command
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update settings of an existing cluster",
+	Long: `Update settings of an existing cluster with command line flags.
+Only the flags that are set are changed, all other settings are kept.
+For example, to replace the Docker registry mirrors of cluster 'dev.example.com':
+
+$ kaptain update -n dev.example.com --docker-registry-mirror=https://mirror.example.com
+
+Like 'kaptain apply', all config files are rendered again and existing PKIs and
+tokens are kept.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flagset := cmd.Flags()
+
+		clusterName, err := flagset.GetString("name")
+		if err != nil {
+			panic(err)
+		}
+
+		client := kaptain.KaptainClient{
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
+		}
+
+		cluster, err := client.Get(clusterName)
+		if err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+
+		spec := &cluster.Spec
+		if flagset.Changed("kube-version") {
+			spec.KubeVersion = updateSpec.KubeVersion
+		}
+		if flagset.Changed("apiserver") {
+			spec.MasterPublicName = updateSpec.MasterPublicName
+		}
+		if flagset.Changed("apiserver-port") {
+			spec.MasterPort = updateSpec.MasterPort
+		}
+		if flagset.Changed("etcd-servers") {
+			spec.EtcdCluster = newEtcdCluster(updateEtcdServers)
+		}
+		if flagset.Changed("masters") {
+			spec.Masters = newMasterNodes(updateMasters)
+		}
+		if flagset.Changed("node-certs-only") {
+			spec.PKIOpts.NodeCertsOnly = updateSpec.PKIOpts.NodeCertsOnly
+		}
+		if flagset.Changed("kubelet-server-tls-bootstrap") {
+			spec.KubeletBootstrapOpts.ServerTLSBootstrap = updateSpec.KubeletBootstrapOpts.ServerTLSBootstrap
+		}
+		if flagset.Changed("docker-kube-image-proxy") {
+			spec.DockerOpts.KubeImageProxy = updateSpec.DockerOpts.KubeImageProxy
+		}
+		if flagset.Changed("docker-insecure-registry") {
+			spec.DockerOpts.InsecureRegistries = updateSpec.DockerOpts.InsecureRegistries
+		}
+		if flagset.Changed("docker-registry-mirror") {
+			spec.DockerOpts.RegistryMirrors = updateSpec.DockerOpts.RegistryMirrors
+		}
+		if flagset.Changed("authentication-token-webhook-cache-ttl") {
+			spec.AuthenticationTokenWebhookOpts.CacheTTL = updateSpec.AuthenticationTokenWebhookOpts.CacheTTL
+		}
+		if flagset.Changed("enable-pod-security-policy") {
+			spec.PodSecurityPolicyOpts.Enabled = updateSpec.PodSecurityPolicyOpts.Enabled
+		}
+
+		if err := client.Apply(cluster, &updateOpts); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(updateCmd)
+
+	updateCmd.Flags().StringP("name", "n", "", "Cluster Name")
+	updateCmd.Flags().StringVar(&updateSpec.KubeVersion, "kube-version", "", "Specify Kubernetes Version")
+	updateCmd.Flags().StringVar(&updateSpec.MasterPublicName, "apiserver", "", "Kubernetes API server name")
+	updateCmd.Flags().IntVar(&updateSpec.MasterPort, "apiserver-port", kaptain.DefaultMasterPort, "Kubernetes API server listen port")
+	updateCmd.Flags().StringVar(&updateEtcdServers, "etcd-servers", "", "Comma-separated ETCD server hostnames, each optionally with its IP as hostname=ip")
+	updateCmd.Flags().StringVar(&updateMasters, "masters", "", "Comma-separated master hostnames, each optionally with its IP as hostname=ip (replaces the existing list)")
+	updateCmd.Flags().BoolVar(&updateSpec.PKIOpts.NodeCertsOnly, "node-certs-only", false, "Only provision the etcd and apiserver serving certs issued to each node (requires 'sailor provision --hostname')")
+	updateCmd.Flags().StringVar(&updateSpec.DockerOpts.KubeImageProxy, "docker-kube-image-proxy", "", "Set this flag to use a proxy to download gcr.io images (e.g. gcr.io/google_containers/kube-apiserver)")
+	updateCmd.Flags().StringArrayVar(&updateSpec.DockerOpts.InsecureRegistries, "docker-insecure-registry", []string{}, "Insecure Docker registries to allow (replaces the existing list)")
+	updateCmd.Flags().StringArrayVar(&updateSpec.DockerOpts.RegistryMirrors, "docker-registry-mirror", []string{}, "Docker registry mirrors (replaces the existing list)")
+	updateCmd.Flags().StringVar(&updateSpec.AuthenticationTokenWebhookOpts.CacheTTL, "authentication-token-webhook-cache-ttl", "", "Kubernetes Authentication Webhook Cache TTL")
+	updateCmd.Flags().BoolVar(&updateSpec.PodSecurityPolicyOpts.Enabled, "enable-pod-security-policy", false, "Enable PodSecurityPolicy, see 'cluster/pod-security-policy' for set up details")
+	updateCmd.Flags().BoolVar(&updateSpec.KubeletBootstrapOpts.ServerTLSBootstrap, "kubelet-server-tls-bootstrap", false, "Serve kubelets with certs signed by the cluster CA and verify them from the apiserver (Kubernetes 1.10+)")
+	updateCmd.Flags().BoolVar(&updateOpts.ReissueCerts, "reissue-certs", false, "Reissue certs invalidated by the change")
+
+	updateCmd.MarkFlagRequired("name")
+}
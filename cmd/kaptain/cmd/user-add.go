@@ -0,0 +1,78 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/kaptain"
+)
+
+// userAddCmd represents the user add command
+var userAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add a token user to a cluster",
+	Long: `Add a token user to a cluster. A new token is generated for the user. Without
+--uid, the first free UID from 2000 is used.
+
+$ kaptain user add -n dev.example.com --user ci-deployer --group system:masters --uid 2001
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flagset := cmd.Flags()
+
+		clusterName, err := flagset.GetString("name")
+		if err != nil {
+			panic(err)
+		}
+
+		username, err := flagset.GetString("user")
+		if err != nil {
+			panic(err)
+		}
+
+		groups, err := flagset.GetStringSlice("group")
+		if err != nil {
+			panic(err)
+		}
+
+		uid, err := flagset.GetInt("uid")
+		if err != nil {
+			panic(err)
+		}
+
+		client := kaptain.KaptainClient{
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
+		}
+
+		if err := client.AddUser(clusterName, username, uid, groups); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	userCmd.AddCommand(userAddCmd)
+
+	userAddCmd.Flags().StringP("name", "n", "", "Cluster Name")
+	userAddCmd.Flags().String("user", "", "Username")
+	userAddCmd.Flags().StringSlice("group", []string{}, "Group of the user (can be repeated)")
+	userAddCmd.Flags().Int("uid", 0, "UID of the user (default first free UID from 2000)")
+
+	userAddCmd.MarkFlagRequired("name")
+	userAddCmd.MarkFlagRequired("user")
+}
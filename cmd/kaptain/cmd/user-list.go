@@ -0,0 +1,76 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/kaptain"
+)
+
+// userListCmd represents the user list command
+var userListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the token users of a cluster",
+	Long: `List the token users of a cluster with their UID and groups. Tokens are not
+printed. With --auth=cert, list the client certs issued by
+'kaptain export-config --auth=cert' instead.
+
+$ kaptain user list -n dev.example.com
+$ kaptain user list -n dev.example.com --auth=cert
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flagset := cmd.Flags()
+
+		clusterName, err := flagset.GetString("name")
+		if err != nil {
+			panic(err)
+		}
+
+		auth, err := flagset.GetString("auth")
+		if err != nil {
+			panic(err)
+		}
+
+		client := kaptain.KaptainClient{
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
+		}
+
+		switch auth {
+		case "token":
+			err = client.ListUsers(clusterName, os.Stdout)
+		case "cert":
+			err = client.ListClientCerts(clusterName, os.Stdout)
+		default:
+			err = fmt.Errorf("invalid auth '%s' (must be one of token, cert)", auth)
+		}
+		if err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	userCmd.AddCommand(userListCmd)
+
+	userListCmd.Flags().StringP("name", "n", "", "Cluster Name")
+	userListCmd.Flags().String("auth", "token", "Authentication of the users to list (token or cert)")
+
+	userListCmd.MarkFlagRequired("name")
+}
@@ -0,0 +1,66 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/kaptain"
+)
+
+// userRemoveCmd represents the user remove command
+var userRemoveCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "Remove a token user from a cluster",
+	Long: `Remove a token user from a cluster. The system users (admin and
+kubelet-bootstrap) cannot be removed.
+
+$ kaptain user remove -n dev.example.com --user ci-deployer
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flagset := cmd.Flags()
+
+		clusterName, err := flagset.GetString("name")
+		if err != nil {
+			panic(err)
+		}
+
+		username, err := flagset.GetString("user")
+		if err != nil {
+			panic(err)
+		}
+
+		client := kaptain.KaptainClient{
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
+		}
+
+		if err := client.RemoveUser(clusterName, username); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	userCmd.AddCommand(userRemoveCmd)
+
+	userRemoveCmd.Flags().StringP("name", "n", "", "Cluster Name")
+	userRemoveCmd.Flags().String("user", "", "Username")
+
+	userRemoveCmd.MarkFlagRequired("name")
+	userRemoveCmd.MarkFlagRequired("user")
+}
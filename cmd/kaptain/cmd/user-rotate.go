@@ -0,0 +1,66 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/kaptain"
+)
+
+// userRotateCmd represents the user rotate command
+var userRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Replace the token of a user of a cluster",
+	Long: `Replace the token of a user of a cluster. The old token stops working once
+'sailor provision' has run on all masters.
+
+$ kaptain user rotate -n dev.example.com --user ci-deployer
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flagset := cmd.Flags()
+
+		clusterName, err := flagset.GetString("name")
+		if err != nil {
+			panic(err)
+		}
+
+		username, err := flagset.GetString("user")
+		if err != nil {
+			panic(err)
+		}
+
+		client := kaptain.KaptainClient{
+			Registry: api.NewClusterRegistry(storeUrl, encryptionKeyUrl),
+		}
+
+		if err := client.RotateUserToken(clusterName, username); err != nil {
+			log.Fatal(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	userCmd.AddCommand(userRotateCmd)
+
+	userRotateCmd.Flags().StringP("name", "n", "", "Cluster Name")
+	userRotateCmd.Flags().String("user", "", "Username")
+
+	userRotateCmd.MarkFlagRequired("name")
+	userRotateCmd.MarkFlagRequired("user")
+}
@@ -0,0 +1,40 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// userCmd represents the user command
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage the token users of a cluster",
+	Long: `Manage the users that authenticate to the API server with a token from the
+token.csv of masters.
+
+$ kaptain user list -n dev.example.com
+$ kaptain user add -n dev.example.com --user ci-deployer --group system:masters --uid 2001
+$ kaptain user rotate -n dev.example.com --user ci-deployer
+$ kaptain user remove -n dev.example.com --user ci-deployer
+
+Every change is saved as a new revision. Run 'sailor provision' on all masters
+afterwards, and export the kubeconfig of the user again (see
+'kaptain export-config -h').
+`,
+}
+
+func init() {
+	RootCmd.AddCommand(userCmd)
+}
@@ -0,0 +1,80 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/sailor"
+	"github.com/spf13/cobra"
+)
+
+var sailorAgent = sailor.Agent{Client: &sailorClient}
+
+// agentCmd represents the agent command
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Keep the current node provisioned with the latest files",
+	Long: `Poll the files of the role and node in the store and provision the node whenever they
+change. Only the version of the files is polled where the store supports it (the ETag of
+S3 objects), the files are downloaded when they have changed.
+
+After provisioning, the reload command of each changed file is run once, e.g. restarting
+the kubelet after its config has changed. Failed commands are retried with the next poll.
+Polls are moved by up to --jitter of the interval at random, so nodes do not poll at once,
+and are skipped while another sailor provisions the node.
+
+Commands that restart etcd or kube-apiserver are only run with --quorum-reloads. The
+members of a role reload within the same interval, so a cert rotation would restart all of
+them at about the same time and could lose the quorum of etcd. Without the flag, the agent
+logs the command to run on one member at a time.
+
+$ sailor agent --name=dev.example.com --role=worker --interval=5m
+
+The agent supports systemd services of Type=notify, it is ready after the first poll and
+pings the watchdog if WatchdogSec is set.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateNodeFlags()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		sailorClient.Registry = api.NewClusterRegistry(storeUrl, encryptionKeyUrl)
+
+		stop := make(chan struct{})
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-signals
+			close(stop)
+		}()
+
+		if err := sailorAgent.Run(stop); err != nil {
+			log.Fatalf("sailor agent failed: %v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(agentCmd)
+
+	addNodeFlags(agentCmd)
+	agentCmd.Flags().DurationVar(&sailorAgent.Interval, "interval", 5*time.Minute, "Time between polls of the store")
+	agentCmd.Flags().Float64Var(&sailorAgent.Jitter, "jitter", 0.1, "Fraction of the interval each poll is moved by at random")
+	agentCmd.Flags().BoolVar(&sailorAgent.QuorumReloads, "quorum-reloads", false, "Run the reload commands that restart etcd or kube-apiserver")
+}
@@ -0,0 +1,60 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"log"
+	"os"
+
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/spf13/cobra"
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show what provisioning the current node would change",
+	Long: `Compare the files of the role with the files on the node and print the unified
+diff of every file that would change, the same as 'sailor provision --dry-run'. The
+content of keys, tokens and other secrets is never printed. Nothing is written.
+
+$ sailor diff --name=dev.example.com --role=master --hostname=master-k8s-0
+
+Exits with code 2 if provisioning would change any file.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateNodeFlags()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		sailorClient.Registry = api.NewClusterRegistry(storeUrl, encryptionKeyUrl)
+		sailorClient.DryRun = true
+
+		result, err := sailorClient.Provision()
+		if err != nil {
+			log.Fatalf("failed to compare node: %v", err)
+			os.Exit(1)
+		}
+
+		result.PrintDiffs(os.Stdout)
+		result.PrintSummary(os.Stdout)
+		if result.Changed() {
+			os.Exit(exitCodeChanged)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(diffCmd)
+
+	addNodeFlags(diffCmd)
+}
@@ -19,37 +19,54 @@ import (
 	"os"
 
 	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/sailor"
 	"github.com/spf13/cobra"
 )
 
+// exit code of a run that changed files, so cloud-init or config management can react
+const exitCodeChanged = 2
+
 // provisionCmd represents the provision command
 var provisionCmd = &cobra.Command{
 	Use:   "provision",
 	Short: "Prepare the current Kubernetes node",
 	Long: `Follow Kaptain's instruction to download the correct config files 
-	and TLS assets to configure the current Kubernetes node`,
-	PreRunE: func(cmd *cobra.Command, args []string) error {
-		if sailorClient.ClusterName == "" {
-			return fmt.Errorf("--name must be set")
-		}
+	and TLS assets to configure the current Kubernetes node.
 
-		switch sailorClient.Role {
-		case "etcd":
-		case "master":
-		case "worker":
-		default:
-			return fmt.Errorf("--role must be one of etcd, master or worker")
-		}
+Only files that have changed are written, each through a temp file that is renamed once
+all files are written. The files written are recorded in a state file, files dropped from
+the role since the last run are removed unless they have been changed on the node.
 
-		return nil
+Exits with code 2 if any file has been created, updated or removed. With --dry-run nothing
+is written, the diff of every file that would change is printed instead (see 'sailor diff').`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateNodeFlags()
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		sailorClient.Registry = api.NewClusterRegistry(storeUrl)
+		sailorClient.Registry = api.NewClusterRegistry(storeUrl, encryptionKeyUrl)
 
-		if err := sailorClient.Provision(); err != nil {
+		if !sailorClient.DryRun {
+			unlock, err := sailorClient.Lock()
+			if err != nil {
+				log.Fatalf("failed to provision node: %v", err)
+				os.Exit(1)
+			}
+			defer unlock()
+		}
+
+		result, err := sailorClient.Provision()
+		if err != nil {
 			log.Fatalf("failed to provision node: %v", err)
 			os.Exit(1)
 		}
+
+		if sailorClient.DryRun {
+			result.PrintDiffs(os.Stdout)
+		}
+		result.PrintSummary(os.Stdout)
+		if result.Changed() {
+			os.Exit(exitCodeChanged)
+		}
 	},
 }
 
@@ -66,6 +83,35 @@ func init() {
 	// is called directly, e.g.:
 	// provisionCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 
-	provisionCmd.Flags().StringVar(&sailorClient.Role, "role", "", "Sailor role ('etcd', 'master' or 'worker')")
-	provisionCmd.Flags().StringVar(&sailorClient.Prefix, "prefix", "/", "Base directory for writing all the files to")
+	addNodeFlags(provisionCmd)
+	provisionCmd.Flags().BoolVar(&sailorClient.DryRun, "dry-run", false, "Print the diff of the files that would change without writing them")
+}
+
+// addNodeFlags adds the flags of the node to provision to a command
+func addNodeFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&sailorClient.Role, "role", "", "Sailor role ('etcd', 'master' or 'worker')")
+	cmd.Flags().StringVar(&sailorClient.Prefix, "prefix", "/", "Base directory for writing all the files to")
+	cmd.Flags().StringVar(&sailorClient.Hostname, "hostname", "", "Hostname of the node, to fetch the certs issued to it ('etcd' and 'master' only)")
+	cmd.Flags().StringVar(&sailorClient.IP, "ip", "", "IP of the node, checked against the IP its certs are issued for")
+	cmd.Flags().StringVar(&sailorClient.StateFile, "state-file", "", "State file of the files written (default <prefix>/"+sailor.DefaultStateFile+")")
+}
+
+func validateNodeFlags() error {
+	if sailorClient.ClusterName == "" {
+		return fmt.Errorf("--name must be set")
+	}
+
+	switch sailorClient.Role {
+	case "etcd":
+	case "master":
+	case "worker":
+	default:
+		return fmt.Errorf("--role must be one of etcd, master or worker")
+	}
+
+	if sailorClient.IP != "" && sailorClient.Hostname == "" {
+		return fmt.Errorf("--ip requires --hostname")
+	}
+
+	return nil
 }
@@ -27,6 +27,7 @@ import (
 
 var cfgFile string
 var storeUrl string
+var encryptionKeyUrl string
 var sailorClient sailor.SailorClient
 
 // RootCmd represents the base command when called without any subcommands
@@ -42,6 +43,12 @@ $ export VAULT_ADDR="https://vault.service.consul:8200"
 $ export KAPTAIN_STORE="vault://project/kaptain?role_id=1234-1234-1234-1234&secret_id=<redacted>"
 $ sailor provision --role=etcd -n dev.example.com
 
+Clusters encrypted by Kaptain with a key file or a passphrase are read with the same key, set
+in environment variable "KAPTAIN_ENCRYPTION_KEY". Clusters encrypted with AWS KMS only need
+access to the KMS key.
+
+$ export KAPTAIN_ENCRYPTION_KEY="file:///etc/kaptain/cluster.key"
+
 For details usage, please see help of each sub-command.
 `,
 	// Uncomment the following line if your bare application
@@ -100,6 +107,7 @@ func initConfig() {
 
 	// store settings
 	storeUrl = viper.GetString("store")
+	encryptionKeyUrl = viper.GetString("encryption_key")
 
 	// log settings
 	switch logLevel := viper.GetString("log"); logLevel {
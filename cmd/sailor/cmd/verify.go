@@ -0,0 +1,59 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"log"
+	"os"
+
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/spf13/cobra"
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check the current node for drift from the cluster",
+	Long: `Check that the files on the node match the files of the role, without writing
+anything. Files that are missing, have changed or should have been removed are listed.
+
+$ sailor verify --name=dev.example.com --role=worker
+
+Exits with code 2 on drift and 1 on errors, so it can run as a periodic health check.
+Use 'sailor diff' to see the changes.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateNodeFlags()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		sailorClient.Registry = api.NewClusterRegistry(storeUrl, encryptionKeyUrl)
+		sailorClient.DryRun = true
+
+		result, err := sailorClient.Provision()
+		if err != nil {
+			log.Fatalf("failed to verify node: %v", err)
+			os.Exit(1)
+		}
+
+		result.PrintSummary(os.Stdout)
+		if result.Changed() {
+			os.Exit(exitCodeChanged)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(verifyCmd)
+
+	addNodeFlags(verifyCmd)
+}
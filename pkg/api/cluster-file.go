@@ -1,9 +1,16 @@
 package api
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"github.com/javefang/kaptain/pkg/utils/cryptoutil"
 )
 
 type ClusterFiles struct {
@@ -14,7 +21,8 @@ type ClusterFiles struct {
 }
 
 type ClusterFilesSpec struct {
-	ClusterFiles []*ClusterFile `json:"files"`
+	ClusterFiles   []*ClusterFile         `json:"files"`
+	EncryptedFiles *cryptoutil.SealedData `json:"encryptedFiles,omitempty"` // the files as stored by a registry with a key provider
 }
 
 func NewClusterFiles() *ClusterFiles {
@@ -26,12 +34,93 @@ func NewClusterFiles() *ClusterFiles {
 	return &cf
 }
 
+// Annotations of the cluster files of a single node
+const (
+	NodeHostnameAnnotation = "kaptain/hostname"
+	NodeIPAnnotation       = "kaptain/ip"
+)
+
+// MakeNodeRole returns the name under which the cluster files of a single node of a role
+// are stored, next to the files shared by all nodes of the role
+func MakeNodeRole(role string, hostname string) string {
+	return fmt.Sprintf("%s@%s", role, hostname)
+}
+
+// SplitNodeRole returns the role and hostname of the cluster files of a single node, the
+// last value is false if the files are shared by all nodes of the role
+func SplitNodeRole(nodeRole string) (string, string, bool) {
+	parts := strings.SplitN(nodeRole, "@", 2)
+	if len(parts) != 2 {
+		return nodeRole, "", false
+	}
+	return parts[0], parts[1], true
+}
+
 // ClusterFile represents a file to be provisioned on a cluster node
 type ClusterFile struct {
 	Path       string `json:"path"`
 	DataBase64 string `json:"data"`
+	SHA256     string `json:"sha256,omitempty"`    // hex encoded SHA-256 of the data
+	Sensitive  bool   `json:"sensitive,omitempty"` // true if the file contains keys, tokens or passwords
+	Mode       string `json:"mode,omitempty"`      // octal permissions of the file, e.g. 0600
+	Owner      string `json:"owner,omitempty"`     // user name or id of the file, the user of sailor if empty
+	Group      string `json:"group,omitempty"`     // group name or id of the file, the group of sailor if empty
+	DirMode    string `json:"dirMode,omitempty"`   // octal permissions of the parent dirs created for the file
+	Reload     string `json:"reload,omitempty"`    // command run by sailor agent after changing the file
+	// true if the reload command restarts a member of a quorum, such as etcd, which sailor
+	// agent only runs when enabled
+	QuorumReload bool `json:"quorumReload,omitempty"`
+}
+
+// NewClusterFile creates a cluster file of the data with its checksum
+func NewClusterFile(path string, data []byte) *ClusterFile {
+	return &ClusterFile{
+		Path:       path,
+		DataBase64: base64.StdEncoding.EncodeToString(data),
+		SHA256:     Checksum(data),
+	}
+}
+
+// Checksum returns the hex encoded SHA-256 of the data of a cluster file
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
 }
 
 func (cf *ClusterFile) GetData() ([]byte, error) {
 	return base64.StdEncoding.DecodeString(cf.DataBase64)
 }
+
+// GetVerifiedData returns the data of the file, checked against its checksum if it has one
+func (cf *ClusterFile) GetVerifiedData() ([]byte, error) {
+	data, err := cf.GetData()
+	if err != nil {
+		return nil, err
+	}
+	if cf.SHA256 != "" && Checksum(data) != cf.SHA256 {
+		return nil, fmt.Errorf("checksum mismatch of %s", cf.Path)
+	}
+	return data, nil
+}
+
+// GetMode returns the permissions of the file, or defaultMode for files rendered before
+// modes were introduced
+func (cf *ClusterFile) GetMode(defaultMode os.FileMode) (os.FileMode, error) {
+	return parseFileMode(cf.Mode, defaultMode)
+}
+
+// GetDirMode returns the permissions of the parent dirs created for the file, or defaultMode
+func (cf *ClusterFile) GetDirMode(defaultMode os.FileMode) (os.FileMode, error) {
+	return parseFileMode(cf.DirMode, defaultMode)
+}
+
+func parseFileMode(mode string, defaultMode os.FileMode) (os.FileMode, error) {
+	if mode == "" {
+		return defaultMode, nil
+	}
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || os.FileMode(perm)&^os.ModePerm != 0 {
+		return 0, fmt.Errorf("invalid file mode '%s' (must be octal permissions, e.g. 0644)", mode)
+	}
+	return os.FileMode(perm), nil
+}
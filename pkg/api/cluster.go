@@ -3,8 +3,10 @@ package api
 import (
 	"encoding/base64"
 	"fmt"
+	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"github.com/javefang/kaptain/pkg/utils/cryptoutil"
 	"github.com/javefang/kaptain/pkg/version"
 )
 
@@ -15,9 +17,10 @@ type Cluster struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec          ClusterSpec       `json:"spec"`
-	AssetManifest AssetManifestSpec `json:"assetManifest"`
-	Secrets       ClusterSecrets    `json:"secrets"`
+	Spec             ClusterSpec            `json:"spec"`
+	AssetManifest    AssetManifestSpec      `json:"assetManifest"`
+	Secrets          ClusterSecrets         `json:"secrets"`
+	EncryptedSecrets *cryptoutil.SealedData `json:"encryptedSecrets,omitempty"` // the secrets as stored by a registry with a key provider
 }
 
 // ClusterSpec is the spec that defines a the property of a cluster
@@ -37,12 +40,18 @@ type ClusterSpec struct {
 	PodSecurityPolicyOpts          PodSecurityPolicyOpts          `json:"podSecurityPolicyOpts"`
 	AuthenticationTokenWebhookOpts AuthenticationTokenWebhookOpts `json:"authenticationTokenWebhookOpts"`
 	EtcdCluster                    EtcdCluster                    `json:"etcdCluster"`
+	PKIOpts                        PKIOpts                        `json:"pkiOpts"`
+	KubeletBootstrapOpts           KubeletBootstrapOpts           `json:"kubeletBootstrapOpts"`
+	Masters                        []MasterNode                   `json:"masters,omitempty"`
 }
 
 // ClusterSecrets stores PKI and token secrets used to secure the cluster
 type ClusterSecrets struct {
-	PKIs         map[string]CertPair    `json:"pkis"`
-	TokenSecrets map[string]TokenSecret `json:"tokenSecrets"`
+	PKIs            map[string]CertPair       `json:"pkis"`
+	TokenSecrets    map[string]TokenSecret    `json:"tokenSecrets"`
+	ClientCerts     []ClientCertRecord        `json:"clientCerts,omitempty"`
+	BootstrapTokens map[string]BootstrapToken `json:"bootstrapTokens,omitempty"`
+	KeyPairs        map[string]KeyPair        `json:"keyPairs,omitempty"`
 }
 
 // DockerOpts is the configurable options for Docker
@@ -73,6 +82,22 @@ type PodSecurityPolicyOpts struct {
 	Enabled bool `json:"enabled"`
 }
 
+// PKIOpts is the configurable options for the keys and validity of new certs
+type PKIOpts struct {
+	KeyAlgorithm   string            `json:"keyAlgorithm"`            // key type of leaf certs, e.g. rsa-2048, ecdsa-p256
+	CAKeyAlgorithm string            `json:"caKeyAlgorithm"`          // key type of CAs, e.g. rsa-4096, ecdsa-p384
+	CertValidity   string            `json:"certValidity"`            // validity of leaf certs, e.g. 8760h
+	CAValidity     string            `json:"caValidity"`              // validity of CAs, e.g. 43800h
+	NodeCertsOnly  bool              `json:"nodeCertsOnly,omitempty"` // leave the shared etcd and apiserver serving certs out of the role files
+	Signers        map[string]string `json:"signers,omitempty"`       // signer url of each CA whose key is not held by kaptain, e.g. vault-pki://pki/kaptain
+}
+
+// KubeletBootstrapOpts is the configurable options for how workers join the cluster
+type KubeletBootstrapOpts struct {
+	Mode               string `json:"mode"`                         // static-token (kubelet-bootstrap user in token.csv) or bootstrap-token
+	ServerTLSBootstrap bool   `json:"serverTLSBootstrap,omitempty"` // kubelets serve with certs signed by kube-ca, verified by the apiserver
+}
+
 // EtcdCluster contains information about the ETCD cluster used by Kubernetes
 type EtcdCluster struct {
 	Members []EtcdMember `json:"members"`
@@ -81,12 +106,27 @@ type EtcdCluster struct {
 // EtcdMember contains information about a single ETCD node of the ETCD cluster used by Kubernetes
 type EtcdMember struct {
 	Hostname string `json:"hostname"`
+	IP       string `json:"ip,omitempty"` // added to the SANs of the certs of the member
+}
+
+// MasterNode contains information about a master node, which gets its own apiserver serving cert
+type MasterNode struct {
+	Hostname string `json:"hostname"`
+	IP       string `json:"ip,omitempty"` // added to the SANs of the certs of the master
 }
 
 // CertPair is contains base64 encoded cert and key data
 type CertPair struct {
-	CertData string `json:"certData"`
-	KeyData  string `json:"keyData"`
+	CertData  string `json:"certData"`
+	KeyData   string `json:"keyData"`
+	ChainData string `json:"chainData,omitempty"` // base64 encoded certs that issued an imported CA, up to the root
+	Imported  bool   `json:"imported,omitempty"`  // true if the CA was imported instead of created by kaptain
+}
+
+// KeyPair contains base64 encoded private and public key data, for keys without a cert
+type KeyPair struct {
+	PrivateKeyData string `json:"privateKeyData"`
+	PublicKeyData  string `json:"publicKeyData"`
 }
 
 // TokenSecret contains information about a bearing token used for apiserver authentication
@@ -97,6 +137,29 @@ type TokenSecret struct {
 	Groups   []string `json:"groups"`
 }
 
+// BootstrapToken is a Kubernetes bootstrap token used by kubelets to join the cluster
+type BootstrapToken struct {
+	ID          string    `json:"id"`
+	Secret      string    `json:"secret"`
+	Description string    `json:"description"`
+	Expiration  time.Time `json:"expiration"`
+}
+
+// Token returns the bootstrap token in the <id>.<secret> form used by kubelets
+func (t BootstrapToken) Token() string {
+	return fmt.Sprintf("%s.%s", t.ID, t.Secret)
+}
+
+// ClientCertRecord describes a client cert issued to a user by 'kaptain export-config'.
+// The cert and key are not kept.
+type ClientCertRecord struct {
+	Username  string    `json:"username"`
+	Groups    []string  `json:"groups"`
+	Serial    string    `json:"serial"`
+	NotBefore time.Time `json:"notBefore"`
+	NotAfter  time.Time `json:"notAfter"`
+}
+
 // NewCluster creates a new Cluster object
 func NewCluster() Cluster {
 	cluster := Cluster{}
@@ -119,6 +182,19 @@ func getAnnotationFullName(field string) string {
 	return fmt.Sprintf("%s/%s", apiNamespace, field)
 }
 
+// GetAnnotation returns the value of a kaptain annotation of the cluster, e.g. "version"
+func (cluster *Cluster) GetAnnotation(field string) string {
+	return cluster.Annotations[getAnnotationFullName(field)]
+}
+
+// SetAnnotation sets a kaptain annotation of the cluster
+func (cluster *Cluster) SetAnnotation(field string, value string) {
+	if cluster.Annotations == nil {
+		cluster.Annotations = map[string]string{}
+	}
+	cluster.Annotations[getAnnotationFullName(field)] = value
+}
+
 // NodeFile represents a specific version of the node config file template from asset files
 type NodeFile struct {
 	Name    string `json:"name"`
@@ -130,19 +206,82 @@ func (a NodeFile) String() string {
 }
 
 // GetCertData returns the x509 certificate data in bytes
-func (pair CertPair) GetCertData() []byte {
+func (pair CertPair) GetCertData() ([]byte, error) {
 	data, err := base64.StdEncoding.DecodeString(pair.CertData)
 	if err != nil {
-		panic(fmt.Errorf("Failed to decode x509 cert data: %v", err))
+		return nil, fmt.Errorf("Failed to decode x509 cert data: %v", err)
 	}
-	return data
+	return data, nil
+}
+
+// GetChainData returns the PEM data of the chain of an imported CA, empty if it has none
+func (pair CertPair) GetChainData() ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(pair.ChainData)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode x509 chain data: %v", err)
+	}
+	return data, nil
 }
 
 // GetKeyData returns the x509 key data in bytes
-func (pair CertPair) GetKeyData() []byte {
+func (pair CertPair) GetKeyData() ([]byte, error) {
 	data, err := base64.StdEncoding.DecodeString(pair.KeyData)
 	if err != nil {
-		panic(fmt.Errorf("Failed to decode x509 key data: %v", err))
+		return nil, fmt.Errorf("Failed to decode x509 key data: %v", err)
+	}
+	return data, nil
+}
+
+// GetPrivateKeyData returns the decoded private key data
+func (pair KeyPair) GetPrivateKeyData() ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(pair.PrivateKeyData)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode private key data: %v", err)
+	}
+	return data, nil
+}
+
+// GetPublicKeyData returns the decoded public key data
+func (pair KeyPair) GetPublicKeyData() ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(pair.PublicKeyData)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode public key data: %v", err)
+	}
+	return data, nil
+}
+
+// GetCABundle returns the cert data of a CA followed by the next and previous CAs of an
+// ongoing rotation, so the bundle is trusted by both old and new certs. The bundle verifies
+// client certs, so the chain of an imported CA is left out, certs issued by the other CAs of
+// the chain must not authenticate.
+func (secrets ClusterSecrets) GetCABundle(caName string) ([]byte, error) {
+	return secrets.getCABundle(caName, false)
+}
+
+// GetServerCABundle returns the CA bundle followed by the chain of each imported CA, so
+// clients verifying the servers of the cluster trust the full path to the root
+func (secrets ClusterSecrets) GetServerCABundle(caName string) ([]byte, error) {
+	return secrets.getCABundle(caName, true)
+}
+
+func (secrets ClusterSecrets) getCABundle(caName string, withChain bool) ([]byte, error) {
+	bundle := []byte{}
+	for _, name := range []string{caName, caName + "-next", caName + "-previous"} {
+		if pair, exists := secrets.PKIs[name]; exists {
+			data, err := pair.GetCertData()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			bundle = append(bundle, data...)
+			if !withChain {
+				continue
+			}
+			chainData, err := pair.GetChainData()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			bundle = append(bundle, chainData...)
+		}
 	}
-	return data
+	return bundle, nil
 }
@@ -1,29 +1,67 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"path"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ghodss/yaml"
 	log "github.com/sirupsen/logrus"
 	"github.com/javefang/kaptain/pkg/store"
+	"github.com/javefang/kaptain/pkg/utils/cryptoutil"
 )
 
 const clusterSpecFile = "cluster.yaml"
+const revisionFile = "revision.yaml"
+const currentRevisionFile = "current"
+const roleFilesFile = "files.yaml"
 const defaultCAExpiry = time.Hour * 24 * 365 * 5
 const defaultCertExpiry = time.Hour * 24 * 365
 const defaultTokenLength = 32
 
 type ClusterRegistry struct {
-	store store.Store
+	store    store.Store
+	envelope *cryptoutil.Envelope // seals the secrets and role files of saved clusters
 }
 
-func NewClusterRegistry(storeUrl string) *ClusterRegistry {
-	s := store.CreateStoreFromUrlOrDie(storeUrl)
+// NewClusterRegistry creates a registry on the store of the url, it panics if the store or
+// the key provider cannot be created (see CreateClusterRegistryFromUrl)
+func NewClusterRegistry(storeUrl string, keyProviderUrl string) *ClusterRegistry {
+	reg, err := CreateClusterRegistryFromUrl(storeUrl, keyProviderUrl)
+	if err != nil {
+		panic(err)
+	}
+	return reg
+}
+
+// CreateClusterRegistryFromUrl creates a registry on the store of the url. If the key
+// provider url is not empty, the secrets and role files of saved clusters are encrypted.
+func CreateClusterRegistryFromUrl(storeUrl string, keyProviderUrl string) (*ClusterRegistry, error) {
+	s, err := store.CreateStoreFromUrl(storeUrl)
+	if err != nil {
+		return nil, err
+	}
 
+	var keyProvider cryptoutil.KeyProvider
+	if keyProviderUrl != "" {
+		if keyProvider, err = cryptoutil.CreateKeyProviderFromUrl(keyProviderUrl); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewClusterRegistryWithStore(s, keyProvider), nil
+}
+
+// NewClusterRegistryWithStore creates a registry on an existing store, clusters are saved
+// in plaintext if the key provider is nil
+func NewClusterRegistryWithStore(s store.Store, keyProvider cryptoutil.KeyProvider) *ClusterRegistry {
 	return &ClusterRegistry{
-		store: s,
+		store:    s,
+		envelope: cryptoutil.NewEnvelope(keyProvider),
 	}
 }
 
@@ -31,10 +69,45 @@ func makeClusterSpecPath(clusterName string) string {
 	return path.Join(clusterName, clusterSpecFile)
 }
 
+// makeClusterFilesPath returns the key of the files of a role in a revision, and of the
+// current files of clusters saved before role prefixes were introduced
 func makeClusterFilesPath(clusterName string, role string) string {
 	return path.Join(clusterName, "roles", fmt.Sprintf("%s.yaml", role))
 }
 
+// MakeClusterPrefix returns the key prefix of all data of a cluster, which only kaptain
+// needs access to
+func MakeClusterPrefix(clusterName string) string {
+	return clusterName
+}
+
+// MakeRolePrefix returns the key prefix of the current files of a role and of the nodes of
+// the role, which is all sailor on a node of the role needs access to
+func MakeRolePrefix(clusterName string, role string) string {
+	return path.Join(clusterName, "roles", role)
+}
+
+// MakeRoleFilesPath returns the key of the current files of a role or of a single node
+// (see MakeNodeRole), the files of a node are under the prefix of its role
+func MakeRoleFilesPath(clusterName string, role string) string {
+	if role, hostname, isNode := SplitNodeRole(role); isNode {
+		return path.Join(MakeRolePrefix(clusterName, role), "nodes", fmt.Sprintf("%s.yaml", hostname))
+	}
+	return path.Join(MakeRolePrefix(clusterName, role), roleFilesFile)
+}
+
+func makeRevisionsPath(clusterName string) string {
+	return path.Join(clusterName, "revisions")
+}
+
+func makeRevisionPath(clusterName string, number int) string {
+	return path.Join(makeRevisionsPath(clusterName), strconv.Itoa(number))
+}
+
+func makeCurrentRevisionPath(clusterName string) string {
+	return path.Join(clusterName, currentRevisionFile)
+}
+
 func (reg *ClusterRegistry) List() ([]string, error) {
 	log.Debug("Listing clusters")
 	clusterNames, err := reg.store.List("")
@@ -60,16 +133,16 @@ func (reg *ClusterRegistry) Get(clusterName string) (*Cluster, error) {
 		return nil, fmt.Errorf("failed to get cluster '%s': %v", clusterName, err)
 	}
 
-	cluster := Cluster{}
-	err = yaml.Unmarshal(data, &cluster)
+	cluster, err := reg.unmarshalCluster(data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse cluster spec '%s': %v", clusterName, err)
 	}
 
-	return &cluster, nil
+	return cluster, nil
 }
 
-func (reg *ClusterRegistry) Create(cluster *Cluster, force bool) error {
+// Create saves a new cluster with its role files as the first revision
+func (reg *ClusterRegistry) Create(cluster *Cluster, roleFiles map[string]*ClusterFiles, force bool) error {
 	clusterName := cluster.Name
 	if clusterName == "" {
 		return fmt.Errorf("cluster name cannot be empty")
@@ -86,22 +159,247 @@ func (reg *ClusterRegistry) Create(cluster *Cluster, force bool) error {
 
 	log.Debugf("Creating new cluster '%s'", cluster.Name)
 
+	change := "create"
+	if exists {
+		change = "overwrite"
+	}
+
+	if _, err := reg.Save(cluster, roleFiles, change); err != nil {
+		return fmt.Errorf("failed to create cluster '%s': %v", cluster.Name, err)
+	}
+
+	return nil
+}
+
+// Save stores the cluster and its role files as a new immutable revision, and makes it
+// the current revision
+func (reg *ClusterRegistry) Save(cluster *Cluster, roleFiles map[string]*ClusterFiles, change string) (*Revision, error) {
+	clusterName := cluster.Name
+	if clusterName == "" {
+		return nil, fmt.Errorf("cluster name cannot be empty")
+	}
+
+	latest, err := reg.getLatestRevisionNumber(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	revision := NewRevision(latest+1, change)
+	revisionPath := makeRevisionPath(clusterName, revision.Number)
+
+	log.Debugf("Saving revision %d of cluster '%s'", revision.Number, clusterName)
+
 	// prepare and write cluster spec
-	data, err := yaml.Marshal(cluster)
+	data, err := reg.marshalCluster(cluster)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("failed to serialise cluster '%s': %v", clusterName, err)
 	}
 
 	log.Debugf("Cluster: \n%s", string(data))
 
-	err = reg.store.Set(makeClusterSpecPath(cluster.Name), data)
+	// claim the revision number first, so a concurrent save of the same number fails instead
+	// of overwriting this revision
+	revisionData, err := yaml.Marshal(revision)
 	if err != nil {
-		return fmt.Errorf("failed to create cluster '%s': %v", cluster.Name, err)
+		return nil, fmt.Errorf("failed to serialise revision %d of cluster '%s': %v", revision.Number, clusterName, err)
+	}
+	if err := reg.createRevisionFile(clusterName, revision.Number, revisionData); err != nil {
+		return nil, err
+	}
+
+	// write the immutable revision first, so the current files only change once it is complete
+	if err := reg.store.Set(path.Join(revisionPath, clusterSpecFile), data); err != nil {
+		return nil, fmt.Errorf("failed to write revision %d of cluster '%s': %v", revision.Number, clusterName, err)
+	}
+	for role, clusterFiles := range roleFiles {
+		if err := reg.setFiles(makeClusterFilesPath(revisionPath, role), clusterFiles); err != nil {
+			return nil, fmt.Errorf("failed to write revision %d of cluster '%s': %v", revision.Number, clusterName, err)
+		}
+	}
+
+	// update the current cluster spec and role files, clusters saved before role prefixes
+	// were introduced keep the legacy files up to date too until they are migrated, for
+	// sailors that have not been upgraded yet
+	legacy, err := reg.HasLegacyFiles(clusterName)
+	if err != nil {
+		return nil, err
 	}
+	if err := reg.store.Set(makeClusterSpecPath(clusterName), data); err != nil {
+		return nil, fmt.Errorf("failed to write cluster '%s': %v", clusterName, err)
+	}
+	for role, clusterFiles := range roleFiles {
+		if err := reg.SetFiles(clusterName, role, clusterFiles); err != nil {
+			return nil, err
+		}
+		if !legacy {
+			continue
+		}
+		if err := reg.setFiles(makeClusterFilesPath(clusterName, role), clusterFiles); err != nil {
+			return nil, fmt.Errorf("failed to write legacy cluster files for %s: %v", role, err)
+		}
+	}
+	if err := reg.deleteStaleFiles(clusterName, roleFiles); err != nil {
+		return nil, err
+	}
+	if err := reg.store.Set(makeCurrentRevisionPath(clusterName), []byte(strconv.Itoa(revision.Number))); err != nil {
+		return nil, fmt.Errorf("failed to set current revision of cluster '%s': %v", clusterName, err)
+	}
+
+	return &revision, nil
+}
+
+// createRevisionFile writes the revision file of a new revision, and fails if the revision
+// exists already. Stores that cannot create a key atomically are only checked beforehand.
+func (reg *ClusterRegistry) createRevisionFile(clusterName string, number int, data []byte) error {
+	key := path.Join(makeRevisionPath(clusterName, number), revisionFile)
+	errExists := fmt.Errorf("revision %d of cluster '%s' was saved by another kaptain, please retry", number, clusterName)
 
+	if creator, ok := reg.store.(store.Creator); ok {
+		err := creator.Create(key, data)
+		if err == store.ErrExists {
+			return errExists
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write revision %d of cluster '%s': %v", number, clusterName, err)
+		}
+		return nil
+	}
+
+	exists, err := reg.store.Exists(key)
+	if err != nil {
+		return fmt.Errorf("failed to check revision %d of cluster '%s': %v", number, clusterName, err)
+	}
+	if exists {
+		return errExists
+	}
+	if err := reg.store.Set(key, data); err != nil {
+		return fmt.Errorf("failed to write revision %d of cluster '%s': %v", number, clusterName, err)
+	}
 	return nil
 }
 
+// History returns all revisions of the cluster, oldest first
+func (reg *ClusterRegistry) History(clusterName string) ([]Revision, error) {
+	log.Debugf("Getting history of cluster '%s'", clusterName)
+
+	numbers, err := reg.listRevisionNumbers(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := make([]Revision, len(numbers))
+	for i, n := range numbers {
+		data, err := reg.store.Get(path.Join(makeRevisionPath(clusterName, n), revisionFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get revision %d of cluster '%s': %v", n, clusterName, err)
+		}
+		if err := yaml.Unmarshal(data, &revisions[i]); err != nil {
+			return nil, fmt.Errorf("failed to parse revision %d of cluster '%s': %v", n, clusterName, err)
+		}
+	}
+
+	return revisions, nil
+}
+
+// CurrentRevision returns the number of the current revision of the cluster, or 0 if the
+// cluster was saved before revisions were introduced
+func (reg *ClusterRegistry) CurrentRevision(clusterName string) (int, error) {
+	exists, err := reg.store.Exists(makeCurrentRevisionPath(clusterName))
+	if err != nil {
+		return 0, fmt.Errorf("failed to check current revision of cluster '%s': %v", clusterName, err)
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	data, err := reg.store.Get(makeCurrentRevisionPath(clusterName))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get current revision of cluster '%s': %v", clusterName, err)
+	}
+
+	number, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse current revision of cluster '%s': %v", clusterName, err)
+	}
+
+	return number, nil
+}
+
+// GetRevision returns the cluster and its role files as saved in the given revision
+func (reg *ClusterRegistry) GetRevision(clusterName string, number int) (*Cluster, map[string]*ClusterFiles, error) {
+	log.Debugf("Getting revision %d of cluster '%s'", number, clusterName)
+	revisionPath := makeRevisionPath(clusterName, number)
+
+	data, err := reg.store.Get(path.Join(revisionPath, clusterSpecFile))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get revision %d of cluster '%s': %v", number, clusterName, err)
+	}
+
+	cluster, err := reg.unmarshalCluster(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse revision %d of cluster '%s': %v", number, clusterName, err)
+	}
+
+	roleFileNames, err := reg.store.List(path.Join(revisionPath, "roles"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list roles of revision %d of cluster '%s': %v", number, clusterName, err)
+	}
+
+	roleFiles := map[string]*ClusterFiles{}
+	for _, name := range roleFileNames {
+		role := strings.TrimSuffix(name, ".yaml")
+		clusterFiles, err := reg.getFiles(makeClusterFilesPath(revisionPath, role))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get role '%s' of revision %d of cluster '%s': %v", role, number, clusterName, err)
+		}
+		roleFiles[role] = clusterFiles
+	}
+
+	return cluster, roleFiles, nil
+}
+
+// Rollback restores the given revision by saving it again as a new revision
+func (reg *ClusterRegistry) Rollback(clusterName string, number int) (*Revision, error) {
+	log.Debugf("Rolling back cluster '%s' to revision %d", clusterName, number)
+
+	cluster, roleFiles, err := reg.GetRevision(clusterName, number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rollback cluster '%s': %v", clusterName, err)
+	}
+
+	return reg.Save(cluster, roleFiles, fmt.Sprintf("rollback to %d", number))
+}
+
+func (reg *ClusterRegistry) listRevisionNumbers(clusterName string) ([]int, error) {
+	names, err := reg.store.List(makeRevisionsPath(clusterName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revisions of cluster '%s': %v", clusterName, err)
+	}
+
+	numbers := make([]int, 0, len(names))
+	for _, name := range names {
+		n, err := strconv.Atoi(name)
+		if err != nil {
+			log.Warnf("Ignoring unknown revision '%s' of cluster '%s'", name, clusterName)
+			continue
+		}
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	return numbers, nil
+}
+
+func (reg *ClusterRegistry) getLatestRevisionNumber(clusterName string) (int, error) {
+	numbers, err := reg.listRevisionNumbers(clusterName)
+	if err != nil {
+		return 0, err
+	}
+	if len(numbers) == 0 {
+		return 0, nil
+	}
+	return numbers[len(numbers)-1], nil
+}
+
 func (reg *ClusterRegistry) Delete(clusterName string) error {
 	log.Debugf("Deleting cluster '%s'", clusterName)
 
@@ -114,29 +412,295 @@ func (reg *ClusterRegistry) Delete(clusterName string) error {
 
 func (reg *ClusterRegistry) GetFiles(clusterName string, role string) (*ClusterFiles, error) {
 	log.Debugf("Get cluster files for '%s' as role '%s'", clusterName, role)
-	data, err := reg.store.Get(makeClusterFilesPath(clusterName, role))
+	key, err := reg.findRoleFilesKey(clusterName, role)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cluster file for cluster '%s' and role '%s': %v", clusterName, role, err)
 	}
+	if key == "" {
+		key = MakeRoleFilesPath(clusterName, role)
+	}
 
-	var clusterFiles ClusterFiles
-	err = yaml.Unmarshal(data, &clusterFiles)
+	clusterFiles, err := reg.getFiles(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster file for cluster '%s' and role '%s': %v", clusterName, role, err)
+	}
 
-	return &clusterFiles, err
+	return clusterFiles, nil
 }
 
 func (reg *ClusterRegistry) SetFiles(clusterName string, role string, clusterFiles *ClusterFiles) error {
 	log.Debugf("Set cluster files for '%s' as role '%s'", clusterName, role)
 
-	data, err := yaml.Marshal(clusterFiles)
+	if err := reg.setFiles(MakeRoleFilesPath(clusterName, role), clusterFiles); err != nil {
+		return fmt.Errorf("failed to write cluster files for %s: %v", role, err)
+	}
+
+	return nil
+}
+
+// FilesExist returns true if the cluster has files for the role
+func (reg *ClusterRegistry) FilesExist(clusterName string, role string) (bool, error) {
+	key, err := reg.findRoleFilesKey(clusterName, role)
 	if err != nil {
-		panic(err)
+		return false, fmt.Errorf("failed to check cluster files for cluster '%s' and role '%s': %v", clusterName, role, err)
+	}
+	return key != "", nil
+}
+
+// GetFilesVersion returns a version of the files of the role that changes whenever the
+// files change, or an empty version if the role has no files. Stores without versions
+// are read to compute one.
+func (reg *ClusterRegistry) GetFilesVersion(clusterName string, role string) (string, error) {
+	key, err := reg.findRoleFilesKey(clusterName, role)
+	if err != nil || key == "" {
+		return "", err
 	}
 
-	err = reg.store.Set(makeClusterFilesPath(clusterName, role), data)
+	var version string
+	if versioner, ok := reg.store.(store.Versioner); ok {
+		version, err = versioner.GetVersion(key)
+	} else {
+		var data []byte
+		data, err = reg.store.Get(key)
+		version = Checksum(data)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to write cluster files for %s: %v", role, err)
+		return "", fmt.Errorf("failed to get version of cluster files for cluster '%s' and role '%s': %v", clusterName, role, err)
+	}
+	return fmt.Sprintf("%s@%s", key, version), nil
+}
+
+// findRoleFilesKey returns the key of the current files of a role, or an empty key if the
+// role has no files. Clusters saved before role prefixes were introduced have the files at
+// roles/<role>.yaml until they are saved again.
+func (reg *ClusterRegistry) findRoleFilesKey(clusterName string, role string) (string, error) {
+	key := MakeRoleFilesPath(clusterName, role)
+	exists, err := reg.listExists(key)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return key, nil
+	}
+
+	// sailor with access to the role prefix only cannot read the legacy key, which means
+	// the cluster has been saved with role prefixes already
+	legacyKey := makeClusterFilesPath(clusterName, role)
+	exists, err = reg.store.Exists(legacyKey)
+	if err != nil {
+		log.Debugf("Ignoring legacy cluster files of role '%s': %v", role, err)
+		return "", nil
+	}
+	if exists {
+		return legacyKey, nil
+	}
+	return "", nil
+}
+
+// listExists checks if a key exists by listing its parent. Unlike getting a missing key,
+// this only needs the list permission of the prefix (S3 denies a HEAD of a missing object
+// to callers that cannot list the whole bucket).
+func (reg *ClusterRegistry) listExists(key string) (bool, error) {
+	names, err := reg.store.List(path.Dir(key))
+	if err != nil {
+		return false, err
+	}
+	for _, name := range names {
+		if name == path.Base(key) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// HasLegacyFiles returns true if the cluster still has current files at roles/<role>.yaml,
+// as saved before role prefixes were introduced (see MigrateLayout)
+func (reg *ClusterRegistry) HasLegacyFiles(clusterName string) (bool, error) {
+	names, err := reg.store.List(path.Join(clusterName, "roles"))
+	if err != nil {
+		return false, fmt.Errorf("failed to list roles of cluster '%s': %v", clusterName, err)
+	}
+	for _, name := range names {
+		if strings.HasSuffix(name, ".yaml") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// MigrateLayout deletes the legacy files at roles/<role>.yaml of a cluster, which are kept
+// up to date by Save until every sailor reads the files under the role prefixes. It
+// returns the number of files deleted.
+func (reg *ClusterRegistry) MigrateLayout(clusterName string) (int, error) {
+	rolesPath := path.Join(clusterName, "roles")
+	names, err := reg.store.List(rolesPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list roles of cluster '%s': %v", clusterName, err)
+	}
+
+	deleted := 0
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".yaml") {
+			continue
+		}
+		role := strings.TrimSuffix(name, ".yaml")
+		exists, err := reg.listExists(MakeRoleFilesPath(clusterName, role))
+		if err != nil {
+			return deleted, fmt.Errorf("failed to check cluster files for %s: %v", role, err)
+		}
+		if !exists {
+			return deleted, fmt.Errorf("role '%s' of cluster '%s' has no files under its role prefix yet, save the cluster first (e.g. 'kaptain apply')", role, clusterName)
+		}
+
+		log.Debugf("Deleting legacy cluster files '%s'", name)
+		if err := reg.store.Delete(path.Join(rolesPath, name)); err != nil {
+			return deleted, fmt.Errorf("failed to delete cluster files %s: %v", name, err)
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// deleteStaleFiles deletes the current files of roles that are no longer rendered, such
+// as the files of a node removed from the cluster, in both layouts
+func (reg *ClusterRegistry) deleteStaleFiles(clusterName string, roleFiles map[string]*ClusterFiles) error {
+	rolesPath := path.Join(clusterName, "roles")
+	names, err := reg.store.List(rolesPath)
+	if err != nil {
+		return fmt.Errorf("failed to list roles of cluster '%s': %v", clusterName, err)
+	}
+
+	for _, name := range names {
+		if strings.HasSuffix(name, ".yaml") {
+			if _, exists := roleFiles[strings.TrimSuffix(name, ".yaml")]; exists {
+				continue
+			}
+			log.Debugf("Deleting legacy cluster files of removed role '%s'", name)
+			if err := reg.store.Delete(path.Join(rolesPath, name)); err != nil {
+				return fmt.Errorf("failed to delete cluster files %s: %v", name, err)
+			}
+			continue
+		}
+
+		role := name
+		if _, exists := roleFiles[role]; !exists {
+			log.Debugf("Deleting cluster files of removed role '%s'", role)
+			if err := reg.store.DeleteAll(MakeRolePrefix(clusterName, role)); err != nil {
+				return fmt.Errorf("failed to delete cluster files for %s: %v", role, err)
+			}
+			continue
+		}
+
+		nodesPath := path.Join(MakeRolePrefix(clusterName, role), "nodes")
+		nodeNames, err := reg.store.List(nodesPath)
+		if err != nil {
+			return fmt.Errorf("failed to list nodes of role '%s' of cluster '%s': %v", role, clusterName, err)
+		}
+		for _, nodeName := range nodeNames {
+			nodeRole := MakeNodeRole(role, strings.TrimSuffix(nodeName, ".yaml"))
+			if _, exists := roleFiles[nodeRole]; exists {
+				continue
+			}
+			log.Debugf("Deleting cluster files of removed node '%s'", nodeRole)
+			if err := reg.store.Delete(path.Join(nodesPath, nodeName)); err != nil {
+				return fmt.Errorf("failed to delete cluster files for %s: %v", nodeRole, err)
+			}
+		}
 	}
 
 	return nil
 }
+
+func (reg *ClusterRegistry) getFiles(key string) (*ClusterFiles, error) {
+	data, err := reg.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var clusterFiles ClusterFiles
+	if err := yaml.Unmarshal(data, &clusterFiles); err != nil {
+		return nil, err
+	}
+
+	// role files saved before encryption was enabled are in plaintext
+	if sealed := clusterFiles.Spec.EncryptedFiles; sealed != nil {
+		filesData, err := reg.envelope.Open(sealed)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(filesData, &clusterFiles.Spec.ClusterFiles); err != nil {
+			return nil, fmt.Errorf("failed to parse encrypted cluster files: %v", err)
+		}
+		clusterFiles.Spec.EncryptedFiles = nil
+	}
+
+	return &clusterFiles, nil
+}
+
+func (reg *ClusterRegistry) setFiles(key string, clusterFiles *ClusterFiles) error {
+	if reg.envelope.CanSeal() {
+		filesData, err := json.Marshal(clusterFiles.Spec.ClusterFiles)
+		if err != nil {
+			return fmt.Errorf("failed to serialise cluster files: %v", err)
+		}
+		sealed, err := reg.envelope.Seal(filesData)
+		if err != nil {
+			return err
+		}
+		sealedFiles := *clusterFiles
+		sealedFiles.Spec = ClusterFilesSpec{ClusterFiles: []*ClusterFile{}, EncryptedFiles: sealed}
+		clusterFiles = &sealedFiles
+	}
+
+	data, err := yaml.Marshal(clusterFiles)
+	if err != nil {
+		return fmt.Errorf("failed to serialise cluster files: %v", err)
+	}
+
+	return reg.store.Set(key, data)
+}
+
+// marshalCluster serialises the cluster, its secrets are sealed if the registry has a key
+// provider
+func (reg *ClusterRegistry) marshalCluster(cluster *Cluster) ([]byte, error) {
+	if !reg.envelope.CanSeal() {
+		return yaml.Marshal(cluster)
+	}
+
+	secretsData, err := json.Marshal(cluster.Secrets)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := reg.envelope.Seal(secretsData)
+	if err != nil {
+		return nil, err
+	}
+
+	sealedCluster := *cluster
+	sealedCluster.Secrets = ClusterSecrets{}
+	sealedCluster.EncryptedSecrets = sealed
+	return yaml.Marshal(&sealedCluster)
+}
+
+// unmarshalCluster parses a cluster and opens its secrets if they are sealed, clusters saved
+// before encryption was enabled have plaintext secrets
+func (reg *ClusterRegistry) unmarshalCluster(data []byte) (*Cluster, error) {
+	cluster := Cluster{}
+	if err := yaml.Unmarshal(data, &cluster); err != nil {
+		return nil, err
+	}
+
+	if sealed := cluster.EncryptedSecrets; sealed != nil {
+		secretsData, err := reg.envelope.Open(sealed)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(secretsData, &cluster.Secrets); err != nil {
+			return nil, fmt.Errorf("failed to parse encrypted secrets: %v", err)
+		}
+		cluster.EncryptedSecrets = nil
+	}
+
+	return &cluster, nil
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javefang/kaptain/pkg/store"
+	"github.com/javefang/kaptain/pkg/utils/cryptoutil"
+)
+
+const testToken = "s3cr3t-token"
+
+func makeTestKeyProvider(t *testing.T, dir string) cryptoutil.KeyProvider {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	keyFile := filepath.Join(dir, "cluster.key")
+	if err := ioutil.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(key)), 0600); err != nil {
+		t.Fatalf("failed to write key file: %v", err)
+	}
+	provider, err := cryptoutil.CreateKeyProviderFromUrl("file://" + keyFile)
+	if err != nil {
+		t.Fatalf("CreateKeyProviderFromUrl failed: %v", err)
+	}
+	return provider
+}
+
+// assertStored checks whether the stored value of the key holds the token in plaintext
+func assertStored(t *testing.T, s store.Store, key string, plaintext bool) {
+	data, err := s.Get(key)
+	if err != nil {
+		t.Fatalf("Get(%q) failed: %v", key, err)
+	}
+	stored := string(data)
+	hasToken := strings.Contains(stored, testToken) || strings.Contains(stored, base64.StdEncoding.EncodeToString([]byte(testToken)))
+	if hasToken != plaintext {
+		t.Errorf("%s holds the token in plaintext: %v, expected %v", key, hasToken, plaintext)
+	}
+}
+
+func TestClusterRegistrySealsPlaintextCluster(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registry-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := store.CreateStoreFromUrl("mem://registry-seal-test")
+	if err != nil {
+		t.Fatalf("CreateStoreFromUrl failed: %v", err)
+	}
+
+	cluster := NewCluster()
+	cluster.Name = "dev"
+	cluster.Secrets.TokenSecrets["admin"] = TokenSecret{Username: "admin", Token: testToken}
+	clusterFiles := NewClusterFiles()
+	clusterFiles.Spec.ClusterFiles = append(clusterFiles.Spec.ClusterFiles, NewClusterFile("/etc/kubernetes/token.csv", []byte(testToken)))
+	roleFiles := map[string]*ClusterFiles{"master": clusterFiles}
+
+	// a cluster saved before encryption was enabled
+	plain := NewClusterRegistryWithStore(s, nil)
+	if err := plain.Create(&cluster, roleFiles, false); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	assertStored(t, s, makeClusterSpecPath("dev"), true)
+	assertStored(t, s, MakeRoleFilesPath("dev", "master"), true)
+
+	// is read and saved again sealed
+	sealed := NewClusterRegistryWithStore(s, makeTestKeyProvider(t, dir))
+	read, err := sealed.Get("dev")
+	if err != nil {
+		t.Fatalf("Get of the plaintext cluster failed: %v", err)
+	}
+	readFiles, err := sealed.GetFiles("dev", "master")
+	if err != nil {
+		t.Fatalf("GetFiles of the plaintext cluster failed: %v", err)
+	}
+	if _, err := sealed.Save(read, map[string]*ClusterFiles{"master": readFiles}, "encrypt"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	for _, key := range []string{
+		makeClusterSpecPath("dev"),
+		MakeRoleFilesPath("dev", "master"),
+		path.Join(makeRevisionPath("dev", 2), clusterSpecFile),
+		makeClusterFilesPath(makeRevisionPath("dev", 2), "master"),
+	} {
+		assertStored(t, s, key, false)
+	}
+
+	// the sealed cluster opens with the key only
+	opened, err := sealed.Get("dev")
+	if err != nil {
+		t.Fatalf("Get of the sealed cluster failed: %v", err)
+	}
+	if token := opened.Secrets.TokenSecrets["admin"].Token; token != testToken {
+		t.Errorf("token of the sealed cluster is %q, expected %q", token, testToken)
+	}
+	openedFiles, err := sealed.GetFiles("dev", "master")
+	if err != nil {
+		t.Fatalf("GetFiles of the sealed cluster failed: %v", err)
+	}
+	if len(openedFiles.Spec.ClusterFiles) != 1 {
+		t.Fatalf("sealed cluster has %d files, expected 1", len(openedFiles.Spec.ClusterFiles))
+	}
+	if data, _ := openedFiles.Spec.ClusterFiles[0].GetVerifiedData(); string(data) != testToken {
+		t.Errorf("file of the sealed cluster is %q, expected %q", data, testToken)
+	}
+
+	if _, err := plain.Get("dev"); err == nil || !strings.Contains(err.Error(), "an encryption key is required") {
+		t.Errorf("Get without a key returned %v, expected an error asking for the key", err)
+	}
+}
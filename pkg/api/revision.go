@@ -0,0 +1,43 @@
+package api
+
+import (
+	"os/user"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"github.com/javefang/kaptain/pkg/version"
+)
+
+// Revision describes an immutable revision of a cluster, saved each time the cluster changes
+type Revision struct {
+	metav1.TypeMeta `json:",inline"`
+
+	Number         int       `json:"number"`
+	Timestamp      time.Time `json:"timestamp"`
+	KaptainVersion string    `json:"kaptainVersion"`
+	User           string    `json:"user"`
+	Change         string    `json:"change"` // short description of the change, e.g. "create"
+}
+
+// NewRevision creates a new Revision object made by the current user
+func NewRevision(number int, change string) Revision {
+	rev := Revision{}
+	rev.Kind = "Revision"
+	rev.APIVersion = "v1"
+
+	rev.Number = number
+	rev.Timestamp = time.Now().UTC()
+	rev.KaptainVersion = version.GetVersion().Version
+	rev.User = getCurrentUser()
+	rev.Change = change
+
+	return rev
+}
+
+func getCurrentUser() string {
+	usr, err := user.Current()
+	if err != nil {
+		return "unknown"
+	}
+	return usr.Username
+}
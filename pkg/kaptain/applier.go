@@ -0,0 +1,199 @@
+package kaptain
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/javefang/kaptain/pkg/api"
+)
+
+// ApplyOptions defines options to apply a spec change to an existing cluster
+type ApplyOptions struct {
+	// ReissueCerts allows changes that invalidate the SANs of existing certs, the
+	// affected certs will be reissued by the existing CAs
+	ReissueCerts bool
+}
+
+// specDerivedCerts are the certs whose SANs are derived from the cluster spec
+var specDerivedCerts = map[string]func(cluster *api.Cluster) []string{
+	"etcd-server": makeEtcdServerAltNames,
+	"etcd-peer":   makeEtcdServerAltNames,
+	"kubernetes":  makeKubernetesAltNames,
+}
+
+// prepareApply returns the cluster that results from applying the desired spec to the
+// existing cluster, with missing PKIs, tokens and asset manifest inflated. A dry run only
+// marks the missing certs and keys as would be issued.
+func prepareApply(existing *api.Cluster, desired *api.Cluster, opts *ApplyOptions, dryRun bool) (*api.Cluster, error) {
+	cluster, err := mergeCluster(existing, desired)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkCertChanges(cluster, opts.ReissueCerts); err != nil {
+		return nil, err
+	}
+
+	updateAssetManifest, err := needsAssetManifestUpdate(existing, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	inflateOptions := InflateClusterOptions{
+		UpdateSpec:          true,
+		UpdatePKIs:          true,
+		UpdateTokens:        true,
+		UpdateAssetManifest: updateAssetManifest,
+		DryRun:              dryRun,
+	}
+	if err := InflateCluster(cluster, &inflateOptions); err != nil {
+		return nil, err
+	}
+
+	return cluster, nil
+}
+
+// mergeCluster returns a copy of the existing cluster with the spec of the desired cluster.
+// Existing PKIs and tokens are always kept, secrets only in the desired cluster are added.
+func mergeCluster(existing *api.Cluster, desired *api.Cluster) (*api.Cluster, error) {
+	if existing.Name != desired.Name {
+		return nil, fmt.Errorf("cluster name cannot be changed from '%s' to '%s'", existing.Name, desired.Name)
+	}
+
+	merged := *existing
+	merged.Spec = desired.Spec
+
+	// use the asset manifest in the desired cluster if there is one
+	if len(desired.AssetManifest.Files) > 0 {
+		merged.AssetManifest = desired.AssetManifest
+	}
+
+	merged.Secrets.PKIs = map[string]api.CertPair{}
+	for k, v := range desired.Secrets.PKIs {
+		merged.Secrets.PKIs[k] = v
+	}
+	for k, v := range existing.Secrets.PKIs {
+		merged.Secrets.PKIs[k] = v
+	}
+
+	merged.Secrets.TokenSecrets = map[string]api.TokenSecret{}
+	for k, v := range desired.Secrets.TokenSecrets {
+		merged.Secrets.TokenSecrets[k] = v
+	}
+	for k, v := range existing.Secrets.TokenSecrets {
+		merged.Secrets.TokenSecrets[k] = v
+	}
+
+	merged.Secrets.BootstrapTokens = map[string]api.BootstrapToken{}
+	for k, v := range desired.Secrets.BootstrapTokens {
+		merged.Secrets.BootstrapTokens[k] = v
+	}
+	for k, v := range existing.Secrets.BootstrapTokens {
+		merged.Secrets.BootstrapTokens[k] = v
+	}
+
+	merged.Secrets.KeyPairs = map[string]api.KeyPair{}
+	for k, v := range desired.Secrets.KeyPairs {
+		merged.Secrets.KeyPairs[k] = v
+	}
+	for k, v := range existing.Secrets.KeyPairs {
+		merged.Secrets.KeyPairs[k] = v
+	}
+
+	return &merged, nil
+}
+
+// needsAssetManifestUpdate returns true if the asset manifest should be replaced by the
+// default one of the cluster's Kubernetes version
+func needsAssetManifestUpdate(existing *api.Cluster, merged *api.Cluster) (bool, error) {
+	if len(merged.AssetManifest.Files) == 0 {
+		return true, nil
+	}
+
+	oldVersion, err := getMajorMinorVersion(existing.Spec.KubeVersion)
+	if err != nil {
+		return false, err
+	}
+	newVersion, err := getMajorMinorVersion(merged.Spec.KubeVersion)
+	if err != nil {
+		return false, err
+	}
+
+	// keep a custom asset manifest given together with the version change
+	return oldVersion != newVersion && reflect.DeepEqual(merged.AssetManifest, existing.AssetManifest), nil
+}
+
+// checkCertChanges verifies the SANs of existing certs still match the cluster spec. Certs
+// that no longer match are removed (so they get reissued) if reissue is true, otherwise
+// an error is returned.
+func checkCertChanges(cluster *api.Cluster, reissue bool) error {
+	expectedAltNames := map[string][]string{}
+	for name, makeAltNames := range specDerivedCerts {
+		expectedAltNames[name] = makeAltNames(cluster)
+	}
+	for _, spec := range makeNodeCertSpecs(cluster) {
+		expectedAltNames[spec.name] = spec.makeCsr(cluster).AltNames
+	}
+
+	names := make([]string, 0, len(expectedAltNames))
+	for name := range expectedAltNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	invalidCerts := []string{}
+	for _, name := range names {
+		certPair, exists := cluster.Secrets.PKIs[name]
+		if !exists {
+			continue
+		}
+
+		certCombo, err := makeCertCombo(&certPair)
+		if err != nil {
+			return fmt.Errorf("invalid cert %s: %v", name, err)
+		}
+		cert := certCombo.Cert
+		actual := getCertAltNames(cert.DNSNames, cert.IPAddresses)
+		expected := expectedAltNames[name]
+		if equalAltNames(actual, expected) {
+			continue
+		}
+
+		if reissue {
+			log.Infof("SANs of cert '%s' changed, it will be reissued", name)
+			delete(cluster.Secrets.PKIs, name)
+		} else {
+			invalidCerts = append(invalidCerts, name)
+		}
+	}
+
+	if len(invalidCerts) > 0 {
+		return fmt.Errorf("the change invalidates the SANs of cert %s (use --reissue-certs to reissue them)", strings.Join(invalidCerts, ", "))
+	}
+
+	return nil
+}
+
+func getCertAltNames(dnsNames []string, ips []net.IP) []string {
+	altNames := append([]string{}, dnsNames...)
+	for _, ip := range ips {
+		altNames = append(altNames, ip.String())
+	}
+	return altNames
+}
+
+func equalAltNames(a []string, b []string) bool {
+	setA := map[string]bool{}
+	for _, n := range a {
+		setA[n] = true
+	}
+	setB := map[string]bool{}
+	for _, n := range b {
+		setB[n] = true
+	}
+	return reflect.DeepEqual(setA, setB)
+}
@@ -0,0 +1,83 @@
+package kaptain
+
+import (
+	"io"
+	"sort"
+
+	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/utils/secretutil"
+)
+
+// tokenGeneratorAnnotation is set to secureTokenGenerator on clusters whose tokens were
+// all made with crypto/rand. Older versions seeded math/rand with the creation time, so
+// their tokens can be predicted. The version annotation cannot tell them apart, because
+// development builds leave it empty.
+const tokenGeneratorAnnotation = "token-generator"
+const secureTokenGenerator = "crypto/rand"
+
+// TokenAudit describes whether the tokens of a cluster can be predicted
+type TokenAudit struct {
+	Cluster        string
+	KaptainVersion string // version of kaptain that created the cluster
+	TokenGenerator string
+	Weak           bool
+}
+
+func auditTokens(cluster *api.Cluster) TokenAudit {
+	generator := cluster.GetAnnotation(tokenGeneratorAnnotation)
+	return TokenAudit{
+		Cluster:        cluster.Name,
+		KaptainVersion: cluster.GetAnnotation("version"),
+		TokenGenerator: generator,
+		Weak:           generator != secureTokenGenerator && len(cluster.Secrets.TokenSecrets) > 0,
+	}
+}
+
+// regenerateTokens replaces every token of the cluster with a new one made by crypto/rand,
+// keeping the user, UID and groups of each token
+func regenerateTokens(cluster *api.Cluster) error {
+	names := make([]string, 0, len(cluster.Secrets.TokenSecrets))
+	for name := range cluster.Secrets.TokenSecrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		token, err := secretutil.MakeRandomToken(defaultTokenLength)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Regenerating token: %s", name)
+		tokenSecret := cluster.Secrets.TokenSecrets[name]
+		tokenSecret.Token = token
+		cluster.Secrets.TokenSecrets[name] = tokenSecret
+	}
+
+	cluster.SetAnnotation(tokenGeneratorAnnotation, secureTokenGenerator)
+
+	return nil
+}
+
+func printTokenAudits(audits []TokenAudit, out io.Writer) {
+	data := make([][]string, len(audits))
+	for i, a := range audits {
+		status := "ok"
+		if a.Weak {
+			status = "weak"
+		}
+		generator := a.TokenGenerator
+		if generator == "" {
+			generator = "math/rand"
+		}
+		data[i] = []string{a.Cluster, a.KaptainVersion, generator, status}
+	}
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"Cluster", "Created By", "Token Generator", "Status"})
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(false)
+	table.AppendBulk(data)
+	table.Render()
+}
@@ -2,9 +2,14 @@ package kaptain
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
 	"github.com/javefang/kaptain/pkg/api"
 	"github.com/javefang/kaptain/pkg/utils/kubeutil"
 )
@@ -25,30 +30,288 @@ func (client *KaptainClient) List() error {
 }
 
 func (client *KaptainClient) Create(cluster *api.Cluster, force bool) error {
-	// TODO: check if cluster exists
+	roleFiles, err := createAllFilesFromClusterSpec(cluster)
+	if err != nil {
+		return err
+	}
+
+	// write cluster spec and cluster files
+	return client.Registry.Create(cluster, roleFiles, force)
+}
+
+// Apply updates the spec of an existing cluster and re-renders all cluster files. Existing
+// PKIs and tokens are kept, missing ones are generated.
+func (client *KaptainClient) Apply(desired *api.Cluster, opts *ApplyOptions) error {
+	existing, err := client.Registry.Get(desired.Name)
+	if err != nil {
+		return fmt.Errorf("failed to read cluster: %v", err)
+	}
+
+	cluster, err := prepareApply(existing, desired, opts, false)
+	if err != nil {
+		return fmt.Errorf("failed to apply cluster '%s': %v", desired.Name, err)
+	}
+
+	return client.save(cluster, "apply")
+}
+
+// Diff prints the differences between the current cluster and the cluster that applying
+// the desired spec would result in. It returns true if there is any difference.
+func (client *KaptainClient) Diff(desired *api.Cluster, opts *ApplyOptions, out io.Writer) (bool, error) {
+	existing, err := client.Registry.Get(desired.Name)
+	if err != nil {
+		return false, fmt.Errorf("failed to read cluster: %v", err)
+	}
+
+	// nothing is issued, certs and keys missing in the existing cluster are diffed as would be issued
+	cluster, err := prepareApply(existing, desired, opts, true)
+	if err != nil {
+		return false, fmt.Errorf("failed to diff cluster '%s': %v", desired.Name, err)
+	}
+
+	return diffClusters(existing, cluster, "current", "desired", out)
+}
+
+// DiffRevisions prints the differences between two revisions of the cluster. It returns
+// true if there is any difference.
+func (client *KaptainClient) DiffRevisions(clusterName string, from int, to int, out io.Writer) (bool, error) {
+	fromCluster, fromRoleFiles, err := client.Registry.GetRevision(clusterName, from)
+	if err != nil {
+		return false, err
+	}
+
+	toCluster, toRoleFiles, err := client.Registry.GetRevision(clusterName, to)
+	if err != nil {
+		return false, err
+	}
+
+	// the role files as saved, which rollback restores, not as rendered by the current templates
+	return diffClustersWithFiles(fromCluster, toCluster, fromRoleFiles, toRoleFiles, fmt.Sprintf("revision-%d", from), fmt.Sprintf("revision-%d", to), out)
+}
+
+// History prints all revisions of the cluster
+func (client *KaptainClient) History(clusterName string) error {
+	revisions, err := client.Registry.History(clusterName)
+	if err != nil {
+		return fmt.Errorf("failed to get history: %v", err)
+	}
+
+	current, err := client.Registry.CurrentRevision(clusterName)
+	if err != nil {
+		return fmt.Errorf("failed to get history: %v", err)
+	}
+
+	printRevisions(revisions, current)
+
+	return nil
+}
+
+// Rollback restores a previous revision of the cluster as a new revision
+func (client *KaptainClient) Rollback(clusterName string, number int) error {
+	revision, err := client.Registry.Rollback(clusterName, number)
+	if err != nil {
+		return err
+	}
 
-	// write cluster spec
-	if err := client.Registry.Create(cluster, force); err != nil {
+	log.Infof("Cluster '%s' rolled back to revision %d (new revision %d)", clusterName, number, revision.Number)
+
+	return nil
+}
+
+// MigrateLayout deletes the role files of a cluster saved before role prefixes were
+// introduced, once every sailor of the cluster has been upgraded
+func (client *KaptainClient) MigrateLayout(clusterName string) error {
+	exists, err := client.Registry.Exists(clusterName)
+	if err != nil {
 		return err
 	}
+	if !exists {
+		return fmt.Errorf("cluster '%s' not found", clusterName)
+	}
 
-	// write cluster files
-	for _, role := range []string{"etcd", "master", "worker", "bootstrapper"} {
-		clusterFiles, err := createFilesFromClusterSpec(role, cluster)
+	deleted, err := client.Registry.MigrateLayout(clusterName)
+	if err != nil {
+		return fmt.Errorf("failed to migrate layout of cluster '%s': %v", clusterName, err)
+	}
+
+	log.Infof("Deleted %d legacy role files of cluster '%s'", deleted, clusterName)
+	return nil
+}
+
+// RotateCerts reissues the named leaf certs with the existing CAs, or all leaf certs if
+// no name is given
+func (client *KaptainClient) RotateCerts(clusterName string, certNames []string) error {
+	cluster, err := client.Registry.Get(clusterName)
+	if err != nil {
+		return fmt.Errorf("failed to read cluster: %v", err)
+	}
+
+	if len(certNames) == 0 {
+		certNames = getLeafCertNames(cluster)
+	}
+
+	if err := rotateLeafCerts(cluster, certNames); err != nil {
+		return fmt.Errorf("failed to rotate certs of cluster '%s': %v", clusterName, err)
+	}
+
+	return client.save(cluster, fmt.Sprintf("rotate-certs %s", strings.Join(certNames, ",")))
+}
+
+// RotateCA runs a stage of the rotation of a CA (see CARotationTrust, CARotationSign and
+// CARotationFinish)
+func (client *KaptainClient) RotateCA(clusterName string, caName string, stage string) error {
+	cluster, err := client.Registry.Get(clusterName)
+	if err != nil {
+		return fmt.Errorf("failed to read cluster: %v", err)
+	}
+
+	if err := rotateCA(cluster, caName, stage); err != nil {
+		return fmt.Errorf("failed to rotate CA of cluster '%s': %v", clusterName, err)
+	}
+
+	return client.save(cluster, fmt.Sprintf("rotate-certs --ca %s --stage %s", caName, stage))
+}
+
+// Certs prints the details and checks of every cert of the cluster. It returns the worst
+// status of all certs (see CertStatusOK, CertStatusWarn and CertStatusFail).
+func (client *KaptainClient) Certs(clusterName string, opts *CertsOptions, out io.Writer) (string, error) {
+	cluster, err := client.Registry.Get(clusterName)
+	if err != nil {
+		return "", fmt.Errorf("failed to read cluster: %v", err)
+	}
+
+	report := inspectCerts(cluster, opts, time.Now())
+	if err := printCertsReport(report, opts.Output, out); err != nil {
+		return "", err
+	}
+
+	return report.Status, nil
+}
+
+// AuditTokens prints whether the tokens of the named clusters, or of all clusters if no
+// name is given, can be predicted. It returns true if any cluster has weak tokens.
+func (client *KaptainClient) AuditTokens(clusterNames []string, out io.Writer) (bool, error) {
+	if len(clusterNames) == 0 {
+		names, err := client.Registry.List()
 		if err != nil {
-			return fmt.Errorf("Failed to render cluster files for %s: %v", role, err)
+			return false, fmt.Errorf("failed to list clusters: %v", err)
 		}
+		clusterNames = names
+	}
 
-		err = client.Registry.SetFiles(cluster.Name, role, clusterFiles)
+	weak := false
+	audits := make([]TokenAudit, len(clusterNames))
+	for i, name := range clusterNames {
+		cluster, err := client.Registry.Get(name)
 		if err != nil {
-			return fmt.Errorf("failed to write cluster files for %s: %v", role, err)
+			return false, fmt.Errorf("failed to read cluster '%s': %v", name, err)
 		}
+		audits[i] = auditTokens(cluster)
+		weak = weak || audits[i].Weak
+	}
+
+	printTokenAudits(audits, out)
+
+	return weak, nil
+}
+
+// RegenerateTokens replaces all tokens of the cluster and re-renders the files using them
+// (token.csv on masters and bootstrap.kubeconfig on workers)
+func (client *KaptainClient) RegenerateTokens(clusterName string) error {
+	cluster, err := client.Registry.Get(clusterName)
+	if err != nil {
+		return fmt.Errorf("failed to read cluster: %v", err)
+	}
+
+	if err := regenerateTokens(cluster); err != nil {
+		return fmt.Errorf("failed to regenerate tokens of cluster '%s': %v", clusterName, err)
+	}
+
+	if err := client.save(cluster, "regenerate-tokens"); err != nil {
+		return err
+	}
+
+	log.Warnf("Kubeconfigs exported with 'kaptain export-config' must be exported again")
+
+	return nil
+}
+
+// ListUsers prints the token users of the cluster, without their tokens
+func (client *KaptainClient) ListUsers(clusterName string, out io.Writer) error {
+	cluster, err := client.Registry.Get(clusterName)
+	if err != nil {
+		return fmt.Errorf("failed to read cluster: %v", err)
+	}
+
+	printUsers(cluster.Secrets.TokenSecrets, out)
+
+	return nil
+}
+
+// AddUser adds a token user to the cluster. A free UID is picked if uid is 0.
+func (client *KaptainClient) AddUser(clusterName string, username string, uid int, groups []string) error {
+	cluster, err := client.Registry.Get(clusterName)
+	if err != nil {
+		return fmt.Errorf("failed to read cluster: %v", err)
+	}
+
+	if err := addUser(cluster, username, uid, groups); err != nil {
+		return fmt.Errorf("failed to add user to cluster '%s': %v", clusterName, err)
 	}
 
+	return client.save(cluster, fmt.Sprintf("user add %s", username))
+}
+
+// RemoveUser removes a token user from the cluster
+func (client *KaptainClient) RemoveUser(clusterName string, username string) error {
+	cluster, err := client.Registry.Get(clusterName)
+	if err != nil {
+		return fmt.Errorf("failed to read cluster: %v", err)
+	}
+
+	if err := removeUser(cluster, username); err != nil {
+		return fmt.Errorf("failed to remove user from cluster '%s': %v", clusterName, err)
+	}
+
+	return client.save(cluster, fmt.Sprintf("user remove %s", username))
+}
+
+// RotateUserToken replaces the token of a user of the cluster
+func (client *KaptainClient) RotateUserToken(clusterName string, username string) error {
+	cluster, err := client.Registry.Get(clusterName)
+	if err != nil {
+		return fmt.Errorf("failed to read cluster: %v", err)
+	}
+
+	if err := rotateUserToken(cluster, username); err != nil {
+		return fmt.Errorf("failed to rotate token of cluster '%s': %v", clusterName, err)
+	}
+
+	if err := client.save(cluster, fmt.Sprintf("user rotate %s", username)); err != nil {
+		return err
+	}
+
+	log.Warnf("Kubeconfigs of '%s' exported with 'kaptain export-config' must be exported again", username)
+
 	return nil
 }
 
-// TODO: create func (client *KaptainClient) Apply(cluster *api.Cluster) error
+// save renders the cluster files of all roles and saves them with the cluster as a new revision
+func (client *KaptainClient) save(cluster *api.Cluster, change string) error {
+	roleFiles, err := createAllFilesFromClusterSpec(cluster)
+	if err != nil {
+		return err
+	}
+
+	revision, err := client.Registry.Save(cluster, roleFiles, change)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Cluster '%s' saved as revision %d", cluster.Name, revision.Number)
+
+	return nil
+}
 
 func (client *KaptainClient) Delete(clusterName string) error {
 	// TODO: check if cluster exists
@@ -87,6 +350,127 @@ func (client *KaptainClient) ExportConfig(clusterName string, kubeConfigFilePath
 	return nil
 }
 
+// ExportClientCertConfig signs a new client cert for the user with kube-ca, records it in
+// the cluster as a new revision and exports a kubeconfig using it
+func (client *KaptainClient) ExportClientCertConfig(clusterName string, kubeConfigFilePath string, username string, groups []string, validFor time.Duration, overwrite bool) error {
+	cluster, err := client.Registry.Get(clusterName)
+	if err != nil {
+		return fmt.Errorf("failed to read cluster: %v", err)
+	}
+
+	// the key of an issued cert is only kept in the kubeconfig, so check it can be exported first
+	if err := kubeutil.CheckMergeKubeConfig(cluster, kubeConfigFilePath, username, overwrite); err != nil {
+		return fmt.Errorf("failed to export cluster config: %v", err)
+	}
+
+	cert, err := issueClientCert(cluster, username, groups, validFor)
+	if err != nil {
+		return fmt.Errorf("failed to issue client cert: %v", err)
+	}
+	keyData, err := cert.ExtractKeyData()
+	if err != nil {
+		return fmt.Errorf("failed to issue client cert: %v", err)
+	}
+
+	// record the cert before handing it out, so every issued cert can be listed
+	if err := client.save(cluster, fmt.Sprintf("export-config --auth=cert --user %s", username)); err != nil {
+		return err
+	}
+
+	err = kubeutil.ExportClientCertKubeConfig(cluster, kubeConfigFilePath, username, cert.ExtractCertData(), keyData, overwrite)
+	if err != nil {
+		return fmt.Errorf("failed to export cluster config: %v", err)
+	}
+
+	log.Infof("Client cert of '%s' expires at %s", username, cert.Cert.NotAfter.UTC().Format(time.RFC3339))
+
+	return nil
+}
+
+// ListClientCerts prints the client certs issued to users of the cluster
+func (client *KaptainClient) ListClientCerts(clusterName string, out io.Writer) error {
+	cluster, err := client.Registry.Get(clusterName)
+	if err != nil {
+		return fmt.Errorf("failed to read cluster: %v", err)
+	}
+
+	printClientCerts(cluster.Secrets.ClientCerts, time.Now(), out)
+
+	return nil
+}
+
+// ListBootstrapTokens prints the bootstrap tokens of the cluster, without their secrets
+func (client *KaptainClient) ListBootstrapTokens(clusterName string, out io.Writer) error {
+	cluster, err := client.Registry.Get(clusterName)
+	if err != nil {
+		return fmt.Errorf("failed to read cluster: %v", err)
+	}
+
+	printBootstrapTokens(cluster.Secrets.BootstrapTokens, time.Now(), out)
+
+	return nil
+}
+
+// CreateBootstrapToken adds a bootstrap token expiring after ttl to the cluster and creates
+// its secret in the running cluster. It returns the token in the <id>.<secret> form.
+func (client *KaptainClient) CreateBootstrapToken(clusterName string, ttl time.Duration, description string) (string, error) {
+	cluster, err := client.Registry.Get(clusterName)
+	if err != nil {
+		return "", fmt.Errorf("failed to read cluster: %v", err)
+	}
+
+	token, err := createBootstrapToken(cluster, ttl, description, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("failed to create bootstrap token for cluster '%s': %v", clusterName, err)
+	}
+
+	if err := client.save(cluster, fmt.Sprintf("token create %s", token.ID)); err != nil {
+		return "", err
+	}
+
+	addonFiles, err := client.Registry.GetFiles(clusterName, "bootstrapper")
+	if err != nil {
+		return "", fmt.Errorf("failed to get addon files for %s: %v", clusterName, err)
+	}
+	for _, file := range addonFiles.Spec.ClusterFiles {
+		if file.Path != "bootstrap-tokens" {
+			continue
+		}
+		data, err := file.GetData()
+		if err != nil {
+			return "", fmt.Errorf("failed to deserialise data for %s: %v", file.Path, err)
+		}
+		if err := kubeutil.KubeApply(clusterName, file.Path, data); err != nil {
+			return "", fmt.Errorf("bootstrap token saved but not created in the cluster, run 'kaptain bootstrap': %v", err)
+		}
+	}
+
+	return token.Token(), nil
+}
+
+// RevokeBootstrapToken removes a bootstrap token from the cluster and deletes its secret
+// from the running cluster
+func (client *KaptainClient) RevokeBootstrapToken(clusterName string, id string) error {
+	cluster, err := client.Registry.Get(clusterName)
+	if err != nil {
+		return fmt.Errorf("failed to read cluster: %v", err)
+	}
+
+	if err := revokeBootstrapToken(cluster, id); err != nil {
+		return fmt.Errorf("failed to revoke bootstrap token of cluster '%s': %v", clusterName, err)
+	}
+
+	if err := client.save(cluster, fmt.Sprintf("token revoke %s", id)); err != nil {
+		return err
+	}
+
+	if err := kubeutil.KubeDelete(clusterName, "secret", "kube-system", "bootstrap-token-"+id); err != nil {
+		return fmt.Errorf("bootstrap token removed but not deleted from the cluster, delete secret 'kube-system/bootstrap-token-%s': %v", id, err)
+	}
+
+	return nil
+}
+
 func (client *KaptainClient) Bootstrap(clusterName string) error {
 	// TODO: check if cluster exists
 
@@ -98,6 +482,23 @@ func (client *KaptainClient) Bootstrap(clusterName string) error {
 	return bootstrap(clusterName, addonFiles)
 }
 
+func printRevisions(revisions []api.Revision, current int) {
+	data := make([][]string, len(revisions))
+	for i, r := range revisions {
+		number := strconv.Itoa(r.Number)
+		if r.Number == current {
+			number += " (current)"
+		}
+		data[i] = []string{number, r.Timestamp.Format(time.RFC3339), r.KaptainVersion, r.User, r.Change}
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Revision", "Timestamp", "Kaptain Version", "User", "Change"})
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(false)
+	table.AppendBulk(data)
+	table.Render()
+}
+
 func printClusterNames(clusters []string) {
 	data := make([][]string, len(clusters))
 	for i, c := range clusters {
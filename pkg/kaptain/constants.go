@@ -1,12 +1,19 @@
 package kaptain
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 const (
 	// role=etcd
 	EtcdCACert     = "etc/pki/tls/certs/etcd-ca.pem"
 	EtcdServerCert = "etc/pki/tls/certs/etcd-server.pem"
 	EtcdServerKey  = "etc/pki/tls/private/etcd-server-key.pem"
+	EtcdPeerCert   = "etc/pki/tls/certs/etcd-peer.pem"
+	EtcdPeerKey    = "etc/pki/tls/private/etcd-peer-key.pem"
+
+	EtcdMemberConfig = "etc/etcd/%s.conf.yaml" // formatted with the member hostname
 
 	// role=master|worker
 	DockerDaemonConfig        = "etc/docker/daemon.json"
@@ -21,11 +28,19 @@ const (
 	KubeEtcdClientKey           = "var/lib/kubernetes/etcd-client-key.pem"
 	KubeCACert                  = "var/lib/kubernetes/ca.pem"
 	KubeCAKey                   = "var/lib/kubernetes/ca-key.pem"
+	KubeCASigningCert           = "var/lib/kubernetes/ca-signing.pem"
+	KubeFrontProxyCACert        = "var/lib/kubernetes/front-proxy-ca.pem"
+	KubeFrontProxyClientCert    = "var/lib/kubernetes/front-proxy-client.pem"
+	KubeFrontProxyClientKey     = "var/lib/kubernetes/front-proxy-client-key.pem"
+	KubeServiceAccountKey       = "var/lib/kubernetes/service-account-key.pem"
+	KubeServiceAccountPublicKey = "var/lib/kubernetes/service-account.pem"
 	KubeCert                    = "var/lib/kubernetes/kubernetes.pem"
 	KubeKey                     = "var/lib/kubernetes/kubernetes-key.pem"
 	KubeTokenCsv                = "var/lib/kubernetes/token.csv"
 	KubeBasicAuthCsv            = "var/lib/kubernetes/basic_auth.csv"
 	KubeletConfig               = "var/lib/kubelet/kubeconfig"
+	KubeletServerCert           = "var/lib/kubelet/kubelet-server.pem"
+	KubeletServerKey            = "var/lib/kubelet/kubelet-server-key.pem"
 	KubeControllerManagerConfig = "var/lib/kubernetes/kube-controller-manager.kubeconfig"
 	KubeSchedulerConfig         = "var/lib/kubernetes/kube-scheduler.kubeconfig"
 	KubeCloudConfig             = "var/lib/kubernetes/cloud.conf"
@@ -40,6 +55,82 @@ const (
 	KubeletBootstrapConfig       = "var/lib/kubelet/bootstrap.kubeconfig"
 )
 
+// commands that make the service of a file pick up a change made by sailor agent. Files
+// without a command are picked up by their service, such as the static pod manifests
+// watched by the kubelet.
+var reloadCommands = map[string]string{
+	EtcdCACert:       "systemctl restart etcd",
+	EtcdServerCert:   "systemctl restart etcd",
+	EtcdServerKey:    "systemctl restart etcd",
+	EtcdPeerCert:     "systemctl restart etcd",
+	EtcdPeerKey:      "systemctl restart etcd",
+	EtcdMemberConfig: "systemctl restart etcd",
+
+	DockerDaemonConfig: "systemctl restart docker",
+	SysconfigDocker:    "systemctl restart docker",
+
+	SysconfigKubeletKaptain:      "systemctl restart kubelet",
+	SysconfigKubeletKaptainExtra: "systemctl restart kubelet",
+	KubeletConfig:                "systemctl restart kubelet",
+	KubeletBootstrapConfig:       "systemctl restart kubelet",
+	KubeletServerCert:            "systemctl restart kubelet",
+	KubeletServerKey:             "systemctl restart kubelet",
+
+	SysconfigKubeProxyKaptain: "systemctl restart kube-proxy",
+	KubeProxyConfig:           "systemctl restart kube-proxy",
+
+	KubeEtcdCA:                  restartStaticPods("kube-apiserver"),
+	KubeEtcdClientCert:          restartStaticPods("kube-apiserver"),
+	KubeEtcdClientKey:           restartStaticPods("kube-apiserver"),
+	KubeFrontProxyCACert:        restartStaticPods("kube-apiserver"),
+	KubeFrontProxyClientCert:    restartStaticPods("kube-apiserver"),
+	KubeFrontProxyClientKey:     restartStaticPods("kube-apiserver"),
+	KubeServiceAccountPublicKey: restartStaticPods("kube-apiserver"),
+	KubeCert:                    restartStaticPods("kube-apiserver"),
+	KubeKey:                     restartStaticPods("kube-apiserver"),
+	KubeTokenCsv:                restartStaticPods("kube-apiserver"),
+	AuthTokenWebhookConfig:      restartStaticPods("kube-apiserver"),
+	KubeCACert:                  restartStaticPods("kube-apiserver", "kube-controller-manager"),
+	KubeCAKey:                   restartStaticPods("kube-apiserver", "kube-controller-manager"),
+	KubeServiceAccountKey:       restartStaticPods("kube-apiserver", "kube-controller-manager"),
+	KubeCASigningCert:           restartStaticPods("kube-controller-manager"),
+	KubeControllerManagerConfig: restartStaticPods("kube-controller-manager"),
+	KubeSchedulerConfig:         restartStaticPods("kube-scheduler"),
+	KubeCloudConfig:             "systemctl restart kubelet && " + restartStaticPods("kube-apiserver", "kube-controller-manager"),
+}
+
+// reload commands that restart a member of a quorum. The members of a role reload within
+// the same poll interval of sailor agent, so restarting all of them could lose the quorum
+// of etcd or take down every apiserver, sailor agent only runs them when enabled.
+var quorumReloadCommands = map[string]bool{
+	reloadCommands[EtcdCACert]:      true,
+	reloadCommands[KubeEtcdCA]:      true,
+	reloadCommands[KubeCACert]:      true,
+	reloadCommands[KubeCloudConfig]: true,
+}
+
+// restartStaticPods returns a command that restarts the containers of static pods, which
+// the kubelet does not restart when a file they mount changes
+func restartStaticPods(names ...string) string {
+	filters := ""
+	for _, name := range names {
+		filters += fmt.Sprintf(" --filter name=^k8s_%s_", name)
+	}
+	return fmt.Sprintf("docker ps -q%s | xargs -r docker restart", filters)
+}
+
+// permissions of cluster files that contain keys, tokens or credentials
+const (
+	privateFileMode = "0600"
+	privateDirMode  = "0700"
+)
+
+// etcd runs as its own user, so it owns its keys
+const etcdUser = "etcd"
+
+// all roles of a cluster that have cluster files
+var clusterRoles = []string{"etcd", "master", "worker", "bootstrapper"}
+
 // default values
 const DefaultMasterServiceIP = "100.64.0.1"
 const DefaultDNSClusterIP = "100.64.0.10"
@@ -51,9 +142,25 @@ const DefaultCloudProvider = "aws"
 const DefaultKubeImageProxy = "gcr.io"
 const DefaultMasterPort = 6443
 const DefaultClusterDomain = "cluster.local"
+const DefaultKeyAlgorithm = "rsa-2048"
+const DefaultCAKeyAlgorithm = "rsa-2048"
+const DefaultCertValidity = "8760h"
+const DefaultCAValidity = "43800h"
+const DefaultKubeletBootstrapMode = KubeletBootstrapStaticToken
+const DefaultBootstrapTokenTTL = 24 * time.Hour
+
+// kubelet bootstrap modes
+const (
+	// KubeletBootstrapStaticToken joins workers with the kubelet-bootstrap user of token.csv
+	KubeletBootstrapStaticToken = "static-token"
+	// KubeletBootstrapToken joins workers with expiring bootstrap tokens
+	KubeletBootstrapToken = "bootstrap-token"
+)
 
 const clusterSpecFile = "cluster.yaml"
-const defaultCAExpiry = time.Hour * 24 * 365 * 5
-const defaultCertExpiry = time.Hour * 24 * 365
 const defaultTokenLength = 32
 const defaultContextName = "default"
+
+// key pairs without a cert
+const serviceAccountKeyName = "service-account"
+const legacyServiceAccountKeyName = "service-account-legacy" // kube-ca public key, see inflateServiceAccountKey
@@ -0,0 +1,315 @@
+package kaptain
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"sort"
+
+	"github.com/ghodss/yaml"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/utils/diffutil"
+)
+
+const redactedChanged = "<redacted: changed>"
+const redactedUnchanged = "<redacted: unchanged>"
+
+// diffClusters prints the differences between two clusters: the spec, the secrets (as
+// changed/unchanged markers only) and the rendered cluster files of every role. It returns
+// true if there is any difference.
+func diffClusters(a *api.Cluster, b *api.Cluster, aName string, bName string, out io.Writer) (bool, error) {
+	aRoleFiles, err := createAllFilesFromClusterSpec(a)
+	if err != nil {
+		return false, fmt.Errorf("failed to render cluster files of %s: %v", aName, err)
+	}
+	bRoleFiles, err := createAllFilesFromClusterSpec(b)
+	if err != nil {
+		return false, fmt.Errorf("failed to render cluster files of %s: %v", bName, err)
+	}
+
+	return diffClustersWithFiles(a, b, aRoleFiles, bRoleFiles, aName, bName, out)
+}
+
+// diffClustersWithFiles prints the differences of two clusters and the given role files,
+// such as the role files saved in their revisions
+func diffClustersWithFiles(a *api.Cluster, b *api.Cluster, aRoleFiles map[string]*api.ClusterFiles, bRoleFiles map[string]*api.ClusterFiles, aName string, bName string, out io.Writer) (bool, error) {
+	specChanged, err := diffSpecs(a, b, aName, bName, out)
+	if err != nil {
+		return false, err
+	}
+
+	secretsChanged := diffSecrets(a, b, out)
+
+	// node roles only exist while their node is in the spec
+	roles := []string{}
+	for role := range aRoleFiles {
+		roles = append(roles, role)
+	}
+	for role := range bRoleFiles {
+		if _, exists := aRoleFiles[role]; !exists {
+			roles = append(roles, role)
+		}
+	}
+	sort.Strings(roles)
+
+	filesChanged := false
+	for _, role := range roles {
+		aFiles, bFiles := aRoleFiles[role], bRoleFiles[role]
+		if aFiles == nil {
+			aFiles = api.NewClusterFiles()
+		}
+		if bFiles == nil {
+			bFiles = api.NewClusterFiles()
+		}
+
+		changed, err := diffClusterFiles(role, aFiles, bFiles, aName, bName, out)
+		if err != nil {
+			return false, err
+		}
+		filesChanged = filesChanged || changed
+	}
+
+	return specChanged || secretsChanged || filesChanged, nil
+}
+
+// diffSpecs prints the unified diff of the cluster spec and asset manifest, with
+// passwords and other sensitive spec fields redacted
+func diffSpecs(a *api.Cluster, b *api.Cluster, aName string, bName string, out io.Writer) (bool, error) {
+	aSpec, bSpec := a.Spec, b.Spec
+	redactSpecField(&aSpec.VSphereOpts.Password, &bSpec.VSphereOpts.Password)
+	redactSpecField(&aSpec.AuthenticationTokenWebhookOpts.ConfigDataBase64, &bSpec.AuthenticationTokenWebhookOpts.ConfigDataBase64)
+
+	aData, err := marshalSpec(aSpec, a.AssetManifest)
+	if err != nil {
+		return false, err
+	}
+	bData, err := marshalSpec(bSpec, b.AssetManifest)
+	if err != nil {
+		return false, err
+	}
+
+	diff := diffutil.Unified(path.Join(aName, "spec"), path.Join(bName, "spec"), aData, bData)
+	fmt.Fprint(out, diff)
+
+	// a redacted field may differ while the marshalled specs are equal
+	return diff != "" || !reflect.DeepEqual(a.Spec, b.Spec), nil
+}
+
+func marshalSpec(spec api.ClusterSpec, manifest api.AssetManifestSpec) ([]byte, error) {
+	data, err := yaml.Marshal(struct {
+		Spec          api.ClusterSpec       `json:"spec"`
+		AssetManifest api.AssetManifestSpec `json:"assetManifest"`
+	}{spec, manifest})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialise cluster spec: %v", err)
+	}
+	return data, nil
+}
+
+// redactSpecField replaces a pair of sensitive values with changed/unchanged markers
+func redactSpecField(a *string, b *string) {
+	if *a == "" && *b == "" {
+		return
+	}
+
+	marker := redactedUnchanged
+	if *a != *b {
+		marker = redactedChanged
+	}
+	*a, *b = marker, marker
+}
+
+// diffSecrets prints a changed/unchanged marker for every PKI, key pair, token, bootstrap
+// token and issued client cert, never the values
+func diffSecrets(a *api.Cluster, b *api.Cluster, out io.Writer) bool {
+	markers := map[string]string{}
+
+	for name, aPair := range a.Secrets.PKIs {
+		bPair, inB := b.Secrets.PKIs[name]
+		markers[path.Join("pkis", name)] = compareSecret(aPair, bPair, inB)
+	}
+	for name, bPair := range b.Secrets.PKIs {
+		if _, inA := a.Secrets.PKIs[name]; !inA {
+			markers[path.Join("pkis", name)] = "added"
+		}
+		if isPending(bPair) {
+			markers[path.Join("pkis", name)] = "would be issued"
+		}
+	}
+	for name, aToken := range a.Secrets.TokenSecrets {
+		bToken, inB := b.Secrets.TokenSecrets[name]
+		markers[path.Join("tokens", name)] = compareSecret(aToken, bToken, inB)
+	}
+	for name := range b.Secrets.TokenSecrets {
+		if _, inA := a.Secrets.TokenSecrets[name]; !inA {
+			markers[path.Join("tokens", name)] = "added"
+		}
+	}
+	for name, aPair := range a.Secrets.KeyPairs {
+		bPair, inB := b.Secrets.KeyPairs[name]
+		markers[path.Join("keyPairs", name)] = compareSecret(aPair, bPair, inB)
+	}
+	for name, bPair := range b.Secrets.KeyPairs {
+		if _, inA := a.Secrets.KeyPairs[name]; !inA {
+			markers[path.Join("keyPairs", name)] = "added"
+		}
+		if isPending(bPair) {
+			markers[path.Join("keyPairs", name)] = "would be issued"
+		}
+	}
+	for id, aToken := range a.Secrets.BootstrapTokens {
+		bToken, inB := b.Secrets.BootstrapTokens[id]
+		markers[path.Join("bootstrapTokens", id)] = compareSecret(aToken, bToken, inB)
+	}
+	for id := range b.Secrets.BootstrapTokens {
+		if _, inA := a.Secrets.BootstrapTokens[id]; !inA {
+			markers[path.Join("bootstrapTokens", id)] = "added"
+		}
+	}
+	aClientCerts := indexClientCertsBySerial(a.Secrets.ClientCerts)
+	bClientCerts := indexClientCertsBySerial(b.Secrets.ClientCerts)
+	for serial, aRecord := range aClientCerts {
+		bRecord, inB := bClientCerts[serial]
+		markers[path.Join("clientCerts", serial)] = compareSecret(aRecord, bRecord, inB)
+	}
+	for serial := range bClientCerts {
+		if _, inA := aClientCerts[serial]; !inA {
+			markers[path.Join("clientCerts", serial)] = "added"
+		}
+	}
+
+	names := make([]string, 0, len(markers))
+	for name := range markers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	changed := false
+	fmt.Fprintln(out, "secrets:")
+	for _, name := range names {
+		fmt.Fprintf(out, "  %s: %s\n", name, markers[name])
+		changed = changed || markers[name] != "unchanged"
+	}
+
+	return changed
+}
+
+func indexClientCertsBySerial(records []api.ClientCertRecord) map[string]api.ClientCertRecord {
+	index := map[string]api.ClientCertRecord{}
+	for _, r := range records {
+		index[r.Serial] = r
+	}
+	return index
+}
+
+func compareSecret(a interface{}, b interface{}, inB bool) string {
+	switch {
+	case !inB:
+		return "removed"
+	case reflect.DeepEqual(a, b):
+		return "unchanged"
+	default:
+		return "changed"
+	}
+}
+
+// diffClusterFiles prints the unified diff of every changed file of a role. Sensitive
+// files are only reported as changed, without their content.
+func diffClusterFiles(role string, aFiles *api.ClusterFiles, bFiles *api.ClusterFiles, aName string, bName string, out io.Writer) (bool, error) {
+	aIndex := indexClusterFilesByPath(aFiles)
+	bIndex := indexClusterFilesByPath(bFiles)
+
+	paths := []string{}
+	for p := range aIndex {
+		paths = append(paths, p)
+	}
+	for p := range bIndex {
+		if _, exists := aIndex[p]; !exists {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	changed := false
+	for _, p := range paths {
+		aFile, bFile := aIndex[p], bIndex[p]
+		filePath := path.Join(role, p)
+
+		aData, err := getClusterFileData(aFile)
+		if err != nil {
+			return false, fmt.Errorf("failed to decode %s of %s: %v", filePath, aName, err)
+		}
+		bData, err := getClusterFileData(bFile)
+		if err != nil {
+			return false, fmt.Errorf("failed to decode %s of %s: %v", filePath, bName, err)
+		}
+
+		sensitive := (aFile != nil && aFile.Sensitive) || (bFile != nil && bFile.Sensitive)
+		switch {
+		case aFile == nil:
+			changed = true
+			if sensitive {
+				fmt.Fprintf(out, "%s: added %s\n", filePath, redactedChanged)
+				continue
+			}
+		case bFile == nil:
+			changed = true
+			if sensitive {
+				fmt.Fprintf(out, "%s: removed %s\n", filePath, redactedChanged)
+				continue
+			}
+		case aFile.DataBase64 == bFile.DataBase64:
+			if aAttrs, bAttrs := formatFileAttrs(aFile), formatFileAttrs(bFile); aAttrs != bAttrs {
+				changed = true
+				fmt.Fprintf(out, "%s: %s -> %s\n", filePath, aAttrs, bAttrs)
+			}
+			continue
+		default:
+			changed = true
+			if sensitive {
+				fmt.Fprintf(out, "%s: %s\n", filePath, redactedChanged)
+				continue
+			}
+		}
+
+		fmt.Fprint(out, diffutil.Unified(path.Join(aName, filePath), path.Join(bName, filePath), aData, bData))
+	}
+
+	return changed, nil
+}
+
+// formatFileAttrs returns the mode, ownership and reload command of a file, e.g.
+// mode=0600 owner=etcd:etcd
+func formatFileAttrs(clusterFile *api.ClusterFile) string {
+	mode := clusterFile.Mode
+	if mode == "" {
+		mode = "default"
+	}
+	attrs := fmt.Sprintf("mode=%s", mode)
+	if clusterFile.Owner != "" || clusterFile.Group != "" {
+		attrs += fmt.Sprintf(" owner=%s:%s", clusterFile.Owner, clusterFile.Group)
+	}
+	if clusterFile.DirMode != "" {
+		attrs += fmt.Sprintf(" dirMode=%s", clusterFile.DirMode)
+	}
+	if clusterFile.Reload != "" {
+		attrs += fmt.Sprintf(" reload=%q", clusterFile.Reload)
+	}
+	return attrs
+}
+
+func indexClusterFilesByPath(clusterFiles *api.ClusterFiles) map[string]*api.ClusterFile {
+	index := map[string]*api.ClusterFile{}
+	for _, f := range clusterFiles.Spec.ClusterFiles {
+		index[f.Path] = f
+	}
+	return index
+}
+
+func getClusterFileData(clusterFile *api.ClusterFile) ([]byte, error) {
+	if clusterFile == nil {
+		return []byte{}, nil
+	}
+	return clusterFile.GetData()
+}
@@ -0,0 +1,91 @@
+package kaptain
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/utils/pkiutil"
+)
+
+// CAImport is an existing CA, such as a corporate intermediate, to use instead of a CA
+// created by kaptain
+type CAImport struct {
+	CertPEM  []byte
+	KeyPEM   []byte
+	ChainPEM []byte // certs that issued the CA up to the root, empty if the CA is a root
+}
+
+// ImportCA verifies an existing CA and adds it to the secrets of a new cluster, so it is
+// used instead of a new CA when the PKIs are inflated
+func ImportCA(cluster *api.Cluster, caName string, ca *CAImport, now time.Time) error {
+	if _, err := getCACertSpec(caName); err != nil {
+		return err
+	}
+	if _, exists := cluster.Secrets.PKIs[caName]; exists {
+		return fmt.Errorf("cluster already has CA '%s'", caName)
+	}
+	if isExternalCA(cluster, caName) {
+		return fmt.Errorf("CA '%s' is held by signer %s and cannot be imported", caName, getSignerUrl(cluster, caName))
+	}
+
+	combo := pkiutil.CertCombo{}
+	if err := combo.SetCertPEMData(ca.CertPEM); err != nil {
+		return fmt.Errorf("invalid cert of CA '%s': %v", caName, err)
+	}
+	if err := combo.SetKeyPEMData(ca.KeyPEM); err != nil {
+		return fmt.Errorf("invalid key of CA '%s': %v", caName, err)
+	}
+	chain, err := pkiutil.ParseCertsPEM(ca.ChainPEM)
+	if err != nil {
+		return fmt.Errorf("invalid chain of CA '%s': %v", caName, err)
+	}
+
+	cert := combo.Cert
+	if !cert.IsCA || cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return fmt.Errorf("cert of CA '%s' (%s) is not a CA", caName, cert.Subject)
+	}
+	if !combo.KeyMatchesCert() {
+		return fmt.Errorf("key of CA '%s' does not match its cert", caName)
+	}
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return fmt.Errorf("CA '%s' is only valid from %s to %s", caName, formatCertTime(cert.NotBefore), formatCertTime(cert.NotAfter))
+	}
+	if err := pkiutil.VerifyCAChain(cert, chain, now); err != nil {
+		return fmt.Errorf("invalid chain of CA '%s': %v", caName, err)
+	}
+
+	daysRemaining := int(math.Floor(cert.NotAfter.Sub(now).Hours() / 24))
+	log.Infof("Importing CA %s: %s, expires at %s (%d days)", caName, cert.Subject, formatCertTime(cert.NotAfter), daysRemaining)
+	if validity, err := time.ParseDuration(cluster.Spec.PKIOpts.CertValidity); err == nil && now.Add(validity).After(cert.NotAfter) {
+		log.Warnf("CA %s expires before the certs it signs, they will be valid until %s only", caName, formatCertTime(cert.NotAfter))
+	}
+	if len(chain) > 0 {
+		log.Infof("The chain of CA %s is only added to the kubeconfigs to verify the apiserver, clients are only trusted with certs issued by the CA", caName)
+	}
+
+	keyPEM, err := combo.ExtractKeyData()
+	if err != nil {
+		return fmt.Errorf("invalid key of CA '%s': %v", caName, err)
+	}
+	chainPEM := []byte{}
+	for _, c := range chain {
+		chainPEM = append(chainPEM, (&pkiutil.CertCombo{Cert: c}).ExtractCertData()...)
+	}
+
+	if cluster.Secrets.PKIs == nil {
+		cluster.Secrets.PKIs = map[string]api.CertPair{}
+	}
+	cluster.Secrets.PKIs[caName] = api.CertPair{
+		CertData:  base64.StdEncoding.EncodeToString(combo.ExtractCertData()),
+		KeyData:   base64.StdEncoding.EncodeToString(keyPEM),
+		ChainData: base64.StdEncoding.EncodeToString(chainPEM),
+		Imported:  true,
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package kaptain
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/utils/pkiutil"
+)
+
+func TestImportCAChainOnlyVerifiesServers(t *testing.T) {
+	root, err := pkiutil.InitCA(pkiutil.CSRParams{Subject: pkix.Name{CommonName: "corporate-root"}, ValidFor: 2 * time.Hour})
+	if err != nil {
+		t.Fatalf("InitCA failed: %v", err)
+	}
+	intermediate, err := pkiutil.InitCA(pkiutil.CSRParams{Subject: pkix.Name{CommonName: "kube-intermediate"}, ValidFor: time.Hour})
+	if err != nil {
+		t.Fatalf("InitCA failed: %v", err)
+	}
+	template := *intermediate.Cert
+	template.SerialNumber = big.NewInt(2)
+	der, err := x509.CreateCertificate(rand.Reader, &template, root.Cert, intermediate.Key.Public(), root.Key)
+	if err != nil {
+		t.Fatalf("failed to sign intermediate: %v", err)
+	}
+	if intermediate.Cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatalf("failed to parse intermediate: %v", err)
+	}
+	keyPEM, err := intermediate.ExtractKeyData()
+	if err != nil {
+		t.Fatalf("ExtractKeyData failed: %v", err)
+	}
+
+	// the certs are valid from the time they were made
+	now := time.Now()
+	cluster := &api.Cluster{}
+	ca := &CAImport{CertPEM: intermediate.ExtractCertData(), KeyPEM: keyPEM, ChainPEM: root.ExtractCertData()}
+	if err := ImportCA(cluster, "kube-ca", ca, now); err != nil {
+		t.Fatalf("ImportCA failed: %v", err)
+	}
+
+	// ca.pem verifies client certs, a cert issued by the root must not authenticate
+	bundle, err := cluster.Secrets.GetCABundle("kube-ca")
+	if err != nil {
+		t.Fatalf("GetCABundle failed: %v", err)
+	}
+	if !bytes.Equal(bundle, intermediate.ExtractCertData()) {
+		t.Errorf("client CA bundle holds more than the imported CA:\n%s", bundle)
+	}
+
+	serverBundle, err := cluster.Secrets.GetServerCABundle("kube-ca")
+	if err != nil {
+		t.Fatalf("GetServerCABundle failed: %v", err)
+	}
+	if !bytes.Contains(serverBundle, root.ExtractCertData()) {
+		t.Errorf("server CA bundle does not hold the chain of the imported CA")
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"crypto/x509/pkix"
 	"encoding/base64"
 	"fmt"
-	"math/rand"
 	"strings"
 	"time"
 
@@ -22,15 +21,13 @@ type InflateClusterOptions struct {
 	UpdatePKIs          bool
 	UpdateTokens        bool
 	UpdateAssetManifest bool
+	DryRun              bool // missing certs and keys are marked as would be issued, nothing is signed
 }
 
 // InflateCluster inflate the cluster by setting default values, prepare role manifest, generate PKIs and token secrets
 func InflateCluster(cluster *api.Cluster, opts *InflateClusterOptions) error {
-	// Initialise random seed, otherwise all secrets will be generated with the same value
-	rand.Seed(time.Now().UnixNano())
-
 	b, _ := yaml.Marshal(cluster)
-	log.Infof(string(b))
+	log.Debug(string(b))
 
 	if opts.UpdateSpec {
 		inflateClusterDefaults(cluster)
@@ -43,12 +40,20 @@ func InflateCluster(cluster *api.Cluster, opts *InflateClusterOptions) error {
 		}
 	}
 
+	if err := checkKubeletServerTLSBootstrapSupported(cluster); err != nil {
+		return err
+	}
+
 	if opts.UpdatePKIs {
-		inflatePKIs(cluster)
+		if err := inflatePKIs(cluster, opts.DryRun); err != nil {
+			return err
+		}
 	}
 
 	if opts.UpdateTokens {
-		inflateTokens(cluster)
+		if err := inflateTokens(cluster); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -69,6 +74,44 @@ func inflateClusterDefaults(cluster *api.Cluster) {
 	if cluster.Spec.DNSClusterIP == "" {
 		cluster.Spec.DNSClusterIP = DefaultDNSClusterIP
 	}
+
+	// PKI options, existing clusters are RSA 2048
+	if cluster.Spec.PKIOpts.KeyAlgorithm == "" {
+		cluster.Spec.PKIOpts.KeyAlgorithm = DefaultKeyAlgorithm
+	}
+
+	if cluster.Spec.PKIOpts.CAKeyAlgorithm == "" {
+		cluster.Spec.PKIOpts.CAKeyAlgorithm = DefaultCAKeyAlgorithm
+	}
+
+	if cluster.Spec.PKIOpts.CertValidity == "" {
+		cluster.Spec.PKIOpts.CertValidity = DefaultCertValidity
+	}
+
+	if cluster.Spec.PKIOpts.CAValidity == "" {
+		cluster.Spec.PKIOpts.CAValidity = DefaultCAValidity
+	}
+
+	if cluster.Spec.KubeletBootstrapOpts.Mode == "" {
+		cluster.Spec.KubeletBootstrapOpts.Mode = DefaultKubeletBootstrapMode
+	}
+}
+
+// checkKubeletServerTLSBootstrapSupported verifies the asset manifest has the templates
+// and addon needed by kubelet serving certs, if they are enabled
+func checkKubeletServerTLSBootstrapSupported(cluster *api.Cluster) error {
+	if !cluster.Spec.KubeletBootstrapOpts.ServerTLSBootstrap {
+		return nil
+	}
+
+	if _, exists := indexByName(cluster.AssetManifest.Addons)["kubelet-serving-csr"]; !exists {
+		return fmt.Errorf("kubelet serving certs are not supported by the asset manifest of Kubernetes %s, update the asset manifest first", cluster.Spec.KubeVersion)
+	}
+	if len(cluster.Spec.Masters) == 0 {
+		log.Warnf("No masters in the cluster spec, the apiserver cannot verify the kubelets of the masters")
+	}
+
+	return nil
 }
 
 func inflateAssetManifest(cluster *api.Cluster) error {
@@ -87,155 +130,469 @@ func inflateAssetManifest(cluster *api.Cluster) error {
 	return nil
 }
 
-func inflatePKIs(cluster *api.Cluster) {
-	log.Infof("Inflating PKIs")
+// certSpec defines how a PKI in the cluster secrets is created. The key and validity
+// of the CSR are set from the PKI options of the cluster spec.
+type certSpec struct {
+	name    string
+	caName  string // empty for CAs
+	makeCsr func(cluster *api.Cluster) pkiutil.CSRParams
+}
 
-	// etcd-ca
-	var etcdCA *pkiutil.CertCombo
-	if etcdCAPair, exists := cluster.Secrets.PKIs["etcd-ca"]; !exists {
-		log.Infof("Creating new CA: etcd-ca")
-		// create new CA
-		etcdCACsr := pkiutil.CSRParams{
+// caCertSpecs are the CAs of the cluster
+var caCertSpecs = []certSpec{
+	{"etcd-ca", "", func(cluster *api.Cluster) pkiutil.CSRParams {
+		return pkiutil.CSRParams{
 			Subject: pkix.Name{
 				CommonName: "ETCD CA",
 			},
-			ValidFor: defaultCAExpiry,
 			Profile:  pkiutil.None,
 		}
-		etcdCA = pkiutil.InitCA(etcdCACsr)
-		cluster.Secrets.PKIs["etcd-ca"] = makeCertPair(etcdCA)
-	} else {
-		// use existing CA
-		log.Infof("Use existing PKI: etcd-ca")
-		etcdCA = makeCertCombo(&etcdCAPair)
-	}
-
-	// kube-ca
-	var kubeCA *pkiutil.CertCombo
-	if kubeCAPair, exists := cluster.Secrets.PKIs["kube-ca"]; !exists {
-		log.Infof("Creating new CA: kube-ca")
-		// create new CA
-		kubeCACsr := pkiutil.CSRParams{
+	}},
+	{"kube-ca", "", func(cluster *api.Cluster) pkiutil.CSRParams {
+		return pkiutil.CSRParams{
 			Subject: pkix.Name{
 				CommonName: "Kube CA",
 			},
-			ValidFor: defaultCAExpiry,
 			Profile:  pkiutil.None,
 		}
-		kubeCA = pkiutil.InitCA(kubeCACsr)
-		cluster.Secrets.PKIs["kube-ca"] = makeCertPair(kubeCA)
-	} else {
-		// use existing CA
-		log.Infof("Use existing PKI: kube-ca")
-		kubeCA = makeCertCombo(&kubeCAPair)
-	}
-
-	// etcd-server
-	if _, exists := cluster.Secrets.PKIs["etcd-server"]; !exists {
-		etcdMemberCount := len(cluster.Spec.EtcdCluster.Members)
-		etcdAltNames := make([]string, etcdMemberCount*2)
-		for i, v := range cluster.Spec.EtcdCluster.Members {
-			fullHostname := fmt.Sprintf("%s.%s", v.Hostname, cluster.Spec.DNSDomain)
-			etcdAltNames[i] = v.Hostname
-			etcdAltNames[i+etcdMemberCount] = fullHostname
+	}},
+	{"front-proxy-ca", "", func(cluster *api.Cluster) pkiutil.CSRParams {
+		return pkiutil.CSRParams{
+			Subject: pkix.Name{
+				CommonName: "Front Proxy CA",
+			},
+			Profile: pkiutil.None,
 		}
-		etcdCsr := pkiutil.CSRParams{
+	}},
+}
+
+// leafCertSpecs are the certs signed by the CAs of the cluster
+var leafCertSpecs = []certSpec{
+	{"etcd-server", "etcd-ca", func(cluster *api.Cluster) pkiutil.CSRParams {
+		return pkiutil.CSRParams{
 			Subject: pkix.Name{
 				CommonName: "etcd",
 			},
-			AltNames: etcdAltNames,
+			AltNames: makeEtcdServerAltNames(cluster),
 			Profile:  pkiutil.Server,
-			ValidFor: defaultCertExpiry,
 		}
-		etcd := pkiutil.MakeCert(etcdCsr, etcdCA)
-		cluster.Secrets.PKIs["etcd-server"] = makeCertPair(etcd)
-	}
-
-	// ** etcd-client (client)
-	if _, exists := cluster.Secrets.PKIs["etcd-client"]; !exists {
-		etcdClientCsr := pkiutil.CSRParams{
+	}},
+	{"etcd-peer", "etcd-ca", func(cluster *api.Cluster) pkiutil.CSRParams {
+		return pkiutil.CSRParams{
+			Subject: pkix.Name{
+				CommonName: "etcd-peer",
+			},
+			AltNames: makeEtcdServerAltNames(cluster),
+			Profile:  pkiutil.Peer,
+		}
+	}},
+	{"etcd-client", "etcd-ca", func(cluster *api.Cluster) pkiutil.CSRParams {
+		return pkiutil.CSRParams{
 			Subject: pkix.Name{
 				CommonName: "apiserver",
 			},
 			Profile:  pkiutil.Client,
-			ValidFor: defaultCertExpiry,
 		}
-		etcdClient := pkiutil.MakeCert(etcdClientCsr, etcdCA)
-		cluster.Secrets.PKIs["etcd-client"] = makeCertPair(etcdClient)
-	}
-
-	// kubernetes
-	if _, exists := cluster.Secrets.PKIs["kubernetes"]; !exists {
-		kubeCsr := pkiutil.CSRParams{
+	}},
+	{"kubernetes", "kube-ca", func(cluster *api.Cluster) pkiutil.CSRParams {
+		return pkiutil.CSRParams{
 			Subject: pkix.Name{
 				CommonName: "kubernetes",
 			},
-			AltNames: []string{
-				cluster.Spec.MasterPublicName,
-				"kubernetes",
-				"kubernetes.default",
-				"kubernetes.default.svc",
-				"kubernetes.default.svc.cluster",
-				"kubernetes.default.svc.cluster.local",
-				"localhost",
-				"127.0.0.1",
-				DefaultMasterServiceIP,
-			},
+			AltNames: makeKubernetesAltNames(cluster),
 			Profile:  pkiutil.Server,
-			ValidFor: defaultCertExpiry,
 		}
-		kube := pkiutil.MakeCert(kubeCsr, kubeCA)
-		cluster.Secrets.PKIs["kubernetes"] = makeCertPair(kube)
-	}
-
-	// kube-controller-manager
-	if _, exists := cluster.Secrets.PKIs["kube-controller-manager"]; !exists {
-		controllerManagerCsr := pkiutil.CSRParams{
+	}},
+	{"kube-controller-manager", "kube-ca", func(cluster *api.Cluster) pkiutil.CSRParams {
+		return pkiutil.CSRParams{
 			Subject: pkix.Name{
 				CommonName: "system:kube-controller-manager",
 			},
 			Profile:  pkiutil.Client,
-			ValidFor: defaultCertExpiry,
 		}
-		controllerManager := pkiutil.MakeCert(controllerManagerCsr, kubeCA)
-		cluster.Secrets.PKIs["kube-controller-manager"] = makeCertPair(controllerManager)
-	}
-
-	// kube-scheduler
-	if _, exists := cluster.Secrets.PKIs["kube-scheduler"]; !exists {
-		schedulerCsr := pkiutil.CSRParams{
+	}},
+	{"kube-scheduler", "kube-ca", func(cluster *api.Cluster) pkiutil.CSRParams {
+		return pkiutil.CSRParams{
 			Subject: pkix.Name{
 				CommonName: "system:kube-scheduler",
 			},
 			Profile:  pkiutil.Client,
-			ValidFor: defaultCertExpiry,
 		}
-		scheduler := pkiutil.MakeCert(schedulerCsr, kubeCA)
-		cluster.Secrets.PKIs["kube-scheduler"] = makeCertPair(scheduler)
-	}
-
-	// kube-proxy (client)
-	if _, exists := cluster.Secrets.PKIs["kube-proxy"]; !exists {
-		kubeProxyCsr := pkiutil.CSRParams{
+	}},
+	{"kube-proxy", "kube-ca", func(cluster *api.Cluster) pkiutil.CSRParams {
+		return pkiutil.CSRParams{
 			Subject: pkix.Name{
 				CommonName: "system:kube-proxy",
 			},
 			Profile:  pkiutil.Client,
-			ValidFor: defaultCertExpiry,
 		}
-		kubeProxy := pkiutil.MakeCert(kubeProxyCsr, kubeCA)
-		cluster.Secrets.PKIs["kube-proxy"] = makeCertPair(kubeProxy)
+	}},
+	{"front-proxy-client", "front-proxy-ca", func(cluster *api.Cluster) pkiutil.CSRParams {
+		return pkiutil.CSRParams{
+			Subject: pkix.Name{
+				CommonName: "front-proxy-client",
+			},
+			Profile: pkiutil.Client,
+		}
+	}},
+}
+
+// nodeCertSpec defines a cert issued to every node of a role instead of one cert shared by
+// all of them, the SANs of each cert are those of its node
+type nodeCertSpec struct {
+	name    string
+	caName  string
+	role    string // etcd or master
+	makeCsr func(cluster *api.Cluster, node clusterNode) pkiutil.CSRParams
+}
+
+// nodeCertSpecs are the certs issued to every etcd member and master of the cluster
+var nodeCertSpecs = []nodeCertSpec{
+	{"etcd-server", "etcd-ca", "etcd", func(cluster *api.Cluster, node clusterNode) pkiutil.CSRParams {
+		return pkiutil.CSRParams{
+			Subject: pkix.Name{
+				CommonName: node.Hostname,
+			},
+			AltNames: makeNodeAltNames(cluster, node),
+			Profile:  pkiutil.Server,
+		}
+	}},
+	{"etcd-peer", "etcd-ca", "etcd", func(cluster *api.Cluster, node clusterNode) pkiutil.CSRParams {
+		return pkiutil.CSRParams{
+			Subject: pkix.Name{
+				CommonName: node.Hostname,
+			},
+			AltNames: makeNodeAltNames(cluster, node),
+			Profile:  pkiutil.Peer,
+		}
+	}},
+	{"kubernetes", "kube-ca", "master", func(cluster *api.Cluster, node clusterNode) pkiutil.CSRParams {
+		return pkiutil.CSRParams{
+			Subject: pkix.Name{
+				CommonName: "kubernetes",
+			},
+			AltNames: append(makeKubernetesAltNames(cluster), makeNodeAltNames(cluster, node)...),
+			Profile:  pkiutil.Server,
+		}
+	}},
+	{"kubelet-server", "kube-ca", "master", func(cluster *api.Cluster, node clusterNode) pkiutil.CSRParams {
+		return pkiutil.CSRParams{
+			Subject: pkix.Name{
+				CommonName:   fmt.Sprintf("system:node:%s", node.Hostname),
+				Organization: []string{"system:nodes"},
+			},
+			AltNames: makeNodeAltNames(cluster, node),
+			Profile:  pkiutil.Server,
+		}
+	}},
+}
+
+// optionalNodeCerts are the node certs that are only issued if the cluster spec enables them
+var optionalNodeCerts = map[string]func(cluster *api.Cluster) bool{
+	// kubelets on workers request their serving certs from the apiserver, but kubelets on
+	// masters talk to the insecure port and cannot, so kaptain issues them
+	"kubelet-server": func(cluster *api.Cluster) bool {
+		return cluster.Spec.KubeletBootstrapOpts.ServerTLSBootstrap
+	},
+}
+
+// clusterNode is an etcd member or a master of the cluster spec
+type clusterNode struct {
+	Hostname string
+	IP       string
+}
+
+// getClusterNodes returns the nodes of a role that are listed in the cluster spec
+func getClusterNodes(cluster *api.Cluster, role string) []clusterNode {
+	nodes := []clusterNode{}
+	switch role {
+	case "etcd":
+		for _, m := range cluster.Spec.EtcdCluster.Members {
+			nodes = append(nodes, clusterNode{Hostname: m.Hostname, IP: m.IP})
+		}
+	case "master":
+		for _, m := range cluster.Spec.Masters {
+			nodes = append(nodes, clusterNode{Hostname: m.Hostname, IP: m.IP})
+		}
 	}
+	return nodes
 }
 
-func inflateTokens(cluster *api.Cluster) {
+// makeNodeCertName returns the name of the PKI of a node cert, e.g. etcd-server@etcd-k8s-0
+func makeNodeCertName(name string, hostname string) string {
+	return fmt.Sprintf("%s@%s", name, hostname)
+}
+
+// isNodeCertName returns true if the PKI name is a node cert of any node
+func isNodeCertName(name string) bool {
+	for _, spec := range nodeCertSpecs {
+		if strings.HasPrefix(name, spec.name+"@") {
+			return true
+		}
+	}
+	return false
+}
+
+// makeNodeCertSpecs returns the cert specs of the node certs of every node in the spec
+func makeNodeCertSpecs(cluster *api.Cluster) []certSpec {
+	specs := []certSpec{}
+	for _, nodeSpec := range nodeCertSpecs {
+		if enabled, optional := optionalNodeCerts[nodeSpec.name]; optional && !enabled(cluster) {
+			continue
+		}
+		for _, node := range getClusterNodes(cluster, nodeSpec.role) {
+			makeCsr, n := nodeSpec.makeCsr, node
+			specs = append(specs, certSpec{
+				name:   makeNodeCertName(nodeSpec.name, node.Hostname),
+				caName: nodeSpec.caName,
+				makeCsr: func(cluster *api.Cluster) pkiutil.CSRParams {
+					return makeCsr(cluster, n)
+				},
+			})
+		}
+	}
+	return specs
+}
+
+// getAllLeafCertSpecs returns the shared leaf certs followed by the node certs of the cluster
+func getAllLeafCertSpecs(cluster *api.Cluster) []certSpec {
+	return append(append([]certSpec{}, leafCertSpecs...), makeNodeCertSpecs(cluster)...)
+}
+
+// inflatePKIs creates the missing CAs, certs and keys. A dry run marks them as would be
+// issued instead, so no key is generated and no signer is called.
+func inflatePKIs(cluster *api.Cluster, dryRun bool) error {
+	log.Infof("Inflating PKIs")
+
+	// service account tokens of clusters made by older versions are signed by kube-ca
+	_, hasKubeCA := cluster.Secrets.PKIs["kube-ca"]
+
+	// CAs
+	cas := map[string]*pkiutil.CertCombo{}
+	for _, spec := range caCertSpecs {
+		if _, exists := cluster.Secrets.PKIs[spec.name]; !exists && isExternalCA(cluster, spec.name) {
+			// the key of the CA is held by its signer
+			caPair, err := fetchExternalCA(cluster, spec.name)
+			if err != nil {
+				return fmt.Errorf("failed to fetch CA %s: %v", spec.name, err)
+			}
+			ca, err := makeCertCombo(&caPair)
+			if err != nil {
+				return fmt.Errorf("invalid CA %s: %v", spec.name, err)
+			}
+			cluster.Secrets.PKIs[spec.name] = caPair
+			cas[spec.name] = ca
+		} else if _, exists := cluster.Secrets.PKIs[spec.name]; !exists && dryRun {
+			cluster.Secrets.PKIs[spec.name] = makePendingCertPair(spec.name)
+		} else if caPair, exists := cluster.Secrets.PKIs[spec.name]; !exists {
+			log.Infof("Creating new CA: %s", spec.name)
+			// create new CA
+			params, err := makeCSRParams(cluster, &spec)
+			if err != nil {
+				return err
+			}
+			ca, err := pkiutil.InitCA(params)
+			if err != nil {
+				return fmt.Errorf("failed to create CA %s: %v", spec.name, err)
+			}
+			if cluster.Secrets.PKIs[spec.name], err = makeCertPair(ca); err != nil {
+				return fmt.Errorf("failed to create CA %s: %v", spec.name, err)
+			}
+			cas[spec.name] = ca
+		} else {
+			// use existing CA
+			log.Infof("Use existing PKI: %s", spec.name)
+			ca, err := makeCertCombo(&caPair)
+			if err != nil {
+				return fmt.Errorf("invalid CA %s: %v", spec.name, err)
+			}
+			cas[spec.name] = ca
+		}
+	}
+
+	// node certs of nodes removed from the spec
+	nodeCertNames := map[string]bool{}
+	for _, spec := range makeNodeCertSpecs(cluster) {
+		nodeCertNames[spec.name] = true
+	}
+	for name := range cluster.Secrets.PKIs {
+		if isNodeCertName(name) && !nodeCertNames[name] {
+			log.Infof("Removing cert of removed node: %s", name)
+			delete(cluster.Secrets.PKIs, name)
+		}
+	}
+
+	// leaf certs, signers are only created for CAs that sign a new cert
+	signers := map[string]pkiutil.Signer{}
+	for _, spec := range getAllLeafCertSpecs(cluster) {
+		if _, exists := cluster.Secrets.PKIs[spec.name]; !exists && dryRun {
+			cluster.Secrets.PKIs[spec.name] = makePendingCertPair(spec.name)
+		} else if !exists {
+			log.Infof("Creating new cert: %s", spec.name)
+			params, err := makeCSRParams(cluster, &spec)
+			if err != nil {
+				return err
+			}
+			if signers[spec.caName] == nil {
+				if signers[spec.caName], err = createSigner(cluster, spec.caName, cas[spec.caName]); err != nil {
+					return fmt.Errorf("failed to create cert %s: %v", spec.name, err)
+				}
+			}
+			cert, err := pkiutil.MakeCert(params, signers[spec.caName])
+			if err != nil {
+				return fmt.Errorf("failed to create cert %s: %v", spec.name, err)
+			}
+			if cluster.Secrets.PKIs[spec.name], err = makeCertPair(cert); err != nil {
+				return fmt.Errorf("failed to create cert %s: %v", spec.name, err)
+			}
+		}
+	}
+
+	return inflateServiceAccountKey(cluster, hasKubeCA, dryRun)
+}
+
+// inflateServiceAccountKey creates the keypair that signs service account tokens. Older
+// versions signed them with the kube-ca key, so when the keypair is added to an existing
+// cluster the kube-ca public key is kept to verify the tokens signed before.
+func inflateServiceAccountKey(cluster *api.Cluster, hasKubeCA bool, dryRun bool) error {
+	if _, exists := cluster.Secrets.KeyPairs[serviceAccountKeyName]; exists {
+		return nil
+	}
+	if cluster.Secrets.KeyPairs == nil {
+		cluster.Secrets.KeyPairs = map[string]api.KeyPair{}
+	}
+
+	if hasKubeCA {
+		caPair := cluster.Secrets.PKIs["kube-ca"]
+		ca, err := makeCertCombo(&caPair)
+		if err != nil {
+			return fmt.Errorf("invalid CA kube-ca: %v", err)
+		}
+		pubPEM, err := pkiutil.EncodePublicKeyPEM(ca.Cert.PublicKey)
+		if err != nil {
+			return err
+		}
+		log.Infof("Keeping kube-ca public key to verify existing service account tokens")
+		cluster.Secrets.KeyPairs[legacyServiceAccountKeyName] = api.KeyPair{
+			PublicKeyData: base64.StdEncoding.EncodeToString(pubPEM),
+		}
+	}
+
+	if dryRun {
+		cluster.Secrets.KeyPairs[serviceAccountKeyName] = makePendingKeyPair(serviceAccountKeyName)
+		return nil
+	}
+
+	log.Infof("Creating new key pair: %s", serviceAccountKeyName)
+	keyType := cluster.Spec.PKIOpts.KeyAlgorithm
+	if keyType == "" {
+		keyType = DefaultKeyAlgorithm
+	}
+	algorithm, size, err := pkiutil.ParseKeyType(keyType)
+	if err != nil {
+		return fmt.Errorf("invalid key type for %s: %v", serviceAccountKeyName, err)
+	}
+	privPEM, pubPEM, err := pkiutil.MakeKeyPair(algorithm, size)
+	if err != nil {
+		return fmt.Errorf("failed to create key pair %s: %v", serviceAccountKeyName, err)
+	}
+	cluster.Secrets.KeyPairs[serviceAccountKeyName] = api.KeyPair{
+		PrivateKeyData: base64.StdEncoding.EncodeToString(privPEM),
+		PublicKeyData:  base64.StdEncoding.EncodeToString(pubPEM),
+	}
+
+	return nil
+}
+
+// makeCSRParams returns the CSR of a cert spec with the key and validity set from the PKI
+// options of the cluster, using the defaults for options that are not set
+func makeCSRParams(cluster *api.Cluster, spec *certSpec) (pkiutil.CSRParams, error) {
+	params := spec.makeCsr(cluster)
+	opts := cluster.Spec.PKIOpts
+
+	keyType, validity := opts.KeyAlgorithm, opts.CertValidity
+	defaultKeyType, defaultValidity := DefaultKeyAlgorithm, DefaultCertValidity
+	if spec.caName == "" {
+		keyType, validity = opts.CAKeyAlgorithm, opts.CAValidity
+		defaultKeyType, defaultValidity = DefaultCAKeyAlgorithm, DefaultCAValidity
+	}
+	if keyType == "" {
+		keyType = defaultKeyType
+	}
+	if validity == "" {
+		validity = defaultValidity
+	}
+
+	algorithm, size, err := pkiutil.ParseKeyType(keyType)
+	if err != nil {
+		return params, fmt.Errorf("invalid key type for %s: %v", spec.name, err)
+	}
+	validFor, err := time.ParseDuration(validity)
+	if err != nil || validFor <= 0 {
+		return params, fmt.Errorf("invalid validity '%s' for %s", validity, spec.name)
+	}
+
+	params.KeyAlgorithm = algorithm
+	params.KeySize = size
+	params.ValidFor = validFor
+	return params, nil
+}
+
+// makeEtcdServerAltNames returns the SANs of the etcd-server cert, which are the short
+// and fully qualified hostnames of all etcd members
+func makeEtcdServerAltNames(cluster *api.Cluster) []string {
+	etcdMemberCount := len(cluster.Spec.EtcdCluster.Members)
+	etcdAltNames := make([]string, etcdMemberCount*2)
+	for i, v := range cluster.Spec.EtcdCluster.Members {
+		fullHostname := fmt.Sprintf("%s.%s", v.Hostname, cluster.Spec.DNSDomain)
+		etcdAltNames[i] = v.Hostname
+		etcdAltNames[i+etcdMemberCount] = fullHostname
+	}
+	return etcdAltNames
+}
+
+// makeKubernetesAltNames returns the SANs of the apiserver serving cert
+func makeKubernetesAltNames(cluster *api.Cluster) []string {
+	return []string{
+		cluster.Spec.MasterPublicName,
+		"kubernetes",
+		"kubernetes.default",
+		"kubernetes.default.svc",
+		"kubernetes.default.svc.cluster",
+		"kubernetes.default.svc.cluster.local",
+		"localhost",
+		"127.0.0.1",
+		DefaultMasterServiceIP,
+	}
+}
+
+// makeNodeAltNames returns the short and fully qualified hostname and the IP, if any, of a node
+func makeNodeAltNames(cluster *api.Cluster, node clusterNode) []string {
+	altNames := []string{
+		node.Hostname,
+		fmt.Sprintf("%s.%s", node.Hostname, cluster.Spec.DNSDomain),
+	}
+	if node.IP != "" {
+		altNames = append(altNames, node.IP)
+	}
+	return altNames
+}
+
+func inflateTokens(cluster *api.Cluster) error {
 	log.Infof("Inflating tokens")
 
+	// existing tokens may have been made by the predictable generator of older versions
+	allNew := len(cluster.Secrets.TokenSecrets) == 0
+
 	// kubelet-bootstrap
 	if _, exists := cluster.Secrets.TokenSecrets["kubelet-bootstrap"]; !exists {
+		token, err := secretutil.MakeRandomToken(defaultTokenLength)
+		if err != nil {
+			return err
+		}
 		cluster.Secrets.TokenSecrets["kubelet-bootstrap"] = api.TokenSecret{
 			Username: "kubelet-bootstrap",
-			Token:    secretutil.MakeRandomToken(defaultTokenLength),
+			Token:    token,
 			UID:      10001,
 			Groups:   []string{"system:bootstrappers"},
 		}
@@ -243,30 +600,97 @@ func inflateTokens(cluster *api.Cluster) {
 
 	// admin
 	if _, exists := cluster.Secrets.TokenSecrets["admin"]; !exists {
+		token, err := secretutil.MakeRandomToken(defaultTokenLength)
+		if err != nil {
+			return err
+		}
 		cluster.Secrets.TokenSecrets["admin"] = api.TokenSecret{
 			Username: "admin",
-			Token:    secretutil.MakeRandomToken(defaultTokenLength),
+			Token:    token,
 			UID:      1,
 			Groups:   []string{"system:masters"},
 		}
 	}
+
+	if allNew {
+		cluster.SetAnnotation(tokenGeneratorAnnotation, secureTokenGenerator)
+	}
+
+	// the first bootstrap token, more are created with 'kaptain token create'
+	if cluster.Spec.KubeletBootstrapOpts.Mode == KubeletBootstrapToken && len(cluster.Secrets.BootstrapTokens) == 0 {
+		if _, err := createBootstrapToken(cluster, DefaultBootstrapTokenTTL, "created by kaptain", time.Now()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pendingPrefix starts the data of the certs and keys that a dry run would issue
+const pendingPrefix = "# would be issued: "
+
+func makePendingCertPair(name string) api.CertPair {
+	data := base64.StdEncoding.EncodeToString([]byte(pendingPrefix + name + "\n"))
+	return api.CertPair{CertData: data, KeyData: data}
+}
+
+func makePendingKeyPair(name string) api.KeyPair {
+	data := base64.StdEncoding.EncodeToString([]byte(pendingPrefix + name + "\n"))
+	return api.KeyPair{PrivateKeyData: data, PublicKeyData: data}
+}
+
+// isPending returns true if a cert pair or key pair would be issued by a dry run
+func isPending(secret interface{}) bool {
+	var data []byte
+	var err error
+	switch pair := secret.(type) {
+	case api.CertPair:
+		data, err = pair.GetCertData()
+	case api.KeyPair:
+		data, err = pair.GetPrivateKeyData()
+	default:
+		return false
+	}
+	return err == nil && strings.HasPrefix(string(data), pendingPrefix)
 }
 
-func makeCertPair(certCombo *pkiutil.CertCombo) api.CertPair {
+func makeCertPair(certCombo *pkiutil.CertCombo) (api.CertPair, error) {
+	keyPEM, err := certCombo.ExtractKeyData()
+	if err != nil {
+		return api.CertPair{}, err
+	}
 	certData := base64.StdEncoding.EncodeToString(certCombo.ExtractCertData())
-	keyData := base64.StdEncoding.EncodeToString(certCombo.ExtractKeyData())
+	keyData := base64.StdEncoding.EncodeToString(keyPEM)
 
 	return api.CertPair{
 		CertData: certData,
 		KeyData:  keyData,
-	}
+	}, nil
 }
 
-func makeCertCombo(certPair *api.CertPair) *pkiutil.CertCombo {
+// makeCertCombo decodes and parses the cert and key of a PKI, CAs of an external signer have no key
+func makeCertCombo(certPair *api.CertPair) (*pkiutil.CertCombo, error) {
+	certPEM, err := certPair.GetCertData()
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := certPair.GetKeyData()
+	if err != nil {
+		return nil, err
+	}
+
 	certCombo := pkiutil.CertCombo{}
-	certCombo.SetCertPEMData(certPair.GetCertData())
-	certCombo.SetKeyPEMData(certPair.GetKeyData())
-	return &certCombo
+	if err := certCombo.SetCertPEMData(certPEM); err != nil {
+		return nil, err
+	}
+	// the key of a CA of an external signer is not in the cluster secrets
+	if len(keyPEM) == 0 && certCombo.Cert.IsCA {
+		return &certCombo, nil
+	}
+	if err := certCombo.SetKeyPEMData(keyPEM); err != nil {
+		return nil, err
+	}
+	return &certCombo, nil
 }
 
 func getManifest(majorMinorVerion string) (*api.AssetManifest, error) {
@@ -0,0 +1,74 @@
+package kaptain
+
+import (
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/utils/pkiutil"
+)
+
+func TestMakeCertCombo(t *testing.T) {
+	ca, err := pkiutil.InitCA(pkiutil.CSRParams{Subject: pkix.Name{CommonName: "test-ca"}, ValidFor: time.Hour})
+	if err != nil {
+		t.Fatalf("InitCA failed: %v", err)
+	}
+	keyPEM, err := ca.ExtractKeyData()
+	if err != nil {
+		t.Fatalf("ExtractKeyData failed: %v", err)
+	}
+	certData := base64.StdEncoding.EncodeToString(ca.ExtractCertData())
+	keyData := base64.StdEncoding.EncodeToString(keyPEM)
+
+	tests := []struct {
+		name    string
+		pair    api.CertPair
+		wantErr string
+	}{
+		{"valid", api.CertPair{CertData: certData, KeyData: keyData}, ""},
+		{"external CA without key", api.CertPair{CertData: certData}, ""},
+		{"bad cert base64", api.CertPair{CertData: "not base64!", KeyData: keyData}, "Failed to decode x509 cert data"},
+		{"bad key base64", api.CertPair{CertData: certData, KeyData: "not base64!"}, "Failed to decode x509 key data"},
+		{"cert is not PEM", api.CertPair{CertData: keyData[:8], KeyData: keyData}, "Invalid PEM data"},
+		{"key is a cert", api.CertPair{CertData: certData, KeyData: certData}, "invalid PEM block type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := makeCertCombo(&tt.pair)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && err == nil:
+				t.Errorf("expected an error containing %q", tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Errorf("error %q does not contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInflatePKIsDryRun(t *testing.T) {
+	cluster := &api.Cluster{}
+	cluster.Name = "dry-run"
+	cluster.Secrets.PKIs = map[string]api.CertPair{}
+	inflateClusterDefaults(cluster)
+
+	if err := inflatePKIs(cluster, true); err != nil {
+		t.Fatalf("inflatePKIs failed: %v", err)
+	}
+	for _, spec := range append(append([]certSpec{}, caCertSpecs...), getAllLeafCertSpecs(cluster)...) {
+		pair, exists := cluster.Secrets.PKIs[spec.name]
+		if !exists {
+			t.Errorf("%s is missing", spec.name)
+		} else if !isPending(pair) {
+			t.Errorf("%s has been issued by a dry run", spec.name)
+		}
+	}
+	if !isPending(cluster.Secrets.KeyPairs[serviceAccountKeyName]) {
+		t.Errorf("%s has been created by a dry run", serviceAccountKeyName)
+	}
+}
@@ -0,0 +1,267 @@
+package kaptain
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/utils/pkiutil"
+)
+
+// Cert status, ordered from best to worst
+const (
+	CertStatusOK   = "ok"
+	CertStatusWarn = "warn"
+	CertStatusFail = "fail"
+)
+
+// CertsOptions defines the thresholds and output format of the cert report
+type CertsOptions struct {
+	WarnDays int    // certs expiring within this number of days are reported as warn
+	FailDays int    // certs expiring within this number of days are reported as fail
+	Output   string // "table" or "json"
+}
+
+// CertsReport describes all certs in the secrets of a cluster
+type CertsReport struct {
+	Cluster string       `json:"cluster"`
+	Status  string       `json:"status"`
+	Certs   []CertReport `json:"certs"`
+}
+
+// CertReport describes a single cert and the result of its checks
+type CertReport struct {
+	Name          string    `json:"name"`
+	Subject       string    `json:"subject"`
+	SANs          []string  `json:"sans"`
+	Issuer        string    `json:"issuer"`
+	Serial        string    `json:"serial"`
+	NotBefore     time.Time `json:"notBefore"`
+	NotAfter      time.Time `json:"notAfter"`
+	DaysRemaining int       `json:"daysRemaining"`
+	KeyType       string    `json:"keyType"`
+	Profile       string    `json:"profile"`
+	Status        string    `json:"status"`
+	Errors        []string  `json:"errors,omitempty"`
+}
+
+// inspectCerts decodes and checks every PKI of the cluster: its expiry against the
+// thresholds, that it chains to its own CA and that its key matches the cert
+func inspectCerts(cluster *api.Cluster, opts *CertsOptions, now time.Time) *CertsReport {
+	names := make([]string, 0, len(cluster.Secrets.PKIs))
+	for name := range cluster.Secrets.PKIs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// decode everything first, leafs are checked against their CA
+	combos := map[string]*pkiutil.CertCombo{}
+	decodeErrors := map[string]error{}
+	caNames := []string{}
+	for _, name := range names {
+		pair := cluster.Secrets.PKIs[name]
+		combo, err := makeCertCombo(&pair)
+		if err != nil {
+			decodeErrors[name] = err
+			continue
+		}
+		combos[name] = combo
+		if combo.Cert.IsCA {
+			caNames = append(caNames, name)
+		}
+	}
+
+	leafCANames := map[string]string{}
+	for _, spec := range getAllLeafCertSpecs(cluster) {
+		leafCANames[spec.name] = spec.caName
+	}
+
+	report := &CertsReport{
+		Cluster: cluster.Name,
+		Status:  CertStatusOK,
+		Certs:   make([]CertReport, 0, len(names)),
+	}
+	for _, name := range names {
+		var certReport CertReport
+		if err, failed := decodeErrors[name]; failed {
+			certReport = CertReport{Name: name, Status: CertStatusFail, Errors: []string{err.Error()}}
+		} else {
+			// a leaf chains to its CA or, during a rotation, to the next or previous CA.
+			// Certs kaptain does not know the CA of chain to any CA.
+			issuers := caNames
+			if caName, exists := leafCANames[name]; exists {
+				issuers = []string{caName, caName + "-next", caName + "-previous"}
+			}
+			certReport = inspectCert(name, combos, issuers, opts, now)
+			if err := verifyImportedCAChain(cluster.Secrets.PKIs[name], combos[name], now); err != nil {
+				certReport.Errors = append(certReport.Errors, err.Error())
+				certReport.Status = CertStatusFail
+			}
+		}
+
+		report.Certs = append(report.Certs, certReport)
+		report.Status = worseCertStatus(report.Status, certReport.Status)
+	}
+
+	return report
+}
+
+func inspectCert(name string, combos map[string]*pkiutil.CertCombo, issuers []string, opts *CertsOptions, now time.Time) CertReport {
+	combo := combos[name]
+	cert := combo.Cert
+	daysRemaining := int(math.Floor(cert.NotAfter.Sub(now).Hours() / 24))
+
+	certReport := CertReport{
+		Name:          name,
+		Subject:       cert.Subject.String(),
+		SANs:          getCertAltNames(cert.DNSNames, cert.IPAddresses),
+		Issuer:        cert.Issuer.String(),
+		Serial:        fmt.Sprintf("%x", cert.SerialNumber),
+		NotBefore:     cert.NotBefore,
+		NotAfter:      cert.NotAfter,
+		DaysRemaining: daysRemaining,
+		KeyType:       pkiutil.DescribePublicKey(cert.PublicKey),
+		Profile:       pkiutil.GetSigningProfile(cert).String(),
+		Status:        CertStatusOK,
+		Errors:        []string{},
+	}
+	if cert.IsCA {
+		certReport.Profile = "ca"
+	}
+
+	switch {
+	case now.Before(cert.NotBefore):
+		certReport.Errors = append(certReport.Errors, "not yet valid")
+	case daysRemaining < 0:
+		certReport.Errors = append(certReport.Errors, "expired")
+	case daysRemaining < opts.FailDays:
+		certReport.Errors = append(certReport.Errors, fmt.Sprintf("expires in less than %d days", opts.FailDays))
+	case daysRemaining < opts.WarnDays:
+		certReport.Status = CertStatusWarn
+	}
+
+	if !cert.IsCA {
+		if err := verifyLeafCert(cert, combos, issuers, now); err != nil {
+			certReport.Errors = append(certReport.Errors, err.Error())
+		}
+	}
+	// CAs of an external signer have no key
+	if (combo.Key != nil || !cert.IsCA) && !combo.KeyMatchesCert() {
+		certReport.Errors = append(certReport.Errors, "key does not match cert")
+	}
+
+	if len(certReport.Errors) > 0 {
+		certReport.Status = CertStatusFail
+	}
+
+	return certReport
+}
+
+// verifyImportedCAChain verifies a CA chains to a root through the chain it was imported
+// or fetched from its signer with, CAs created by kaptain have no chain and are self signed
+func verifyImportedCAChain(pair api.CertPair, combo *pkiutil.CertCombo, now time.Time) error {
+	if !combo.Cert.IsCA {
+		return nil
+	}
+
+	chainPEM, err := pair.GetChainData()
+	if err != nil {
+		return err
+	}
+	chain, err := pkiutil.ParseCertsPEM(chainPEM)
+	if err != nil {
+		return fmt.Errorf("invalid chain: %v", err)
+	}
+	return pkiutil.VerifyCAChain(combo.Cert, chain, now)
+}
+
+// verifyLeafCert verifies the cert chains to one of the issuer CAs and that the cert and
+// the CA are valid at the time
+func verifyLeafCert(cert *x509.Certificate, combos map[string]*pkiutil.CertCombo, issuers []string, now time.Time) error {
+	roots := x509.NewCertPool()
+	found := []string{}
+	for _, caName := range issuers {
+		if ca, exists := combos[caName]; exists && ca.Cert.IsCA {
+			roots.AddCert(ca.Cert)
+			found = append(found, caName)
+		}
+	}
+	if len(found) == 0 {
+		return fmt.Errorf("no CA to verify the cert with")
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:       roots,
+		CurrentTime: now,
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	if _, err := cert.Verify(opts); err != nil {
+		return fmt.Errorf("does not chain to %s: %v", strings.Join(found, ", "), err)
+	}
+	return nil
+}
+
+func worseCertStatus(a string, b string) string {
+	rank := map[string]int{CertStatusOK: 0, CertStatusWarn: 1, CertStatusFail: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+func printCertsReport(report *CertsReport, output string, out io.Writer) error {
+	switch output {
+	case "json":
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to serialise cert report: %v", err)
+		}
+		fmt.Fprintln(out, string(data))
+	case "table", "":
+		data := make([][]string, len(report.Certs))
+		for i, c := range report.Certs {
+			status := c.Status
+			if len(c.Errors) > 0 {
+				status = fmt.Sprintf("%s: %s", status, strings.Join(c.Errors, ", "))
+			}
+			data[i] = []string{
+				c.Name,
+				c.Subject,
+				strings.Join(c.SANs, ","),
+				c.Issuer,
+				c.Serial,
+				formatCertTime(c.NotBefore),
+				formatCertTime(c.NotAfter),
+				strconv.Itoa(c.DaysRemaining),
+				c.KeyType,
+				c.Profile,
+				status,
+			}
+		}
+		table := tablewriter.NewWriter(out)
+		table.SetHeader([]string{"Name", "Subject", "SANs", "Issuer", "Serial", "Not Before", "Not After", "Days", "Key", "Profile", "Status"})
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetBorder(false)
+		table.AppendBulk(data)
+		table.Render()
+	default:
+		return fmt.Errorf("invalid output format '%s' (must be one of table, json)", output)
+	}
+
+	return nil
+}
+
+func formatCertTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
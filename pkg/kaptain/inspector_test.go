@@ -0,0 +1,83 @@
+package kaptain
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/utils/pkiutil"
+)
+
+func TestInspectCertsChainsToOwnCA(t *testing.T) {
+	cluster := &api.Cluster{}
+	cluster.Name = "inspect"
+	cluster.Secrets.PKIs = map[string]api.CertPair{}
+	inflateClusterDefaults(cluster)
+	if err := inflatePKIs(cluster, false); err != nil {
+		t.Fatalf("inflatePKIs failed: %v", err)
+	}
+
+	// an etcd client cert signed by kube-ca
+	spec, err := getLeafCertSpec(cluster, "etcd-client")
+	if err != nil {
+		t.Fatalf("getLeafCertSpec failed: %v", err)
+	}
+	params, err := makeCSRParams(cluster, spec)
+	if err != nil {
+		t.Fatalf("makeCSRParams failed: %v", err)
+	}
+	kubeCAPair := cluster.Secrets.PKIs["kube-ca"]
+	kubeCA, err := makeCertCombo(&kubeCAPair)
+	if err != nil {
+		t.Fatalf("makeCertCombo failed: %v", err)
+	}
+	cert, err := pkiutil.MakeCert(params, pkiutil.NewLocalSigner(kubeCA))
+	if err != nil {
+		t.Fatalf("MakeCert failed: %v", err)
+	}
+	wrongCA, err := makeCertPair(cert)
+	if err != nil {
+		t.Fatalf("makeCertPair failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		update  func(cluster *api.Cluster)
+		years   int // inspected this many years from now
+		wantErr string
+	}{
+		{"issued by its CA", func(cluster *api.Cluster) {}, 0, ""},
+		{"issued by another CA", func(cluster *api.Cluster) { cluster.Secrets.PKIs["etcd-client"] = wrongCA }, 0, "does not chain to etcd-ca"},
+		{"issued by the previous CA", func(cluster *api.Cluster) {
+			cluster.Secrets.PKIs["etcd-ca-previous"] = cluster.Secrets.PKIs["etcd-ca"]
+			cluster.Secrets.PKIs["etcd-ca"] = cluster.Secrets.PKIs["kube-ca"]
+		}, 0, ""},
+		{"CA expired", func(cluster *api.Cluster) {}, 20, "does not chain to etcd-ca"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := *cluster
+			c.Secrets.PKIs = map[string]api.CertPair{}
+			for name, pair := range cluster.Secrets.PKIs {
+				c.Secrets.PKIs[name] = pair
+			}
+			tt.update(&c)
+
+			report := inspectCerts(&c, &CertsOptions{}, time.Now().AddDate(tt.years, 0, 0))
+			errors := ""
+			for _, certReport := range report.Certs {
+				if certReport.Name == "etcd-client" {
+					errors = strings.Join(certReport.Errors, ", ")
+				}
+			}
+			switch {
+			case tt.wantErr == "" && errors != "":
+				t.Errorf("unexpected errors: %s", errors)
+			case tt.wantErr != "" && !strings.Contains(errors, tt.wantErr):
+				t.Errorf("errors %q do not contain %q", errors, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package kaptain
+
+import (
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/utils/pkiutil"
+)
+
+// issueClientCert signs a client cert for the user with the signer of kube-ca and records
+// its serial and expiry in the cluster secrets. The groups of the user are set as the
+// organisations of the cert, which the apiserver maps to groups.
+func issueClientCert(cluster *api.Cluster, username string, groups []string, validFor time.Duration) (*pkiutil.CertCombo, error) {
+	if username == "" {
+		return nil, fmt.Errorf("username cannot be empty")
+	}
+	if validFor <= 0 {
+		return nil, fmt.Errorf("invalid validity %s", validFor)
+	}
+
+	caPair, exists := cluster.Secrets.PKIs["kube-ca"]
+	if !exists {
+		return nil, fmt.Errorf("PKI not found: kube-ca")
+	}
+	ca, err := makeCertCombo(&caPair)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CA kube-ca: %v", err)
+	}
+	if time.Now().Add(validFor).After(ca.Cert.NotAfter) {
+		return nil, fmt.Errorf("cert would be valid for longer than kube-ca, which expires at %s", ca.Cert.NotAfter.UTC().Format(time.RFC3339))
+	}
+
+	spec := certSpec{username, "kube-ca", func(cluster *api.Cluster) pkiutil.CSRParams {
+		return pkiutil.CSRParams{
+			Subject: pkix.Name{
+				CommonName:   username,
+				Organization: groups,
+			},
+			Profile: pkiutil.Client,
+		}
+	}}
+	params, err := makeCSRParams(cluster, &spec)
+	if err != nil {
+		return nil, err
+	}
+	params.ValidFor = validFor
+
+	signer, err := createSigner(cluster, "kube-ca", ca)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client cert for %s: %v", username, err)
+	}
+	cert, err := pkiutil.MakeCert(params, signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client cert for %s: %v", username, err)
+	}
+
+	cluster.Secrets.ClientCerts = append(cluster.Secrets.ClientCerts, api.ClientCertRecord{
+		Username:  username,
+		Groups:    groups,
+		Serial:    fmt.Sprintf("%x", cert.Cert.SerialNumber),
+		NotBefore: cert.Cert.NotBefore,
+		NotAfter:  cert.Cert.NotAfter,
+	})
+
+	return cert, nil
+}
+
+func printClientCerts(records []api.ClientCertRecord, now time.Time, out io.Writer) {
+	data := make([][]string, len(records))
+	for i, r := range records {
+		status := "valid"
+		if now.After(r.NotAfter) {
+			status = "expired"
+		}
+		data[i] = []string{r.Username, strings.Join(r.Groups, ","), r.Serial, formatCertTime(r.NotBefore), formatCertTime(r.NotAfter), status}
+	}
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"User", "Groups", "Serial", "Not Before", "Not After", "Status"})
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(false)
+	table.AppendBulk(data)
+	table.Render()
+}
@@ -0,0 +1,226 @@
+package kaptain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/utils/cryptoutil"
+)
+
+// AdminPolicyRole is the role of kaptain itself, which has access to all data of a cluster
+const AdminPolicyRole = "admin"
+
+type iamPolicy struct {
+	Version   string         `json:"Version"`
+	Statement []iamStatement `json:"Statement"`
+}
+
+type iamStatement struct {
+	Sid       string                         `json:"Sid"`
+	Effect    string                         `json:"Effect"`
+	Action    []string                       `json:"Action"`
+	Resource  []string                       `json:"Resource"`
+	Condition map[string]map[string][]string `json:"Condition,omitempty"`
+}
+
+// WritePolicy writes the policy that grants a role access to a cluster in the store of the
+// url, an IAM policy for S3 and an ACL policy for Vault. The admin role can read and write
+// all data of the cluster, any other role can only read the files shared by the role, and
+// the files of a single node of the role if the hostname is set. Data encrypted with AWS
+// KMS also needs the role to decrypt with the key of the key provider url, which is added
+// to IAM policies.
+func WritePolicy(storeUrl string, keyProviderUrl string, clusterName string, role string, hostname string, out io.Writer) error {
+	if clusterName == "" {
+		return fmt.Errorf("cluster name cannot be empty")
+	}
+	if role != AdminPolicyRole && !isClusterRole(role) {
+		return fmt.Errorf("invalid role '%s' (must be one of %s, %s)", role, AdminPolicyRole, strings.Join(clusterRoles, ", "))
+	}
+	if role == AdminPolicyRole && hostname != "" {
+		return fmt.Errorf("the %s role has no node files, hostname must be empty", AdminPolicyRole)
+	}
+
+	// same as the store factory
+	parsedURL, err := url.Parse(strings.ToLower(storeUrl))
+	if err != nil {
+		return fmt.Errorf("failed to parse store url '%s': %v", storeUrl, err)
+	}
+
+	switch parsedURL.Scheme {
+	case "s3":
+		policy := makeS3Policy(parsedURL.Host, clusterName, role, hostname)
+		if statement := makeKMSStatement(keyProviderUrl, role); statement != nil {
+			policy.Statement = append(policy.Statement, *statement)
+		}
+		data, err := json.MarshalIndent(policy, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to serialise policy: %v", err)
+		}
+		fmt.Fprintln(out, string(data))
+	case "vault":
+		if keyID, _, isKMS := cryptoutil.ParseAWSKMSKeyProviderUrl(keyProviderUrl); isKMS {
+			fmt.Fprintf(out, "# data is encrypted with AWS KMS, the IAM role of %s must also be allowed to %s with %s\n", role, strings.Join(getKMSActions(role), ", "), keyID)
+		}
+		writeVaultPolicy(parsedURL.Host+parsedURL.Path, clusterName, role, hostname, out)
+	default:
+		return fmt.Errorf("policies of store '%s' are not supported (must be one of s3, vault)", parsedURL.Scheme)
+	}
+
+	return nil
+}
+
+func makeS3Policy(bucket string, clusterName string, role string, hostname string) *iamPolicy {
+	bucketARN := fmt.Sprintf("arn:aws:s3:::%s", bucket)
+
+	if role == AdminPolicyRole {
+		clusterPrefix := api.MakeClusterPrefix(clusterName)
+		return &iamPolicy{
+			Version: "2012-10-17",
+			Statement: []iamStatement{
+				{
+					Sid:      "KaptainClusterReadWrite",
+					Effect:   "Allow",
+					Action:   []string{"s3:GetObject", "s3:PutObject", "s3:DeleteObject"},
+					Resource: []string{fmt.Sprintf("%s/%s", bucketARN, clusterPrefix), fmt.Sprintf("%s/%s/*", bucketARN, clusterPrefix)},
+				},
+				{
+					Sid:      "KaptainList",
+					Effect:   "Allow",
+					Action:   []string{"s3:GetBucketLocation", "s3:ListBucket"},
+					Resource: []string{bucketARN},
+				},
+			},
+		}
+	}
+
+	// sailor checks the files of its node by listing the role prefix, which only reveals
+	// the hostnames of the other nodes
+	rolePrefix := api.MakeRolePrefix(clusterName, role)
+	resources := []string{}
+	for _, key := range getRoleFilesPaths(clusterName, role, hostname) {
+		resources = append(resources, fmt.Sprintf("%s/%s", bucketARN, key))
+	}
+	return &iamPolicy{
+		Version: "2012-10-17",
+		Statement: []iamStatement{
+			{
+				Sid:      "KaptainRoleReadOnly",
+				Effect:   "Allow",
+				Action:   []string{"s3:GetObject"},
+				Resource: resources,
+			},
+			{
+				Sid:      "KaptainRoleList",
+				Effect:   "Allow",
+				Action:   []string{"s3:ListBucket"},
+				Resource: []string{bucketARN},
+				Condition: map[string]map[string][]string{
+					"StringLike": {"s3:prefix": []string{fmt.Sprintf("%s/*", rolePrefix)}},
+				},
+			},
+		},
+	}
+}
+
+// getRoleFilesPaths returns the keys of the files shared by the role, and of the files of
+// the node if the hostname is set. The files of other nodes hold their private keys.
+func getRoleFilesPaths(clusterName string, role string, hostname string) []string {
+	keys := []string{api.MakeRoleFilesPath(clusterName, role)}
+	if hostname != "" {
+		keys = append(keys, api.MakeRoleFilesPath(clusterName, api.MakeNodeRole(role, hostname)))
+	}
+	return keys
+}
+
+// getKMSActions returns the KMS actions kaptain needs to seal data and sailor needs to open it
+func getKMSActions(role string) []string {
+	if role == AdminPolicyRole {
+		return []string{"kms:Encrypt", "kms:Decrypt"}
+	}
+	return []string{"kms:Decrypt"}
+}
+
+// makeKMSStatement returns the statement that allows the role to use the key of an awskms
+// key provider url, or nil if the url is of another key provider. Keys referred to by an
+// alias are matched by the alias, as aliases are not resources of IAM policies.
+func makeKMSStatement(keyProviderUrl string, role string) *iamStatement {
+	keyID, region, isKMS := cryptoutil.ParseAWSKMSKeyProviderUrl(keyProviderUrl)
+	if !isKMS {
+		return nil
+	}
+	if region == "" {
+		region = "*"
+	}
+
+	statement := &iamStatement{
+		Sid:    "KaptainDecrypt",
+		Effect: "Allow",
+		Action: getKMSActions(role),
+	}
+	if role == AdminPolicyRole {
+		statement.Sid = "KaptainEncryptDecrypt"
+	}
+
+	alias := ""
+	switch {
+	case strings.HasPrefix(keyID, "alias/"):
+		alias = keyID
+		statement.Resource = []string{fmt.Sprintf("arn:aws:kms:%s:*:key/*", region)}
+	case strings.HasPrefix(keyID, "arn:") && strings.Contains(keyID, ":alias/"):
+		// arn:aws:kms:<region>:<account>:alias/<name>
+		i := strings.Index(keyID, ":alias/")
+		alias = keyID[i+1:]
+		statement.Resource = []string{keyID[:i] + ":key/*"}
+	case strings.HasPrefix(keyID, "arn:"):
+		statement.Resource = []string{keyID}
+	default:
+		statement.Resource = []string{fmt.Sprintf("arn:aws:kms:%s:*:key/%s", region, keyID)}
+	}
+	if alias != "" {
+		statement.Condition = map[string]map[string][]string{
+			"ForAnyValue:StringEquals": {"kms:ResourceAliases": []string{alias}},
+		}
+	}
+	return statement
+}
+
+// writeVaultPolicy writes the ACL policy of the paths of the vault store, which are under
+// the generic secret backend
+func writeVaultPolicy(vaultPath string, clusterName string, role string, hostname string, out io.Writer) {
+	storePath := path.Join("secret", vaultPath)
+
+	if role == AdminPolicyRole {
+		clusterPath := path.Join(storePath, api.MakeClusterPrefix(clusterName))
+		fmt.Fprintf(out, "# kaptain, read and write all data of cluster %s\n", clusterName)
+		fmt.Fprintf(out, "path \"%s\" {\n  capabilities = [\"create\", \"read\", \"update\", \"delete\", \"list\"]\n}\n\n", clusterPath)
+		fmt.Fprintf(out, "path \"%s/*\" {\n  capabilities = [\"create\", \"read\", \"update\", \"delete\", \"list\"]\n}\n\n", clusterPath)
+		fmt.Fprintf(out, "path \"%s/\" {\n  capabilities = [\"list\"]\n}\n", storePath)
+		return
+	}
+
+	rolePath := path.Join(storePath, api.MakeRolePrefix(clusterName, role))
+	if hostname != "" {
+		fmt.Fprintf(out, "# sailor on node %s of role %s, read the files of the role and node of cluster %s\n", hostname, role, clusterName)
+	} else {
+		fmt.Fprintf(out, "# sailor on the nodes of role %s, read the files of the role of cluster %s\n", role, clusterName)
+	}
+	for _, key := range getRoleFilesPaths(clusterName, role, hostname) {
+		fmt.Fprintf(out, "path \"%s\" {\n  capabilities = [\"read\"]\n}\n\n", path.Join(storePath, key))
+	}
+	fmt.Fprintf(out, "path \"%s/\" {\n  capabilities = [\"list\"]\n}\n\n", rolePath)
+	fmt.Fprintf(out, "path \"%s/nodes/\" {\n  capabilities = [\"list\"]\n}\n", rolePath)
+}
+
+func isClusterRole(role string) bool {
+	for _, r := range clusterRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -5,8 +5,10 @@ import (
 	"encoding/base64"
 	"encoding/csv"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"github.com/javefang/kaptain/pkg/api"
@@ -31,10 +33,79 @@ func createFilesFromClusterSpec(role string, cluster *api.Cluster) (*api.Cluster
 	}
 }
 
+// createAllFilesFromClusterSpec renders the cluster files of every role
+func createAllFilesFromClusterSpec(cluster *api.Cluster) (map[string]*api.ClusterFiles, error) {
+	roleFiles := map[string]*api.ClusterFiles{}
+	for _, role := range clusterRoles {
+		clusterFiles, err := createFilesFromClusterSpec(role, cluster)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to render cluster files for %s: %v", role, err)
+		}
+		roleFiles[role] = clusterFiles
+	}
+	for _, role := range []string{"etcd", "master"} {
+		for _, node := range getClusterNodes(cluster, role) {
+			nodeRole := api.MakeNodeRole(role, node.Hostname)
+			clusterFiles, err := createNodeFilesFromClusterSpec(role, node, cluster)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to render cluster files for %s: %v", nodeRole, err)
+			}
+			roleFiles[nodeRole] = clusterFiles
+		}
+	}
+	return roleFiles, nil
+}
+
+// createNodeFilesFromClusterSpec renders the files of a single node of a role, which
+// sailor provisions on top of the files of the role
+func createNodeFilesFromClusterSpec(role string, node clusterNode, cluster *api.Cluster) (*api.ClusterFiles, error) {
+	r := createRenderer(cluster)
+	r.clusterFiles.Annotations = map[string]string{
+		api.NodeHostnameAnnotation: node.Hostname,
+		api.NodeIPAnnotation:       node.IP,
+	}
+
+	switch role {
+	case "etcd":
+		r.privateFileOwner = etcdUser
+		r.renderX509Cert(makeNodeCertName("etcd-server", node.Hostname), EtcdServerCert)
+		r.renderX509Key(makeNodeCertName("etcd-server", node.Hostname), EtcdServerKey)
+		r.renderX509Cert(makeNodeCertName("etcd-peer", node.Hostname), EtcdPeerCert)
+		r.renderX509Key(makeNodeCertName("etcd-peer", node.Hostname), EtcdPeerKey)
+	case "master":
+		r.renderX509Cert(makeNodeCertName("kubernetes", node.Hostname), KubeCert)
+		r.renderX509Key(makeNodeCertName("kubernetes", node.Hostname), KubeKey)
+		if _, exists := r.cluster.Secrets.PKIs[makeNodeCertName("kubelet-server", node.Hostname)]; exists {
+			r.renderX509Cert(makeNodeCertName("kubelet-server", node.Hostname), KubeletServerCert)
+			r.renderX509Key(makeNodeCertName("kubelet-server", node.Hostname), KubeletServerKey)
+		}
+	default:
+		return nil, fmt.Errorf("Invalid node role: %s", role)
+	}
+
+	return r.clusterFiles, r.err
+}
+
 func createEtcdFiles(r *renderer) (*api.ClusterFiles, error) {
-	r.renderX509Cert("etcd-ca", EtcdCACert)
-	r.renderX509Cert("etcd-server", EtcdServerCert)
-	r.renderX509Key("etcd-server", EtcdServerKey)
+	r.privateFileOwner = etcdUser
+	r.renderCABundle("etcd-ca", EtcdCACert)
+
+	// with node certs only, every member gets its certs from its node files
+	nodeCertsOnly := r.cluster.Spec.PKIOpts.NodeCertsOnly
+	if !nodeCertsOnly {
+		r.renderX509Cert("etcd-server", EtcdServerCert)
+		r.renderX509Key("etcd-server", EtcdServerKey)
+	}
+
+	// clusters made by older versions have no peer cert and etcd config until their PKIs
+	// and asset manifest are inflated again
+	if _, exists := r.cluster.Secrets.PKIs["etcd-peer"]; exists && !nodeCertsOnly {
+		r.renderX509Cert("etcd-peer", EtcdPeerCert)
+		r.renderX509Key("etcd-peer", EtcdPeerKey)
+	}
+	if _, exists := r.files["config.etcd"]; exists {
+		r.renderEtcdMemberConfigs("config.etcd", EtcdMemberConfig)
+	}
 
 	return r.clusterFiles, r.err
 }
@@ -47,20 +118,37 @@ func createMasterFiles(r *renderer) (*api.ClusterFiles, error) {
 	r.renderNodeFile("sysconfig.kubelet.master", SysconfigKubeletKaptainExtra)
 
 	// PKIs
-	r.renderX509Cert("etcd-ca", KubeEtcdCA)
+	r.renderCABundle("etcd-ca", KubeEtcdCA)
 	r.renderX509Cert("etcd-client", KubeEtcdClientCert)
 	r.renderX509Key("etcd-client", KubeEtcdClientKey)
-	r.renderX509Cert("kube-ca", KubeCACert)
-	r.renderX509Key("kube-ca", KubeCAKey)
-	r.renderX509Cert("kubernetes", KubeCert)
-	r.renderX509Key("kubernetes", KubeKey)
+	r.renderCABundle("kube-ca", KubeCACert)
+	// kube-controller-manager only signs the CSRs of kubelets if kaptain holds the kube-ca key
+	if !isExternalCA(r.cluster, "kube-ca") {
+		r.renderX509Cert("kube-ca", KubeCASigningCert)
+		r.renderX509Key("kube-ca", KubeCAKey)
+	}
+	if !r.cluster.Spec.PKIOpts.NodeCertsOnly {
+		r.renderX509Cert("kubernetes", KubeCert)
+		r.renderX509Key("kubernetes", KubeKey)
+	}
+
+	// clusters made by older versions have no front proxy CA and service account key until
+	// their PKIs are inflated again by 'kaptain apply'
+	if _, exists := r.cluster.Secrets.PKIs["front-proxy-ca"]; exists {
+		r.renderCABundle("front-proxy-ca", KubeFrontProxyCACert)
+		r.renderX509Cert("front-proxy-client", KubeFrontProxyClientCert)
+		r.renderX509Key("front-proxy-client", KubeFrontProxyClientKey)
+	}
+	if _, exists := r.cluster.Secrets.KeyPairs[serviceAccountKeyName]; exists {
+		r.renderServiceAccountKeys(KubeServiceAccountKey, KubeServiceAccountPublicKey)
+	}
 
 	// Tokens
 	r.renderTokenCsv(KubeTokenCsv)
 
 	// Kubeconfigs
-	r.renderKubeConfig(makeKubeConfig(r.cluster, "kube-controller-manager"), KubeControllerManagerConfig)
-	r.renderKubeConfig(makeKubeConfig(r.cluster, "kube-scheduler"), KubeSchedulerConfig)
+	r.renderX509KubeConfig("kube-controller-manager", KubeControllerManagerConfig)
+	r.renderX509KubeConfig("kube-scheduler", KubeSchedulerConfig)
 	r.renderKubeConfig(makeKubeletMasterConfig(r.cluster), KubeletConfig)
 
 	// Manifests
@@ -70,7 +158,7 @@ func createMasterFiles(r *renderer) (*api.ClusterFiles, error) {
 
 	// Cloud provider specific config
 	if r.cluster.Spec.CloudProvider == "vsphere" {
-		r.renderNodeFile("config.cloud-config.vsphere", KubeCloudConfig)
+		r.renderSensitiveNodeFile("config.cloud-config.vsphere", KubeCloudConfig)
 	}
 
 	// Auth Token Webhook specific config
@@ -87,7 +175,7 @@ func createWorkerFiles(r *renderer) (*api.ClusterFiles, error) {
 
 	// worker specific files
 	r.renderNodeFile("sysconfig.kubelet.worker", SysconfigKubeletKaptainExtra)
-	r.renderKubeConfig(makeKubeletBootstrapConfig(r.cluster), KubeletBootstrapConfig)
+	r.renderKubeletBootstrapConfig(KubeletBootstrapConfig)
 
 	return r.clusterFiles, r.err
 }
@@ -95,6 +183,12 @@ func createWorkerFiles(r *renderer) (*api.ClusterFiles, error) {
 func createBootstrapperFiles(r *renderer) (*api.ClusterFiles, error) {
 	r.renderAddon("rbac-kube-system")
 	r.renderAddon("rbac-node-bootstrap")
+	if r.cluster.Spec.KubeletBootstrapOpts.Mode == KubeletBootstrapToken && len(r.cluster.Secrets.BootstrapTokens) > 0 {
+		r.renderSensitiveAddon("bootstrap-tokens")
+	}
+	if r.cluster.Spec.KubeletBootstrapOpts.ServerTLSBootstrap {
+		r.renderAddon("kubelet-serving-csr")
+	}
 	r.renderAddon("calico")
 	r.renderAddon("coredns")
 	r.renderAddon("heapster")
@@ -116,43 +210,60 @@ func createKubeNodeFiles(r *renderer) {
 	r.renderNodeFile("sysconfig.kubelet", SysconfigKubeletKaptain)
 	r.renderNodeFile("sysconfig.kube-proxy", SysconfigKubeProxyKaptain)
 
-	r.renderKubeConfig(makeKubeConfig(r.cluster, "kube-proxy"), KubeProxyConfig)
+	r.renderX509KubeConfig("kube-proxy", KubeProxyConfig)
 }
 
 // Util: create a ClusterFile
 func createClusterFile(path string, data []byte) *api.ClusterFile {
-	return &api.ClusterFile{
-		Path:       path,
-		DataBase64: base64.StdEncoding.EncodeToString(data),
-	}
+	return api.NewClusterFile(path, data)
 }
 
 // Util: make token.csv file for the apiserver
 func makeTokenCsv(tokenSecretMap map[string]api.TokenSecret) ([]byte, error) {
-	rows := make([][]string, len(tokenSecretMap))
-	i := 0
-	for _, v := range tokenSecretMap {
+	// sort by name, so the file only changes when the tokens change
+	names := make([]string, 0, len(tokenSecretMap))
+	for name := range tokenSecretMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rows := make([][]string, len(names))
+	for i, name := range names {
+		v := tokenSecretMap[name]
 		groupStr := strings.Join(v.Groups, ",")
 		rows[i] = []string{v.Token, v.Username, strconv.Itoa(v.UID), groupStr}
-		i++
 	}
 	return rowsToCSV(rows)
 }
 
 // Util: Make kubeconfig with x509 credentials for the specified username
-func makeKubeConfig(c *api.Cluster, username string) *clientcmdapi.Config {
+func makeKubeConfig(c *api.Cluster, username string) (*clientcmdapi.Config, error) {
 	clusterName := c.Name
 	apiserverURL := fmt.Sprintf("https://%s", c.Spec.MasterPublicName)
-	apiserverCAData := c.Secrets.PKIs["kube-ca"].GetCertData()
-	certPair := c.Secrets.PKIs[username]
+	apiserverCAData, err := c.Secrets.GetServerCABundle("kube-ca")
+	if err != nil {
+		return nil, err
+	}
+	certPair, exists := c.Secrets.PKIs[username]
+	if !exists {
+		return nil, fmt.Errorf("PKI not found: %s", username)
+	}
+	certData, err := certPair.GetCertData()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", username, err)
+	}
+	keyData, err := certPair.GetKeyData()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", username, err)
+	}
 
 	cluster := clientcmdapi.NewCluster()
 	cluster.Server = apiserverURL
 	cluster.CertificateAuthorityData = apiserverCAData
 
 	authInfo := clientcmdapi.NewAuthInfo()
-	authInfo.ClientCertificateData = certPair.GetCertData()
-	authInfo.ClientKeyData = certPair.GetKeyData()
+	authInfo.ClientCertificateData = certData
+	authInfo.ClientKeyData = keyData
 
 	context := clientcmdapi.NewContext()
 	context.Cluster = clusterName
@@ -165,23 +276,36 @@ func makeKubeConfig(c *api.Cluster, username string) *clientcmdapi.Config {
 
 	config.CurrentContext = "default"
 
-	return config
+	return config, nil
 }
 
 // Util: Make bootstrap kubeconfig for kubelet running on worker nodes
-func makeKubeletBootstrapConfig(c *api.Cluster) *clientcmdapi.Config {
+func makeKubeletBootstrapConfig(c *api.Cluster) (*clientcmdapi.Config, error) {
 	username := "kubelet-bootstrap"
 	clusterName := c.Name
 	apiserverURL := fmt.Sprintf("https://%s", c.Spec.MasterPublicName)
-	apiserverCAData := c.Secrets.PKIs["kube-ca"].GetCertData()
-	tokenSecret := c.Secrets.TokenSecrets[username]
+	apiserverCAData, err := c.Secrets.GetServerCABundle("kube-ca")
+	if err != nil {
+		return nil, err
+	}
 
 	cluster := clientcmdapi.NewCluster()
 	cluster.Server = apiserverURL
 	cluster.CertificateAuthorityData = apiserverCAData
 
 	authInfo := clientcmdapi.NewAuthInfo()
-	authInfo.Token = tokenSecret.Token
+	switch c.Spec.KubeletBootstrapOpts.Mode {
+	case KubeletBootstrapStaticToken, "":
+		authInfo.Token = c.Secrets.TokenSecrets[username].Token
+	case KubeletBootstrapToken:
+		token, found := getLatestBootstrapToken(c, time.Now())
+		if !found {
+			return nil, fmt.Errorf("all bootstrap tokens of cluster '%s' are expired or revoked, workers cannot join, run 'kaptain token create'", c.Name)
+		}
+		authInfo.Token = token.Token()
+	default:
+		return nil, fmt.Errorf("invalid kubelet bootstrap mode '%s' (must be one of %s, %s)", c.Spec.KubeletBootstrapOpts.Mode, KubeletBootstrapStaticToken, KubeletBootstrapToken)
+	}
 
 	context := clientcmdapi.NewContext()
 	context.Cluster = clusterName
@@ -194,7 +318,7 @@ func makeKubeletBootstrapConfig(c *api.Cluster) *clientcmdapi.Config {
 
 	config.CurrentContext = defaultContextName
 
-	return config
+	return config, nil
 }
 
 // Util: Make kubeconfig for kubelet on master
@@ -250,14 +374,75 @@ type renderer struct {
 	addons map[string]api.NodeFile
 	err    error
 
+	// owner of the private files, the user of sailor if empty
+	privateFileOwner string
+
 	clusterFiles *api.ClusterFiles
 }
 
+// setReload sets the reload command of a cluster file from the reload commands of its path
+func setReload(clusterFile *api.ClusterFile, path string) {
+	clusterFile.Reload = reloadCommands[path]
+	clusterFile.QuorumReload = quorumReloadCommands[clusterFile.Reload]
+}
+
 func (r *renderer) appendClusterFile(clusterFile *api.ClusterFile) {
+	setReload(clusterFile, clusterFile.Path)
 	r.clusterFiles.Spec.ClusterFiles = append(r.clusterFiles.Spec.ClusterFiles, clusterFile)
 }
 
+func (r *renderer) appendSensitiveClusterFile(clusterFile *api.ClusterFile) {
+	clusterFile.Sensitive = true
+	r.appendClusterFile(clusterFile)
+}
+
+// appendPrivateClusterFile appends a sensitive file that only its owner can read, such as a key
+func (r *renderer) appendPrivateClusterFile(clusterFile *api.ClusterFile) {
+	r.setPrivate(clusterFile)
+	r.appendClusterFile(clusterFile)
+}
+
+// setPrivate makes a file sensitive and only readable by its owner. Dirs created for the
+// files of another owner must stay open for the owner to reach them.
+func (r *renderer) setPrivate(clusterFile *api.ClusterFile) {
+	clusterFile.Sensitive = true
+	clusterFile.Mode = privateFileMode
+	if r.privateFileOwner != "" {
+		clusterFile.Owner = r.privateFileOwner
+		clusterFile.Group = r.privateFileOwner
+	} else {
+		clusterFile.DirMode = privateDirMode
+	}
+}
+
 func (r *renderer) renderNodeFile(templateName string, path string) {
+	r.renderNodeFileWithArgs(templateName, path, r.cluster)
+}
+
+// etcdMemberConfigArgs are the args of the etcd config template of a member
+type etcdMemberConfigArgs struct {
+	Cluster *api.Cluster
+	Member  api.EtcdMember
+}
+
+// renderEtcdMemberConfigs renders the etcd config of every member, pathFormat is formatted
+// with the member hostname
+func (r *renderer) renderEtcdMemberConfigs(templateName string, pathFormat string) {
+	for _, member := range r.cluster.Spec.EtcdCluster.Members {
+		args := etcdMemberConfigArgs{Cluster: r.cluster, Member: member}
+		r.renderNodeFileWithArgs(templateName, fmt.Sprintf(pathFormat, member.Hostname), args)
+		if r.err != nil {
+			return
+		}
+
+		// reload commands are keyed by the path format
+		files := r.clusterFiles.Spec.ClusterFiles
+		setReload(files[len(files)-1], pathFormat)
+	}
+}
+
+// renderNodeFileWithArgs renders a node file template with args instead of the cluster
+func (r *renderer) renderNodeFileWithArgs(templateName string, path string, args interface{}) {
 	if r.err != nil {
 		return
 	}
@@ -268,7 +453,7 @@ func (r *renderer) renderNodeFile(templateName string, path string) {
 		return
 	}
 	templatePath := fmt.Sprintf("assets/files/%s/%s", nodeFile.Name, nodeFile.Version)
-	data, err := fileutil.RenderTemplate(templatePath, r.cluster)
+	data, err := fileutil.RenderTemplate(templatePath, args)
 	if err != nil {
 		r.err = err
 		return
@@ -277,6 +462,17 @@ func (r *renderer) renderNodeFile(templateName string, path string) {
 	r.appendClusterFile(createClusterFile(path, data))
 }
 
+// renderSensitiveNodeFile renders a node file template that contains secrets from the spec
+func (r *renderer) renderSensitiveNodeFile(templateName string, path string) {
+	r.renderNodeFile(templateName, path)
+	if r.err != nil {
+		return
+	}
+
+	files := r.clusterFiles.Spec.ClusterFiles
+	r.setPrivate(files[len(files)-1])
+}
+
 func (r *renderer) renderAddon(templateName string) {
 	if r.err != nil {
 		return
@@ -299,6 +495,17 @@ func (r *renderer) renderAddon(templateName string) {
 	r.appendClusterFile(createClusterFile(path, data))
 }
 
+// renderSensitiveAddon renders an addon that contains secrets, such as bootstrap tokens
+func (r *renderer) renderSensitiveAddon(templateName string) {
+	r.renderAddon(templateName)
+	if r.err != nil {
+		return
+	}
+
+	files := r.clusterFiles.Spec.ClusterFiles
+	r.setPrivate(files[len(files)-1])
+}
+
 func (r *renderer) renderKubeConfig(config *clientcmdapi.Config, path string) {
 	if r.err != nil {
 		return
@@ -310,7 +517,36 @@ func (r *renderer) renderKubeConfig(config *clientcmdapi.Config, path string) {
 		return
 	}
 
-	r.appendClusterFile(createClusterFile(path, data))
+	r.appendPrivateClusterFile(createClusterFile(path, data))
+}
+
+// renderX509KubeConfig renders a kubeconfig with the x509 credentials of the PKI of a user
+func (r *renderer) renderX509KubeConfig(username string, path string) {
+	if r.err != nil {
+		return
+	}
+
+	config, err := makeKubeConfig(r.cluster, username)
+	if err != nil {
+		r.err = err
+		return
+	}
+
+	r.renderKubeConfig(config, path)
+}
+
+func (r *renderer) renderKubeletBootstrapConfig(path string) {
+	if r.err != nil {
+		return
+	}
+
+	config, err := makeKubeletBootstrapConfig(r.cluster)
+	if err != nil {
+		r.err = err
+		return
+	}
+
+	r.renderKubeConfig(config, path)
 }
 
 func (r *renderer) renderX509Cert(name string, path string) {
@@ -318,8 +554,66 @@ func (r *renderer) renderX509Cert(name string, path string) {
 		return
 	}
 
-	data := r.cluster.Secrets.PKIs[name].GetCertData()
-	r.appendClusterFile(createClusterFile(path, data))
+	certPair, exists := r.cluster.Secrets.PKIs[name]
+	if !exists {
+		r.err = fmt.Errorf("PKI not found: %s", name)
+		return
+	}
+	data, err := certPair.GetCertData()
+	if err != nil {
+		r.err = fmt.Errorf("%s: %v", name, err)
+		return
+	}
+	r.appendSensitiveClusterFile(createClusterFile(path, data))
+}
+
+// renderCABundle renders the certs of a CA and of its ongoing rotation, if any
+func (r *renderer) renderCABundle(caName string, path string) {
+	if r.err != nil {
+		return
+	}
+
+	if _, exists := r.cluster.Secrets.PKIs[caName]; !exists {
+		r.err = fmt.Errorf("PKI not found: %s", caName)
+		return
+	}
+	data, err := r.cluster.Secrets.GetCABundle(caName)
+	if err != nil {
+		r.err = err
+		return
+	}
+	r.appendSensitiveClusterFile(createClusterFile(path, data))
+}
+
+// renderServiceAccountKeys renders the key that signs service account tokens and the
+// public keys that verify them
+func (r *renderer) renderServiceAccountKeys(keyPath string, publicKeyPath string) {
+	if r.err != nil {
+		return
+	}
+
+	keyPair := r.cluster.Secrets.KeyPairs[serviceAccountKeyName]
+	keyData, err := keyPair.GetPrivateKeyData()
+	if err != nil {
+		r.err = fmt.Errorf("%s: %v", serviceAccountKeyName, err)
+		return
+	}
+	publicKeyData, err := keyPair.GetPublicKeyData()
+	if err != nil {
+		r.err = fmt.Errorf("%s: %v", serviceAccountKeyName, err)
+		return
+	}
+	if legacyKeyPair, exists := r.cluster.Secrets.KeyPairs[legacyServiceAccountKeyName]; exists {
+		legacyData, err := legacyKeyPair.GetPublicKeyData()
+		if err != nil {
+			r.err = fmt.Errorf("%s: %v", legacyServiceAccountKeyName, err)
+			return
+		}
+		publicKeyData = append(publicKeyData, legacyData...)
+	}
+
+	r.appendPrivateClusterFile(createClusterFile(keyPath, keyData))
+	r.appendSensitiveClusterFile(createClusterFile(publicKeyPath, publicKeyData))
 }
 
 func (r *renderer) renderX509Key(name string, path string) {
@@ -327,8 +621,17 @@ func (r *renderer) renderX509Key(name string, path string) {
 		return
 	}
 
-	data := r.cluster.Secrets.PKIs[name].GetKeyData()
-	r.appendClusterFile(createClusterFile(path, data))
+	certPair, exists := r.cluster.Secrets.PKIs[name]
+	if !exists {
+		r.err = fmt.Errorf("PKI not found: %s", name)
+		return
+	}
+	data, err := certPair.GetKeyData()
+	if err != nil {
+		r.err = fmt.Errorf("%s: %v", name, err)
+		return
+	}
+	r.appendPrivateClusterFile(createClusterFile(path, data))
 }
 
 func (r *renderer) renderTokenCsv(path string) {
@@ -336,12 +639,23 @@ func (r *renderer) renderTokenCsv(path string) {
 		return
 	}
 
-	data, err := makeTokenCsv(r.cluster.Secrets.TokenSecrets)
+	tokenSecrets := r.cluster.Secrets.TokenSecrets
+	if r.cluster.Spec.KubeletBootstrapOpts.Mode == KubeletBootstrapToken {
+		// workers join with bootstrap tokens, the static token must not work
+		tokenSecrets = map[string]api.TokenSecret{}
+		for name, tokenSecret := range r.cluster.Secrets.TokenSecrets {
+			if name != "kubelet-bootstrap" {
+				tokenSecrets[name] = tokenSecret
+			}
+		}
+	}
+
+	data, err := makeTokenCsv(tokenSecrets)
 	if err != nil {
 		r.err = err
 		return
 	}
-	r.appendClusterFile(createClusterFile(path, data))
+	r.appendPrivateClusterFile(createClusterFile(path, data))
 }
 
 func (r *renderer) renderDataBase64(dataBase64 string, path string) {
@@ -349,10 +663,12 @@ func (r *renderer) renderDataBase64(dataBase64 string, path string) {
 		return
 	}
 
-	r.appendClusterFile(&api.ClusterFile{
-		Path:       path,
-		DataBase64: dataBase64,
-	})
+	data, err := base64.StdEncoding.DecodeString(dataBase64)
+	if err != nil {
+		r.err = fmt.Errorf("%s: %v", path, err)
+		return
+	}
+	r.appendPrivateClusterFile(createClusterFile(path, data))
 }
 
 func rowsToCSV(rows [][]string) ([]byte, error) {
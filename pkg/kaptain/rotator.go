@@ -0,0 +1,179 @@
+package kaptain
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/utils/pkiutil"
+)
+
+// CA rotation stages, they must be run in this order with the cluster files of every
+// node provisioned in between
+const (
+	// CARotationTrust creates the next CA and adds it to the CA bundles
+	CARotationTrust = "trust"
+	// CARotationSign promotes the next CA and reissues all certs it signs
+	CARotationSign = "sign"
+	// CARotationFinish removes the previous CA from the CA bundles
+	CARotationFinish = "finish"
+)
+
+// rotateLeafCerts reissues the named leaf certs with the existing CAs
+func rotateLeafCerts(cluster *api.Cluster, certNames []string) error {
+	if len(certNames) == 0 {
+		return fmt.Errorf("no cert to rotate")
+	}
+
+	for _, name := range certNames {
+		if _, err := getLeafCertSpec(cluster, name); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range certNames {
+		log.Infof("Rotating cert: %s", name)
+		delete(cluster.Secrets.PKIs, name)
+	}
+
+	return inflatePKIs(cluster, false)
+}
+
+// rotateCA runs a stage of the rotation of a CA. While the rotation is in progress the
+// CA bundles contain both the old and the new CA, so nodes trust the certs of either.
+func rotateCA(cluster *api.Cluster, caName string, stage string) error {
+	spec, err := getCACertSpec(caName)
+	if err != nil {
+		return err
+	}
+
+	nextName := caName + "-next"
+	previousName := caName + "-previous"
+	_, hasNext := cluster.Secrets.PKIs[nextName]
+	_, hasPrevious := cluster.Secrets.PKIs[previousName]
+
+	switch stage {
+	case CARotationTrust:
+		if hasNext || hasPrevious {
+			return fmt.Errorf("rotation of CA '%s' is already in progress", caName)
+		}
+		if err := checkCARotationSupported(cluster, caName); err != nil {
+			return err
+		}
+
+		params, err := makeCSRParams(cluster, spec)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Creating next CA: %s", nextName)
+		ca, err := pkiutil.InitCA(params)
+		if err != nil {
+			return fmt.Errorf("failed to create CA %s: %v", nextName, err)
+		}
+		if cluster.Secrets.PKIs[nextName], err = makeCertPair(ca); err != nil {
+			return fmt.Errorf("failed to create CA %s: %v", nextName, err)
+		}
+	case CARotationSign:
+		if !hasNext {
+			return fmt.Errorf("CA '%s' has no next CA, run the '%s' stage first", caName, CARotationTrust)
+		}
+
+		log.Infof("Promoting %s to %s", nextName, caName)
+		cluster.Secrets.PKIs[previousName] = cluster.Secrets.PKIs[caName]
+		cluster.Secrets.PKIs[caName] = cluster.Secrets.PKIs[nextName]
+		delete(cluster.Secrets.PKIs, nextName)
+
+		for _, leaf := range getAllLeafCertSpecs(cluster) {
+			if leaf.caName == caName {
+				log.Infof("Rotating cert: %s", leaf.name)
+				delete(cluster.Secrets.PKIs, leaf.name)
+			}
+		}
+		if err := inflatePKIs(cluster, false); err != nil {
+			return err
+		}
+
+		if caName == "kube-ca" && usesKubeCAForServiceAccounts(cluster) {
+			log.Warnf("The service account signing key has changed, service account tokens must be recreated")
+		}
+	case CARotationFinish:
+		if !hasPrevious {
+			return fmt.Errorf("CA '%s' has no previous CA, run the '%s' stage first", caName, CARotationSign)
+		}
+
+		log.Infof("Removing previous CA: %s", previousName)
+		delete(cluster.Secrets.PKIs, previousName)
+	default:
+		return fmt.Errorf("invalid CA rotation stage '%s' (must be one of %s, %s, %s)", stage, CARotationTrust, CARotationSign, CARotationFinish)
+	}
+
+	return nil
+}
+
+// checkCARotationSupported verifies the CA was created by kaptain, which holds its key,
+// and the cluster files do not use the CA bundle where a single CA cert is required
+func checkCARotationSupported(cluster *api.Cluster, caName string) error {
+	if cluster.Secrets.PKIs[caName].Imported {
+		return fmt.Errorf("CA '%s' was imported and cannot be rotated by kaptain", caName)
+	}
+	if isExternalCA(cluster, caName) {
+		return fmt.Errorf("CA '%s' is held by signer %s and cannot be rotated by kaptain", caName, getSignerUrl(cluster, caName))
+	}
+
+	if caName != "kube-ca" {
+		return nil
+	}
+
+	// kube-controller-manager signs CSRs with the CA of ca-signing.pem since v1.10
+	for _, f := range cluster.AssetManifest.Files {
+		if f.Name == "manifest.kube-controller-manager" && f.Version == "v1.8" {
+			return fmt.Errorf("CA '%s' cannot be rotated with %s, update the asset manifest first", caName, f)
+		}
+	}
+
+	return nil
+}
+
+// usesKubeCAForServiceAccounts returns true if the apiserver template of the cluster signs
+// service account tokens with the kube-ca key instead of the service-account key pair
+func usesKubeCAForServiceAccounts(cluster *api.Cluster) bool {
+	for _, f := range cluster.AssetManifest.Files {
+		if f.Name == "manifest.kube-apiserver" && (f.Version == "v1.8" || f.Version == "v1.10") {
+			return true
+		}
+	}
+	return false
+}
+
+// getLeafCertNames returns the names of all leaf certs of a cluster, node certs included
+func getLeafCertNames(cluster *api.Cluster) []string {
+	specs := getAllLeafCertSpecs(cluster)
+	names := make([]string, len(specs))
+	for i, spec := range specs {
+		names[i] = spec.name
+	}
+	return names
+}
+
+func getLeafCertSpec(cluster *api.Cluster, name string) (*certSpec, error) {
+	specs := getAllLeafCertSpecs(cluster)
+	for i := range specs {
+		if specs[i].name == name {
+			return &specs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown cert '%s' (must be one of %s)", name, strings.Join(getLeafCertNames(cluster), ", "))
+}
+
+func getCACertSpec(name string) (*certSpec, error) {
+	names := make([]string, len(caCertSpecs))
+	for i := range caCertSpecs {
+		if caCertSpecs[i].name == name {
+			return &caCertSpecs[i], nil
+		}
+		names[i] = caCertSpecs[i].name
+	}
+	return nil, fmt.Errorf("unknown CA '%s' (must be one of %s)", name, strings.Join(names, ", "))
+}
@@ -0,0 +1,128 @@
+package kaptain
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/utils/pkiutil"
+)
+
+// SetSigners sets the signers of the CAs of a new cluster. A signer is caName=url, e.g.
+// etcd-ca=vault-pki://pki/etcd, only local:// may be given without a CA to sign the certs
+// of every CA locally. Each external signer holds a single CA, and the kube-ca key must be
+// held by kaptain.
+func SetSigners(cluster *api.Cluster, signers []string) error {
+	for _, s := range signers {
+		caNames := make([]string, len(caCertSpecs))
+		for i, spec := range caCertSpecs {
+			caNames[i] = spec.name
+		}
+
+		signerUrl := s
+		if parts := strings.SplitN(s, "=", 2); len(parts) == 2 && !strings.Contains(parts[0], "://") {
+			if _, err := getCACertSpec(parts[0]); err != nil {
+				return err
+			}
+			caNames, signerUrl = []string{parts[0]}, parts[1]
+		}
+		if err := pkiutil.ValidateSignerUrl(signerUrl); err != nil {
+			return err
+		}
+		if len(caNames) > 1 && !pkiutil.IsLocalSignerUrl(signerUrl) {
+			return fmt.Errorf("signer %s must be given for a single CA as <ca-name>=%s", signerUrl, signerUrl)
+		}
+
+		for _, caName := range caNames {
+			if _, exists := cluster.Secrets.PKIs[caName]; exists {
+				return fmt.Errorf("cluster already has CA '%s', its signer cannot be changed", caName)
+			}
+			if cluster.Spec.PKIOpts.Signers == nil {
+				cluster.Spec.PKIOpts.Signers = map[string]string{}
+			}
+			if pkiutil.IsLocalSignerUrl(signerUrl) {
+				delete(cluster.Spec.PKIOpts.Signers, caName)
+			} else {
+				cluster.Spec.PKIOpts.Signers[caName] = signerUrl
+			}
+		}
+	}
+
+	// CAs of the same signer are the same CA, so each would trust the clients of the others
+	casBySigner := map[string][]string{}
+	for caName, signerUrl := range cluster.Spec.PKIOpts.Signers {
+		casBySigner[signerUrl] = append(casBySigner[signerUrl], caName)
+	}
+	for signerUrl, caNames := range casBySigner {
+		if len(caNames) > 1 {
+			sort.Strings(caNames)
+			return fmt.Errorf("CAs %s cannot share the signer %s, each of them would trust the certs signed for the others", strings.Join(caNames, ", "), signerUrl)
+		}
+	}
+	// workers join through CSRs signed by kube-controller-manager with the kube-ca key
+	if isExternalCA(cluster, "kube-ca") {
+		return fmt.Errorf("kube-ca cannot have signer %s, kube-controller-manager needs its key to sign the CSRs of kubelets", getSignerUrl(cluster, "kube-ca"))
+	}
+
+	return nil
+}
+
+// getSignerUrl returns the signer url of a CA, empty if its certs are signed locally
+func getSignerUrl(cluster *api.Cluster, caName string) string {
+	return cluster.Spec.PKIOpts.Signers[caName]
+}
+
+// isExternalCA returns true if the key of a CA is held by its signer instead of kaptain
+func isExternalCA(cluster *api.Cluster, caName string) bool {
+	return !pkiutil.IsLocalSignerUrl(getSignerUrl(cluster, caName))
+}
+
+// createSigner returns the signer of a CA, an external signer must sign with the CA of
+// the cluster secrets
+func createSigner(cluster *api.Cluster, caName string, ca *pkiutil.CertCombo) (pkiutil.Signer, error) {
+	signerUrl := getSignerUrl(cluster, caName)
+	signer, err := pkiutil.CreateSignerFromUrl(signerUrl, ca)
+	if err != nil {
+		return nil, err
+	}
+	if !isExternalCA(cluster, caName) {
+		return signer, nil
+	}
+
+	signerCA, _, err := signer.GetCA()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(signerCA.Raw, ca.Cert.Raw) {
+		return nil, fmt.Errorf("CA '%s' of the cluster is not the CA of signer %s", caName, signerUrl)
+	}
+	return signer, nil
+}
+
+// fetchExternalCA returns the cert and chain of a CA from its signer, without a key
+func fetchExternalCA(cluster *api.Cluster, caName string) (api.CertPair, error) {
+	signerUrl := getSignerUrl(cluster, caName)
+	signer, err := pkiutil.CreateSignerFromUrl(signerUrl, nil)
+	if err != nil {
+		return api.CertPair{}, err
+	}
+	ca, chain, err := signer.GetCA()
+	if err != nil {
+		return api.CertPair{}, err
+	}
+
+	chainPEM := []byte{}
+	for _, c := range chain {
+		chainPEM = append(chainPEM, (&pkiutil.CertCombo{Cert: c}).ExtractCertData()...)
+	}
+	log.Infof("Using CA %s of signer %s: %s, expires at %s", caName, signerUrl, ca.Subject, formatCertTime(ca.NotAfter))
+
+	return api.CertPair{
+		CertData:  base64.StdEncoding.EncodeToString((&pkiutil.CertCombo{Cert: ca}).ExtractCertData()),
+		ChainData: base64.StdEncoding.EncodeToString(chainPEM),
+	}, nil
+}
@@ -0,0 +1,51 @@
+package kaptain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/javefang/kaptain/pkg/api"
+)
+
+func TestSetSigners(t *testing.T) {
+	tests := []struct {
+		name    string
+		signers []string
+		want    map[string]string
+		wantErr string
+	}{
+		{"local for every CA", []string{"local://"}, map[string]string{}, ""},
+		{"vault for etcd-ca", []string{"etcd-ca=vault-pki://pki/etcd"}, map[string]string{"etcd-ca": "vault-pki://pki/etcd"}, ""},
+		{"vault for each CA", []string{"etcd-ca=vault-pki://pki/etcd", "front-proxy-ca=vault-pki://pki/front-proxy"}, map[string]string{"etcd-ca": "vault-pki://pki/etcd", "front-proxy-ca": "vault-pki://pki/front-proxy"}, ""},
+		{"vault for every CA", []string{"vault-pki://pki/kaptain"}, nil, "must be given for a single CA"},
+		{"CAs sharing a signer", []string{"etcd-ca=vault-pki://pki/kaptain", "front-proxy-ca=vault-pki://pki/kaptain"}, nil, "cannot share the signer"},
+		{"vault for kube-ca", []string{"kube-ca=vault-pki://pki/kube"}, nil, "kube-ca cannot have signer"},
+		{"unknown CA", []string{"my-ca=vault-pki://pki/kube"}, nil, "my-ca"},
+		{"invalid url", []string{"etcd-ca=vault-pki://pki"}, nil, "invalid signer url"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &api.Cluster{}
+			err := SetSigners(cluster, tt.signers)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != "" && err == nil:
+				t.Fatalf("expected an error containing %q", tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Fatalf("error %q does not contain %q", err, tt.wantErr)
+			case tt.wantErr != "":
+				return
+			}
+			for caName, signerUrl := range tt.want {
+				if got := getSignerUrl(cluster, caName); got != signerUrl {
+					t.Errorf("signer of %s is %q, want %q", caName, got, signerUrl)
+				}
+			}
+			if len(cluster.Spec.PKIOpts.Signers) != len(tt.want) {
+				t.Errorf("signers are %v, want %v", cluster.Spec.PKIOpts.Signers, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package kaptain
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/utils/secretutil"
+)
+
+// lengths of the ID and secret of bootstrap tokens, fixed by Kubernetes
+const bootstrapTokenIDLength = 6
+const bootstrapTokenSecretLength = 16
+
+// createBootstrapToken adds a bootstrap token expiring after ttl to the cluster
+func createBootstrapToken(cluster *api.Cluster, ttl time.Duration, description string, now time.Time) (*api.BootstrapToken, error) {
+	if cluster.Spec.KubeletBootstrapOpts.Mode != KubeletBootstrapToken {
+		return nil, fmt.Errorf("bootstrap tokens require kubelet bootstrap mode '%s'", KubeletBootstrapToken)
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("invalid TTL %s", ttl)
+	}
+
+	if cluster.Secrets.BootstrapTokens == nil {
+		cluster.Secrets.BootstrapTokens = map[string]api.BootstrapToken{}
+	}
+
+	id, err := secretutil.MakeRandomLowerToken(bootstrapTokenIDLength)
+	if err != nil {
+		return nil, err
+	}
+	if _, exists := cluster.Secrets.BootstrapTokens[id]; exists {
+		return nil, fmt.Errorf("bootstrap token '%s' already exists", id)
+	}
+	secret, err := secretutil.MakeRandomLowerToken(bootstrapTokenSecretLength)
+	if err != nil {
+		return nil, err
+	}
+
+	token := api.BootstrapToken{
+		ID:          id,
+		Secret:      secret,
+		Description: description,
+		Expiration:  now.Add(ttl).UTC().Truncate(time.Second),
+	}
+	cluster.Secrets.BootstrapTokens[id] = token
+
+	return &token, nil
+}
+
+// revokeBootstrapToken removes a bootstrap token from the cluster
+func revokeBootstrapToken(cluster *api.Cluster, id string) error {
+	if _, exists := cluster.Secrets.BootstrapTokens[id]; !exists {
+		return fmt.Errorf("bootstrap token '%s' not found", id)
+	}
+
+	delete(cluster.Secrets.BootstrapTokens, id)
+
+	return nil
+}
+
+// getLatestBootstrapToken returns the unexpired bootstrap token that expires last, which is
+// used by the bootstrap kubeconfig of workers
+func getLatestBootstrapToken(cluster *api.Cluster, now time.Time) (api.BootstrapToken, bool) {
+	var latest api.BootstrapToken
+	found := false
+	for _, token := range cluster.Secrets.BootstrapTokens {
+		if !now.Before(token.Expiration) {
+			continue
+		}
+		if !found || token.Expiration.After(latest.Expiration) {
+			latest = token
+			found = true
+		}
+	}
+	return latest, found
+}
+
+func printBootstrapTokens(tokens map[string]api.BootstrapToken, now time.Time, out io.Writer) {
+	ids := make([]string, 0, len(tokens))
+	for id := range tokens {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	data := make([][]string, len(ids))
+	for i, id := range ids {
+		token := tokens[id]
+		status := "valid"
+		if now.After(token.Expiration) {
+			status = "expired"
+		}
+		data[i] = []string{id, formatCertTime(token.Expiration), token.Description, status}
+	}
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"ID", "Expiration", "Description", "Status"})
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(false)
+	table.AppendBulk(data)
+	table.Render()
+}
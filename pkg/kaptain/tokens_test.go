@@ -0,0 +1,47 @@
+package kaptain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/javefang/kaptain/pkg/api"
+)
+
+func TestGetLatestBootstrapToken(t *testing.T) {
+	now := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	tokens := map[string]api.BootstrapToken{
+		"expired": {ID: "expired", Expiration: now.Add(-time.Hour)},
+		"now":     {ID: "now", Expiration: now},
+		"day":     {ID: "day", Expiration: now.Add(24 * time.Hour)},
+		"week":    {ID: "week", Expiration: now.Add(7 * 24 * time.Hour)},
+	}
+
+	tests := []struct {
+		name   string
+		ids    []string
+		wantID string // empty if no token is found
+	}{
+		{"no tokens", []string{}, ""},
+		{"latest of valid tokens", []string{"day", "week"}, "week"},
+		{"valid and expired tokens", []string{"expired", "day"}, "day"},
+		{"all expired", []string{"expired", "now"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &api.Cluster{}
+			cluster.Secrets.BootstrapTokens = map[string]api.BootstrapToken{}
+			for _, id := range tt.ids {
+				cluster.Secrets.BootstrapTokens[id] = tokens[id]
+			}
+
+			token, found := getLatestBootstrapToken(cluster, now)
+			if found != (tt.wantID != "") {
+				t.Fatalf("found is %v, expected token '%s'", found, tt.wantID)
+			}
+			if found && token.ID != tt.wantID {
+				t.Errorf("token is '%s', expected '%s'", token.ID, tt.wantID)
+			}
+		})
+	}
+}
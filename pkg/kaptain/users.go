@@ -0,0 +1,126 @@
+package kaptain
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/utils/secretutil"
+)
+
+// systemUsers are the token users created with the cluster, they cannot be removed
+var systemUsers = map[string]bool{
+	"admin":             true,
+	"kubelet-bootstrap": true,
+}
+
+// firstUserUID is the lowest UID picked for users added without one
+const firstUserUID = 2000
+
+// addUser adds a token user to the cluster. A free UID is picked if uid is 0.
+func addUser(cluster *api.Cluster, username string, uid int, groups []string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+	if _, exists := cluster.Secrets.TokenSecrets[username]; exists {
+		return fmt.Errorf("user '%s' already exists", username)
+	}
+
+	if uid < 0 {
+		return fmt.Errorf("invalid UID %d", uid)
+	} else if uid == 0 {
+		uid = getNextFreeUID(cluster)
+	} else if owner := getUIDOwner(cluster, uid); owner != "" {
+		return fmt.Errorf("UID %d is already used by user '%s'", uid, owner)
+	}
+
+	token, err := secretutil.MakeRandomToken(defaultTokenLength)
+	if err != nil {
+		return err
+	}
+
+	cluster.Secrets.TokenSecrets[username] = api.TokenSecret{
+		Username: username,
+		Token:    token,
+		UID:      uid,
+		Groups:   groups,
+	}
+
+	return nil
+}
+
+// removeUser removes a token user from the cluster
+func removeUser(cluster *api.Cluster, username string) error {
+	if systemUsers[username] {
+		return fmt.Errorf("user '%s' is a system user and cannot be removed", username)
+	}
+	if _, exists := cluster.Secrets.TokenSecrets[username]; !exists {
+		return fmt.Errorf("user '%s' not found", username)
+	}
+
+	delete(cluster.Secrets.TokenSecrets, username)
+
+	return nil
+}
+
+// rotateUserToken replaces the token of a user
+func rotateUserToken(cluster *api.Cluster, username string) error {
+	tokenSecret, exists := cluster.Secrets.TokenSecrets[username]
+	if !exists {
+		return fmt.Errorf("user '%s' not found", username)
+	}
+
+	token, err := secretutil.MakeRandomToken(defaultTokenLength)
+	if err != nil {
+		return err
+	}
+	tokenSecret.Token = token
+	cluster.Secrets.TokenSecrets[username] = tokenSecret
+
+	return nil
+}
+
+func getUIDOwner(cluster *api.Cluster, uid int) string {
+	for name, tokenSecret := range cluster.Secrets.TokenSecrets {
+		if tokenSecret.UID == uid {
+			return name
+		}
+	}
+	return ""
+}
+
+func getNextFreeUID(cluster *api.Cluster) int {
+	uid := firstUserUID
+	for getUIDOwner(cluster, uid) != "" {
+		uid++
+	}
+	return uid
+}
+
+func printUsers(tokenSecrets map[string]api.TokenSecret, out io.Writer) {
+	names := make([]string, 0, len(tokenSecrets))
+	for name := range tokenSecrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	data := make([][]string, len(names))
+	for i, name := range names {
+		tokenSecret := tokenSecrets[name]
+		system := ""
+		if systemUsers[name] {
+			system = "yes"
+		}
+		data[i] = []string{name, strconv.Itoa(tokenSecret.UID), strings.Join(tokenSecret.Groups, ","), system}
+	}
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"User", "UID", "Groups", "System"})
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(false)
+	table.AppendBulk(data)
+	table.Render()
+}
@@ -0,0 +1,227 @@
+package sailor
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/utils/systemdutil"
+)
+
+// Agent provisions the node whenever the files of its role or node change in the store,
+// and runs the reload commands of the files it changed
+type Agent struct {
+	Client   *SailorClient
+	Interval time.Duration // time between polls
+	Jitter   float64       // fraction of the interval each poll is moved by at random
+
+	// run the reload commands that restart a member of a quorum, such as etcd. The members
+	// of a role reload within the same interval, which could lose the quorum.
+	QuorumReloads bool
+
+	appliedVersion string
+	pendingReloads []string // reload commands of changed files that have not succeeded yet
+	pollStarted    int64    // unix nanos of the poll in progress, 0 between polls
+}
+
+// Run polls the store until stop is closed. It notifies systemd when the first poll is
+// done and pings the watchdog of the service, if enabled, unless a poll hangs.
+func (a *Agent) Run(stop <-chan struct{}) error {
+	if a.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	if a.Jitter < 0 || a.Jitter >= 1 {
+		return fmt.Errorf("jitter must be between 0 and 1")
+	}
+
+	// pinged from its own goroutine, so a slow poll does not miss a ping
+	if timeout, enabled := systemdutil.WatchdogInterval(); enabled {
+		done := make(chan struct{})
+		defer close(done)
+		go a.pingWatchdog(timeout, done)
+	}
+
+	rand.Seed(time.Now().UnixNano())
+	a.poll()
+	notify(systemdutil.NotifyReady)
+
+	timer := time.NewTimer(a.nextInterval())
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			notify(systemdutil.NotifyStopping)
+			return nil
+		case <-timer.C:
+			a.poll()
+			timer.Reset(a.nextInterval())
+		}
+	}
+}
+
+// pingWatchdog notifies the watchdog of systemd twice per timeout until done is closed.
+// Pings stop while a poll runs for longer than the timeout, such as a store call or a
+// reload command that hangs, so systemd restarts the agent.
+func (a *Agent) pingWatchdog(timeout time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if started := atomic.LoadInt64(&a.pollStarted); started != 0 && time.Since(time.Unix(0, started)) > timeout {
+				log.Errorf("SAILOR: poll has been running since %s, not pinging the watchdog", time.Unix(0, started).Format(time.RFC3339))
+				continue
+			}
+			notify(systemdutil.NotifyWatchdog)
+		}
+	}
+}
+
+func (a *Agent) nextInterval() time.Duration {
+	jitter := (rand.Float64()*2 - 1) * a.Jitter
+	return time.Duration(float64(a.Interval) * (1 + jitter))
+}
+
+// poll provisions the node if the files have changed since the last poll, errors are
+// logged and the poll is retried by the next one
+func (a *Agent) poll() {
+	atomic.StoreInt64(&a.pollStarted, time.Now().UnixNano())
+	defer atomic.StoreInt64(&a.pollStarted, 0)
+
+	if err := a.provisionIfChanged(); err != nil {
+		log.Errorf("SAILOR: %v", err)
+		notifyStatus(fmt.Sprintf("Failed at %s: %v", time.Now().Format(time.RFC3339), err))
+	}
+}
+
+func (a *Agent) provisionIfChanged() error {
+	c := a.Client
+
+	version, err := a.getVersion()
+	if err != nil {
+		return err
+	}
+	if version == a.appliedVersion && len(a.pendingReloads) == 0 {
+		log.Debugf("SAILOR: files of %s have not changed", c.Role)
+		return nil
+	}
+
+	unlock, err := c.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if version != a.appliedVersion {
+		result, err := c.Provision()
+		if err != nil {
+			return fmt.Errorf("failed to provision node: %v", err)
+		}
+		if result.Changed() {
+			var summary bytes.Buffer
+			result.PrintSummary(&summary)
+			for _, line := range strings.Split(strings.TrimSpace(summary.String()), "\n") {
+				log.Infof("SAILOR: %s", line)
+			}
+		}
+		// failed commands are dropped once no file declares them any more
+		declared := map[string]bool{}
+		for _, f := range result.Files {
+			declared[f.Reload] = true
+		}
+		reloads := []string{}
+		for _, command := range a.pendingReloads {
+			if declared[command] {
+				reloads = append(reloads, command)
+			}
+		}
+		for _, f := range result.Files {
+			if f.Reload == "" || (f.Status != FileCreated && f.Status != FileUpdated) {
+				continue
+			}
+			if f.QuorumReload && !a.QuorumReloads {
+				log.Warnf("SAILOR: %s has changed, run '%s' once the other members are healthy", f.Path, f.Reload)
+				continue
+			}
+			reloads = appendIfMissing(reloads, f.Reload)
+		}
+		a.pendingReloads = reloads
+		// files changed after the version was read are applied by the next poll, which
+		// finds nothing to change
+		a.appliedVersion = version
+	}
+
+	a.pendingReloads = runReloads(a.pendingReloads)
+	if len(a.pendingReloads) > 0 {
+		return fmt.Errorf("reload commands failed, retrying with the next poll: %s", strings.Join(a.pendingReloads, "; "))
+	}
+
+	notifyStatus(fmt.Sprintf("Provisioned at %s", time.Now().Format(time.RFC3339)))
+	return nil
+}
+
+// getVersion returns the version of the files of the role and node, it only reads the
+// files if the store has no versions
+func (a *Agent) getVersion() (string, error) {
+	c := a.Client
+
+	version, err := c.Registry.GetFilesVersion(c.ClusterName, c.Role)
+	if err != nil {
+		return "", err
+	}
+	if version == "" {
+		return "", fmt.Errorf("cluster '%s' has no files for role '%s'", c.ClusterName, c.Role)
+	}
+
+	if c.Hostname != "" && c.Role != "worker" {
+		nodeVersion, err := c.Registry.GetFilesVersion(c.ClusterName, api.MakeNodeRole(c.Role, c.Hostname))
+		if err != nil {
+			return "", err
+		}
+		version += "," + nodeVersion
+	}
+	return version, nil
+}
+
+// runReloads runs each reload command once and returns the commands that failed
+func runReloads(commands []string) []string {
+	failed := []string{}
+	for _, command := range commands {
+		log.Infof("SAILOR: running reload command: %s", command)
+		output, err := exec.Command("/bin/sh", "-c", command).CombinedOutput()
+		if err != nil {
+			log.Errorf("SAILOR: reload command '%s' failed: %v: %s", command, err, strings.TrimSpace(string(output)))
+			failed = append(failed, command)
+		}
+	}
+	return failed
+}
+
+func appendIfMissing(commands []string, command string) []string {
+	for _, c := range commands {
+		if c == command {
+			return commands
+		}
+	}
+	return append(commands, command)
+}
+
+func notify(state string) {
+	if _, err := systemdutil.Notify(state); err != nil {
+		log.Warnf("SAILOR: %v", err)
+	}
+}
+
+func notifyStatus(status string) {
+	if _, err := systemdutil.NotifyStatus(status); err != nil {
+		log.Warnf("SAILOR: %v", err)
+	}
+}
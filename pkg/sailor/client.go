@@ -8,5 +8,9 @@ type SailorClient struct {
 	Role        string
 	ClusterName string
 	Prefix      string
+	Hostname    string // fetch the node files of this host on top of the role files
+	IP          string // expected IP of the node files, checked if set
+	StateFile   string // state file of the files written, under the prefix if empty
+	DryRun      bool   // compare the files with the node without writing them
 	Registry    *api.ClusterRegistry
 }
@@ -0,0 +1,90 @@
+package sailor
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/utils/diffutil"
+)
+
+const redactedChanged = "<redacted: changed>"
+
+// makeDryRunResult returns the files that a provisioning run would change, with the diff
+// of each. The content of sensitive files, such as keys and tokens, is never printed.
+func (c *SailorClient) makeDryRunResult(planned []*plannedFile, removals []FileChange) (*ProvisionResult, error) {
+	result := &ProvisionResult{}
+	for _, p := range planned {
+		change := FileChange{Path: p.fullPath, Status: p.status}
+		if p.status != FileUnchanged {
+			diff, err := c.diffFile(p)
+			if err != nil {
+				return nil, err
+			}
+			change.Diff = diff
+		}
+		result.Files = append(result.Files, change)
+	}
+	for _, removal := range removals {
+		if removal.Status == FileRemoved {
+			removal.Diff = fmt.Sprintf("%s: removed\n", removal.Path)
+		}
+		result.Files = append(result.Files, removal)
+	}
+	return result, nil
+}
+
+// diffFile returns the unified diff of the file on the node and the cluster file
+func (c *SailorClient) diffFile(p *plannedFile) (string, error) {
+	nodeData, err := ioutil.ReadFile(p.fullPath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("Failed to read %s: %v", p.fullPath, err)
+	}
+
+	diff := ""
+	if p.status == FileUpdated && p.nodeMode != p.mode {
+		diff = fmt.Sprintf("%s: mode %04o -> %04o\n", p.fullPath, p.nodeMode, p.mode)
+	}
+	if p.status == FileUpdated && p.ownerChanged() {
+		diff += fmt.Sprintf("%s: owner %d:%d -> %s:%s\n", p.fullPath, p.nodeUID, p.nodeGID, p.file.Owner, p.file.Group)
+	}
+	sensitive := isSensitive(p.file, p.data) || isSensitive(p.file, nodeData)
+	switch {
+	case p.status == FileCreated && sensitive:
+		diff += fmt.Sprintf("%s: created %s\n", p.fullPath, redactedChanged)
+	case api.Checksum(nodeData) == p.checksum:
+	case sensitive:
+		diff += fmt.Sprintf("%s: %s\n", p.fullPath, redactedChanged)
+	default:
+		clusterName := path.Join(c.ClusterName, c.Role, p.file.Path)
+		diff += diffutil.Unified(p.fullPath, clusterName, nodeData, p.data)
+	}
+	return diff, nil
+}
+
+// markers of data that holds keys, tokens or passwords
+var sensitiveMarkers = [][]byte{
+	[]byte("PRIVATE KEY-----"),
+	[]byte("client-key-data:"),
+	[]byte("token:"),
+	[]byte("password:"),
+	[]byte("kind: Secret"),
+}
+
+// isSensitive returns true if the file is flagged as sensitive or its data looks like it
+// holds a secret. Files saved by kaptain versions without the flag, including the files of
+// the previous layout, are only recognised by their content.
+func isSensitive(file *api.ClusterFile, data []byte) bool {
+	if file.Sensitive || path.Base(file.Path) == "token.csv" {
+		return true
+	}
+	for _, marker := range sensitiveMarkers {
+		if bytes.Contains(data, marker) {
+			return true
+		}
+	}
+	return false
+}
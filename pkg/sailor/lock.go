@@ -0,0 +1,37 @@
+package sailor
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"syscall"
+
+	"github.com/javefang/kaptain/pkg/utils/fileutil"
+)
+
+const lockFileName = "sailor.lock"
+
+// Lock takes the lock of the node, which is next to the state file, so only one sailor
+// provisions the node at a time. It fails if the node is locked, the returned func
+// releases the lock.
+func (c *SailorClient) Lock() (func(), error) {
+	lockFile := path.Join(path.Dir(c.getStateFile()), lockFileName)
+	if err := fileutil.MakeParentDirs(lockFile, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create lock file: %v", err)
+	}
+
+	f, err := os.OpenFile(lockFile, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %v", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, fmt.Errorf("node is locked by another sailor (%s)", lockFile)
+		}
+		return nil, fmt.Errorf("failed to lock %s: %v", lockFile, err)
+	}
+
+	// closing the file releases the lock
+	return func() { f.Close() }, nil
+}
@@ -2,17 +2,18 @@ package sailor
 
 import (
 	"fmt"
+	"os"
 	"path"
+	"path/filepath"
 
 	log "github.com/sirupsen/logrus"
-	"github.com/javefang/kaptain/pkg/utils/fileutil"
+	"github.com/javefang/kaptain/pkg/api"
 )
 
-func makeClusterFilesPath(clusterName string, role string) string {
-	return path.Join(clusterName, "roles", fmt.Sprintf("%s.yaml", role))
-}
-
-func (c *SailorClient) Provision() error {
+// Provision writes the files of the role and node that have changed, and removes the
+// files written by the last run that are no longer provisioned. A dry run only compares
+// the files with the node and returns the diff of each changed file.
+func (c *SailorClient) Provision() (*ProvisionResult, error) {
 	logCtx := log.Fields{
 		"cluster": c.ClusterName,
 		"role":    c.Role,
@@ -23,9 +24,107 @@ func (c *SailorClient) Provision() error {
 	log.WithFields(logCtx).Debug("SAILOR: fetching cluster files")
 	clusterFiles, err := c.Registry.GetFiles(c.ClusterName, c.Role)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	files := clusterFiles.Spec.ClusterFiles
+	if c.Hostname != "" && c.Role != "worker" {
+		if files, err = c.addNodeFiles(files); err != nil {
+			return nil, err
+		}
+	}
+
+	stateFile := c.getStateFile()
+	state, err := loadState(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	planned, err := planFiles(c.Prefix, files)
+	if err != nil {
+		return nil, err
+	}
+	removals, err := planRemovals(c.Prefix, state, planned)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.DryRun {
+		log.WithFields(logCtx).Infof("SAILOR: comparing all files with prefix: %s, nothing is written", c.Prefix)
+		return c.makeDryRunResult(planned, removals)
 	}
 
 	log.WithFields(logCtx).Infof("SAILOR: writing all files with prefix: %s", c.Prefix)
-	return fileutil.WriteAll(c.Prefix, clusterFiles.Spec.ClusterFiles)
+	if err := writeFiles(planned); err != nil {
+		return nil, err
+	}
+
+	result := &ProvisionResult{}
+	newState := &provisionState{Cluster: c.ClusterName, Role: c.Role, Hostname: c.Hostname, Files: map[string]string{}}
+	for _, p := range planned {
+		result.Files = append(result.Files, FileChange{Path: p.fullPath, Status: p.status, Reload: p.file.Reload, QuorumReload: p.file.QuorumReload})
+		newState.Files[p.file.Path] = p.checksum
+	}
+	for _, removal := range removals {
+		if removal.Status == FileRemoved {
+			log.Infof("removing file: %s", removal.Path)
+			if err := os.Remove(removal.Path); err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("Error removing file %s: %v", removal.Path, err)
+			}
+		} else {
+			// reported again by the next run, until it is removed by hand
+			filePath, _ := filepath.Rel(c.Prefix, removal.Path)
+			newState.Files[filePath] = state.Files[filePath]
+		}
+		result.Files = append(result.Files, removal)
+	}
+
+	if err := saveState(stateFile, newState); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// getStateFile returns the state file of the node, which is under the prefix by default
+func (c *SailorClient) getStateFile() string {
+	if c.StateFile != "" {
+		return c.StateFile
+	}
+	return path.Join(c.Prefix, DefaultStateFile)
+}
+
+// addNodeFiles returns the role files with the files of the node of the host, a node file
+// replaces the role file of the same path
+func (c *SailorClient) addNodeFiles(files []*api.ClusterFile) ([]*api.ClusterFile, error) {
+	nodeRole := api.MakeNodeRole(c.Role, c.Hostname)
+
+	exists, err := c.Registry.FilesExist(c.ClusterName, nodeRole)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		// the node is not in the cluster spec or the cluster has not been applied since
+		log.Warnf("SAILOR: no node files for %s, using the files shared by all nodes of the role", nodeRole)
+		return files, nil
+	}
+
+	log.Debugf("SAILOR: fetching node files of %s", nodeRole)
+	nodeFiles, err := c.Registry.GetFiles(c.ClusterName, nodeRole)
+	if err != nil {
+		return nil, err
+	}
+	if ip := nodeFiles.Annotations[api.NodeIPAnnotation]; c.IP != "" && ip != c.IP {
+		return nil, fmt.Errorf("node files of %s are issued for IP '%s', not '%s' (set the IP of the node in the cluster spec)", nodeRole, ip, c.IP)
+	}
+
+	nodePaths := map[string]bool{}
+	for _, f := range nodeFiles.Spec.ClusterFiles {
+		nodePaths[f.Path] = true
+	}
+	merged := []*api.ClusterFile{}
+	for _, f := range files {
+		if !nodePaths[f.Path] {
+			merged = append(merged, f)
+		}
+	}
+	return append(merged, nodeFiles.Spec.ClusterFiles...), nil
 }
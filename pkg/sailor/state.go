@@ -0,0 +1,61 @@
+package sailor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/javefang/kaptain/pkg/utils/fileutil"
+)
+
+// DefaultStateFile is the path of the state file under the prefix
+const DefaultStateFile = "var/lib/sailor/state.json"
+
+// provisionState records the files written by the last provisioning run, so files dropped
+// from the role since can be removed
+type provisionState struct {
+	Cluster  string            `json:"cluster"`
+	Role     string            `json:"role"`
+	Hostname string            `json:"hostname,omitempty"`
+	Files    map[string]string `json:"files"` // checksums of the files written, by path under the prefix
+}
+
+// loadState reads the state file, a node that has never been provisioned has an empty state
+func loadState(stateFile string) (*provisionState, error) {
+	state := &provisionState{Files: map[string]string{}}
+
+	data, err := ioutil.ReadFile(stateFile)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %v", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %v", stateFile, err)
+	}
+	if state.Files == nil {
+		state.Files = map[string]string{}
+	}
+	return state, nil
+}
+
+func saveState(stateFile string, state *provisionState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialise state: %v", err)
+	}
+
+	if err := fileutil.MakeParentDirs(stateFile, 0700); err != nil {
+		return fmt.Errorf("failed to write state file: %v", err)
+	}
+	tmpFile, err := fileutil.WriteTemp(data, stateFile, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %v", err)
+	}
+	if err := os.Rename(tmpFile, stateFile); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("failed to write state file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package sailor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/javefang/kaptain/pkg/api"
+	"github.com/javefang/kaptain/pkg/utils/fileutil"
+)
+
+// statuses of the files of a provisioning run
+const (
+	FileCreated   = "created"
+	FileUpdated   = "updated"
+	FileUnchanged = "unchanged"
+	FileRemoved   = "removed"
+	FileModified  = "modified" // dropped from the role but changed on the node since, so kept
+)
+
+const defaultFileMode = 0644
+const defaultDirMode = 0755
+
+// FileChange is the status of a file after a provisioning run
+type FileChange struct {
+	Path   string
+	Status string
+	Diff   string // diff of the file on the node and the cluster file, dry runs only
+	Reload string // command that makes the service of the file pick up the change
+
+	QuorumReload bool // the reload command restarts a member of a quorum, such as etcd
+}
+
+// ProvisionResult lists the files of a provisioning run
+type ProvisionResult struct {
+	Files []FileChange
+}
+
+// Count returns the number of files of the status
+func (r *ProvisionResult) Count(status string) int {
+	count := 0
+	for _, f := range r.Files {
+		if f.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// Changed returns true if any file has been created, updated or removed
+func (r *ProvisionResult) Changed() bool {
+	return r.Count(FileCreated)+r.Count(FileUpdated)+r.Count(FileRemoved) > 0
+}
+
+// PrintDiffs prints the diff of every changed file of a dry run
+func (r *ProvisionResult) PrintDiffs(out io.Writer) {
+	for _, f := range r.Files {
+		fmt.Fprint(out, f.Diff)
+	}
+}
+
+// PrintSummary prints the files that are not unchanged and the number of files of each status
+func (r *ProvisionResult) PrintSummary(out io.Writer) {
+	for _, f := range r.Files {
+		if f.Status != FileUnchanged {
+			fmt.Fprintf(out, "%-9s %s\n", f.Status, f.Path)
+		}
+	}
+	fmt.Fprintf(out, "%d created, %d updated, %d unchanged, %d removed", r.Count(FileCreated), r.Count(FileUpdated), r.Count(FileUnchanged), r.Count(FileRemoved))
+	if modified := r.Count(FileModified); modified > 0 {
+		fmt.Fprintf(out, ", %d modified and kept", modified)
+	}
+	fmt.Fprintln(out)
+}
+
+// plannedFile is a cluster file to be provisioned and its status on the node
+type plannedFile struct {
+	file     *api.ClusterFile
+	fullPath string
+	data     []byte
+	checksum string
+	mode     os.FileMode
+	dirMode  os.FileMode
+	uid      int // owner to set, or -1 to keep
+	gid      int // group to set, or -1 to keep
+	status   string
+
+	nodeMode os.FileMode // mode of the file on the node, if it exists
+	nodeUID  int         // owner of the file on the node, if it exists
+	nodeGID  int         // group of the file on the node, if it exists
+}
+
+// ownerChanged returns true if the owner or group of the file on the node is not the one
+// to set
+func (p *plannedFile) ownerChanged() bool {
+	return (p.uid >= 0 && p.uid != p.nodeUID) || (p.gid >= 0 && p.gid != p.nodeGID)
+}
+
+// planFiles compares the files with the node, a file is unchanged if its data, mode and
+// ownership are
+func planFiles(prefix string, files []*api.ClusterFile) ([]*plannedFile, error) {
+	canChown := os.Geteuid() == 0
+	planned := make([]*plannedFile, len(files))
+	for i, file := range files {
+		p := &plannedFile{file: file, fullPath: path.Join(prefix, file.Path)}
+		p.uid, p.gid = lookupOwner(p, canChown)
+
+		var err error
+		if p.data, err = file.GetVerifiedData(); err != nil {
+			return nil, fmt.Errorf("Failed to decode data for %s: %v", p.fullPath, err)
+		}
+		if p.mode, err = file.GetMode(defaultFileMode); err != nil {
+			return nil, fmt.Errorf("Invalid mode of %s: %v", p.fullPath, err)
+		}
+		if p.dirMode, err = file.GetDirMode(defaultDirMode); err != nil {
+			return nil, fmt.Errorf("Invalid dir mode of %s: %v", p.fullPath, err)
+		}
+		p.checksum = api.Checksum(p.data)
+
+		checksum, mode, err := fileutil.ReadChecksum(p.fullPath)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read %s: %v", p.fullPath, err)
+		}
+		p.nodeMode = mode
+		if checksum != "" {
+			if p.nodeUID, p.nodeGID, err = fileutil.ReadOwner(p.fullPath); err != nil {
+				return nil, fmt.Errorf("Failed to read owner of %s: %v", p.fullPath, err)
+			}
+		}
+		switch {
+		case checksum == "":
+			p.status = FileCreated
+		case checksum != p.checksum || mode != p.mode || p.ownerChanged():
+			p.status = FileUpdated
+		default:
+			p.status = FileUnchanged
+		}
+		planned[i] = p
+	}
+	return planned, nil
+}
+
+// planRemovals returns the files written by the last run that are no longer provisioned,
+// files changed on the node since are kept
+func planRemovals(prefix string, state *provisionState, planned []*plannedFile) ([]FileChange, error) {
+	provisioned := map[string]bool{}
+	for _, p := range planned {
+		provisioned[p.file.Path] = true
+	}
+
+	paths := []string{}
+	for filePath := range state.Files {
+		if !provisioned[filePath] {
+			paths = append(paths, filePath)
+		}
+	}
+	sort.Strings(paths)
+
+	removals := []FileChange{}
+	for _, filePath := range paths {
+		fullPath := path.Join(prefix, filePath)
+		checksum, _, err := fileutil.ReadChecksum(fullPath)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read %s: %v", fullPath, err)
+		}
+		switch checksum {
+		case "":
+			// already removed
+		case state.Files[filePath]:
+			removals = append(removals, FileChange{Path: fullPath, Status: FileRemoved})
+		default:
+			log.Warnf("SAILOR: %s is no longer provisioned but has been changed on the node, keeping it", fullPath)
+			removals = append(removals, FileChange{Path: fullPath, Status: FileModified})
+		}
+	}
+	return removals, nil
+}
+
+// writeFiles writes the changed files to temp files, which are only renamed to the files
+// once all of them are written, so a failed run leaves the files of the node untouched
+func writeFiles(planned []*plannedFile) error {
+	tmpFiles := map[*plannedFile]string{}
+	cleanUp := func() {
+		for _, tmpFile := range tmpFiles {
+			os.Remove(tmpFile)
+		}
+	}
+
+	for _, p := range planned {
+		if p.status == FileUnchanged {
+			continue
+		}
+
+		if err := fileutil.MakeParentDirs(p.fullPath, p.dirMode); err != nil {
+			cleanUp()
+			return fmt.Errorf("Error creating parent dirs of %s: %v", p.fullPath, err)
+		}
+		log.Infof("writing file: %s (len: %d, mode: %04o)", p.fullPath, len(p.data), p.mode)
+		tmpFile, err := fileutil.WriteTemp(p.data, p.fullPath, p.mode)
+		if err != nil {
+			cleanUp()
+			return fmt.Errorf("Error writing file %s: %v", p.fullPath, err)
+		}
+		tmpFiles[p] = tmpFile
+
+		if p.uid >= 0 || p.gid >= 0 {
+			if err := os.Chown(tmpFile, p.uid, p.gid); err != nil {
+				cleanUp()
+				return fmt.Errorf("Error setting owner of %s: %v", p.fullPath, err)
+			}
+		}
+	}
+
+	for _, p := range planned {
+		tmpFile, exists := tmpFiles[p]
+		if !exists {
+			continue
+		}
+		if err := os.Rename(tmpFile, p.fullPath); err != nil {
+			cleanUp()
+			return fmt.Errorf("Error writing file %s: %v", p.fullPath, err)
+		}
+		delete(tmpFiles, p)
+	}
+	return nil
+}
+
+// lookupOwner returns the uid and gid to set on the file of a planned file, or -1 if they
+// cannot be set. Ownership is only applied when running as root, and once the owner
+// exists, so a later run applies it.
+func lookupOwner(p *plannedFile, canChown bool) (int, int) {
+	owner, group := p.file.Owner, p.file.Group
+	if owner == "" && group == "" {
+		return -1, -1
+	}
+	if !canChown {
+		log.Warnf("not running as root, owner of %s is not set to %s:%s", p.fullPath, owner, group)
+		return -1, -1
+	}
+	uid, gid, err := fileutil.LookupOwner(owner, group)
+	if err != nil {
+		// the service of the owner may not be installed yet
+		log.Warnf("owner of %s is not set: %v", p.fullPath, err)
+		return -1, -1
+	}
+	return uid, gid
+}
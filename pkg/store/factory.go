@@ -6,10 +6,20 @@ import (
 	"strings"
 )
 
+// CreateStoreFromUrlOrDie creates the store of the url, it panics if the store cannot be created
 func CreateStoreFromUrlOrDie(storeUrl string) Store {
+	s, err := CreateStoreFromUrl(storeUrl)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// CreateStoreFromUrl creates the store of the url, the scheme selects the backend
+func CreateStoreFromUrl(storeUrl string) (Store, error) {
 	parsedURL, err := url.Parse(strings.ToLower(storeUrl))
 	if err != nil {
-		panic(fmt.Errorf("failed to parse store url '%s': %v", storeUrl, err))
+		return nil, fmt.Errorf("failed to parse store url '%s': %v", storeUrl, err)
 	}
 
 	queries := parsedURL.Query()
@@ -23,9 +33,18 @@ func CreateStoreFromUrlOrDie(storeUrl string) Store {
 		roleID := getFirstOrEmpty(queries, "role_id")
 		secretID := getFirstOrEmpty(queries, "secret_id")
 		vaultPath := parsedURL.Host + parsedURL.Path
-		return createVaultStoreOrDie(vaultPath, roleID, secretID)
+		return createVaultStore(vaultPath, roleID, secretID)
+	case "file":
+		// file paths are case sensitive, so take the directory from the original url
+		rawURL, err := url.Parse(storeUrl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse store url '%s': %v", storeUrl, err)
+		}
+		return createFileStore(rawURL.Host + rawURL.Path)
+	case "mem":
+		return createMemStore(parsedURL.Host + parsedURL.Path), nil
 	default:
-		panic(fmt.Errorf("failed to create store '%s': unknown scheme '%s'", storeUrl, parsedURL.Scheme))
+		return nil, fmt.Errorf("failed to create store '%s': unknown scheme '%s'", storeUrl, parsedURL.Scheme)
 	}
 }
 
@@ -0,0 +1,210 @@
+package store
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const fileStoreDirMode = 0700
+const fileStoreFileMode = 0600
+
+type FileStore struct {
+	BaseDir string
+}
+
+func createFileStore(baseDir string) (Store, error) {
+	if baseDir == "" {
+		return nil, fmt.Errorf("failed to create file store: base directory cannot be empty")
+	}
+
+	if err := os.MkdirAll(baseDir, fileStoreDirMode); err != nil {
+		return nil, fmt.Errorf("failed to create file store base directory '%s': %v", baseDir, err)
+	}
+
+	return &FileStore{
+		BaseDir: baseDir,
+	}, nil
+}
+
+// makeAbsolutePath maps a store key to a path under the base directory. Keys are
+// cleaned as if rooted so that '..' elements can never escape the base directory.
+func (store *FileStore) makeAbsolutePath(key string) string {
+	return filepath.Join(store.BaseDir, filepath.FromSlash(path.Clean("/"+key)))
+}
+
+func (store *FileStore) makeError(action string, key string, err error) error {
+	return fmt.Errorf("failed to %s key '%s' from %s: %v", action, key, store, err)
+}
+
+func (store *FileStore) List(key string) ([]string, error) {
+	store.log(fmt.Sprintf("List key %s", key))
+
+	infos, err := ioutil.ReadDir(store.makeAbsolutePath(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make([]string, 0), nil
+		}
+		return nil, store.makeError("list", key, err)
+	}
+
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		if isTempFile(info.Name()) {
+			continue
+		}
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (store *FileStore) Exists(key string) (bool, error) {
+	store.log(fmt.Sprintf("Head key %s", key))
+
+	info, err := os.Stat(store.makeAbsolutePath(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, store.makeError("head", key, err)
+	}
+
+	return !info.IsDir(), nil
+}
+
+func (store *FileStore) Get(key string) ([]byte, error) {
+	store.log(fmt.Sprintf("Get key %s", key))
+
+	data, err := ioutil.ReadFile(store.makeAbsolutePath(key))
+	if err != nil {
+		return nil, store.makeError("get", key, err)
+	}
+
+	return data, nil
+}
+
+func (store *FileStore) Set(key string, data []byte) error {
+	store.log(fmt.Sprintf("Set key %s (len: %d bytes)", key, len(data)))
+
+	absPath := store.makeAbsolutePath(key)
+	if err := os.MkdirAll(filepath.Dir(absPath), fileStoreDirMode); err != nil {
+		return store.makeError("write", key, err)
+	}
+
+	if err := writeFileAtomic(absPath, data, fileStoreFileMode, true); err != nil {
+		return store.makeError("write", key, err)
+	}
+
+	return nil
+}
+
+func (store *FileStore) Create(key string, data []byte) error {
+	store.log(fmt.Sprintf("Create key %s (len: %d bytes)", key, len(data)))
+
+	absPath := store.makeAbsolutePath(key)
+	if err := os.MkdirAll(filepath.Dir(absPath), fileStoreDirMode); err != nil {
+		return store.makeError("write", key, err)
+	}
+
+	err := writeFileAtomic(absPath, data, fileStoreFileMode, false)
+	if os.IsExist(err) {
+		return ErrExists
+	}
+	if err != nil {
+		return store.makeError("write", key, err)
+	}
+
+	return nil
+}
+
+func (store *FileStore) Delete(key string) error {
+	store.log(fmt.Sprintf("Delete key %s", key))
+
+	err := os.Remove(store.makeAbsolutePath(key))
+	if err != nil && !os.IsNotExist(err) {
+		return store.makeError("delete", key, err)
+	}
+
+	return nil
+}
+
+func (store *FileStore) DeleteAll(key string) error {
+	store.log(fmt.Sprintf("DeleteAll key %s", key))
+
+	if cleanKey(key) == "" {
+		return store.makeError("deleteAll", key, errDeleteRoot)
+	}
+
+	if err := os.RemoveAll(store.makeAbsolutePath(key)); err != nil {
+		return store.makeError("deleteAll", key, err)
+	}
+
+	return nil
+}
+
+func (store *FileStore) String() string {
+	return fmt.Sprintf("file://%s", store.BaseDir)
+}
+
+func (store *FileStore) log(msg string) {
+	log.WithField("fileDir", store.BaseDir).Debugf("FILE_STORE: %s", msg)
+}
+
+const tempFilePattern = ".kaptain-tmp-"
+
+func isTempFile(name string) bool {
+	matched, _ := filepath.Match(tempFilePattern+"*", name)
+	return matched
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory and renames it
+// over the target, so readers never observe a partially written file. Without replace,
+// the temp file is linked to the target instead, which fails if the target exists.
+func writeFileAtomic(filename string, data []byte, mode os.FileMode, replace bool) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), tempFilePattern)
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	// clean up the temp file if anything below fails
+	success := false
+	defer func() {
+		if !success {
+			tmp.Close()
+			os.Remove(tmpName)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if !replace {
+		err = os.Link(tmpName, filename)
+		os.Remove(tmpName)
+		success = true
+		return err
+	}
+	if err := os.Rename(tmpName, filename); err != nil {
+		return err
+	}
+
+	success = true
+	return nil
+}
@@ -0,0 +1,26 @@
+package store_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/javefang/kaptain/pkg/store"
+	"github.com/javefang/kaptain/pkg/store/storetest"
+)
+
+func TestFileStore(t *testing.T) {
+	baseDir, err := ioutil.TempDir("", "filestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(baseDir)
+
+	count := 0
+	storetest.Run(t, func(t *testing.T) store.Store {
+		count++
+		return store.CreateStoreFromUrlOrDie("file://" + filepath.Join(baseDir, strconv.Itoa(count)))
+	})
+}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// memStores holds every named in-memory store of the process, so that all clients
+// created from the same 'mem://<name>' url share the same data.
+var memStores = map[string]*MemStore{}
+var memStoresLock sync.Mutex
+
+type MemStore struct {
+	Name string
+
+	lock sync.RWMutex
+	data map[string][]byte
+}
+
+func createMemStore(name string) Store {
+	memStoresLock.Lock()
+	defer memStoresLock.Unlock()
+
+	if s, exists := memStores[name]; exists {
+		return s
+	}
+
+	s := &MemStore{
+		Name: name,
+		data: map[string][]byte{},
+	}
+	memStores[name] = s
+	return s
+}
+
+func (store *MemStore) makeError(action string, key string, err error) error {
+	return fmt.Errorf("failed to %s key '%s' from %s: %v", action, key, store, err)
+}
+
+func (store *MemStore) List(key string) ([]string, error) {
+	store.log(fmt.Sprintf("List key %s", key))
+
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	prefix := makeDirPrefix(key)
+	seen := map[string]bool{}
+	names := make([]string, 0)
+	for k := range store.data {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		name := strings.SplitN(strings.TrimPrefix(k, prefix), "/", 2)[0]
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (store *MemStore) Exists(key string) (bool, error) {
+	store.log(fmt.Sprintf("Head key %s", key))
+
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	_, exists := store.data[cleanKey(key)]
+	return exists, nil
+}
+
+func (store *MemStore) Get(key string) ([]byte, error) {
+	store.log(fmt.Sprintf("Get key %s", key))
+
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	data, exists := store.data[cleanKey(key)]
+	if !exists {
+		return nil, store.makeError("get", key, fmt.Errorf("key not exists"))
+	}
+
+	// return a copy so callers cannot modify the stored value
+	return append([]byte{}, data...), nil
+}
+
+func (store *MemStore) Set(key string, data []byte) error {
+	store.log(fmt.Sprintf("Set key %s (len: %d bytes)", key, len(data)))
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.data[cleanKey(key)] = append([]byte{}, data...)
+	return nil
+}
+
+func (store *MemStore) Create(key string, data []byte) error {
+	store.log(fmt.Sprintf("Create key %s (len: %d bytes)", key, len(data)))
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	k := cleanKey(key)
+	if _, exists := store.data[k]; exists {
+		return ErrExists
+	}
+	store.data[k] = append([]byte{}, data...)
+	return nil
+}
+
+func (store *MemStore) Delete(key string) error {
+	store.log(fmt.Sprintf("Delete key %s", key))
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	delete(store.data, cleanKey(key))
+	return nil
+}
+
+func (store *MemStore) DeleteAll(key string) error {
+	store.log(fmt.Sprintf("DeleteAll key %s", key))
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	k := cleanKey(key)
+	if k == "" {
+		return store.makeError("deleteAll", key, errDeleteRoot)
+	}
+
+	prefix := makeDirPrefix(key)
+	for existing := range store.data {
+		if existing == k || strings.HasPrefix(existing, prefix) {
+			delete(store.data, existing)
+		}
+	}
+
+	return nil
+}
+
+func (store *MemStore) String() string {
+	return fmt.Sprintf("mem://%s", store.Name)
+}
+
+func (store *MemStore) log(msg string) {
+	log.WithField("memStore", store.Name).Debugf("MEM_STORE: %s", msg)
+}
@@ -0,0 +1,20 @@
+package store_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/javefang/kaptain/pkg/store"
+	"github.com/javefang/kaptain/pkg/store/storetest"
+)
+
+// memStoreCount makes the name of every mem store unique, named mem stores are shared by
+// the whole process
+var memStoreCount int
+
+func TestMemStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		memStoreCount++
+		return store.CreateStoreFromUrlOrDie(fmt.Sprintf("mem://%s-%d", t.Name(), memStoreCount))
+	})
+}
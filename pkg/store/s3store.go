@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path"
+	"sort"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -21,17 +22,23 @@ type S3Store struct {
 	S3Client *s3.S3
 }
 
-func createS3Store(bucket string, region string, assumeRoleARN string) Store {
-	s3Client := getS3Client(region, assumeRoleARN)
+func createS3Store(bucket string, region string, assumeRoleARN string) (Store, error) {
+	s3Client, err := getS3Client(region, assumeRoleARN)
+	if err != nil {
+		return nil, err
+	}
 
 	return &S3Store{
 		Bucket:   bucket,
 		S3Client: s3Client,
-	}
+	}, nil
 }
 
-func getS3Client(region string, assumeRoleARN string) *s3.S3 {
-	sess := session.Must(session.NewSession())
+func getS3Client(region string, assumeRoleARN string) (*s3.S3, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %v", err)
+	}
 	conf := &aws.Config{}
 
 	if region != "" {
@@ -43,7 +50,7 @@ func getS3Client(region string, assumeRoleARN string) *s3.S3 {
 		conf.Credentials = creds
 	}
 
-	return s3.New(sess, conf)
+	return s3.New(sess, conf), nil
 }
 
 func (store *S3Store) makeError(action string, key string, err error) error {
@@ -56,18 +63,25 @@ func (store *S3Store) List(key string) ([]string, error) {
 	req := &s3.ListObjectsV2Input{
 		Bucket:    aws.String(store.Bucket),
 		Delimiter: aws.String("/"),
-		Prefix:    aws.String(key),
+		Prefix:    aws.String(makeDirPrefix(key)),
 	}
 
-	resp, err := store.S3Client.ListObjectsV2(req)
+	// both sub-directories (common prefixes) and objects are children of the key
+	names := make([]string, 0)
+	err := store.S3Client.ListObjectsV2Pages(req, func(resp *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, elem := range resp.CommonPrefixes {
+			names = append(names, path.Base(aws.StringValue(elem.Prefix)))
+		}
+		for _, elem := range resp.Contents {
+			names = append(names, path.Base(aws.StringValue(elem.Key)))
+		}
+		return true
+	})
 	if err != nil {
 		return nil, store.makeError("list", key, err)
 	}
 
-	names := make([]string, len(resp.CommonPrefixes))
-	for i, elem := range resp.CommonPrefixes {
-		names[i] = path.Base(aws.StringValue(elem.Prefix))
-	}
+	sort.Strings(names)
 	return names, nil
 }
 
@@ -97,6 +111,21 @@ func (store *S3Store) Exists(key string) (bool, error) {
 	return true, nil
 }
 
+// GetVersion returns the ETag of the object, which only needs a HEAD request
+func (store *S3Store) GetVersion(key string) (string, error) {
+	store.log(fmt.Sprintf("Head key %s", key))
+
+	resp, err := store.S3Client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(store.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", store.makeError("head", key, err)
+	}
+
+	return aws.StringValue(resp.ETag), nil
+}
+
 func (store *S3Store) Get(key string) ([]byte, error) {
 	store.log(fmt.Sprintf("Get key %s", key))
 
@@ -106,14 +135,7 @@ func (store *S3Store) Get(key string) ([]byte, error) {
 	}
 	resp, err := store.S3Client.GetObject(req)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case s3.ErrCodeNoSuchKey:
-				return nil, store.makeError("get", key, err)
-			default:
-				return nil, store.makeError("get", key, err)
-			}
-		}
+		return nil, store.makeError("get", key, err)
 	}
 	defer resp.Body.Close()
 
@@ -162,44 +184,62 @@ func (store *S3Store) Delete(key string) error {
 func (store *S3Store) DeleteAll(key string) error {
 	store.log(fmt.Sprintf("DeleteAll key %s", key))
 
-	// list all keys to delete
-	listInput := &s3.ListObjectsV2Input{
-		Bucket: aws.String(store.Bucket),
-		Prefix: aws.String(key),
+	if cleanKey(key) == "" {
+		return store.makeError("delete", key, errDeleteRoot)
 	}
 
-	listOutput, err := store.S3Client.ListObjectsV2(listInput)
-	if err != nil {
-		return store.makeError("delete", key, err)
+	// the key itself and all keys under it as a directory, but not its siblings
+	// sharing the same prefix (e.g. 'dev' must not delete 'dev2/cluster.yaml')
+	if err := store.Delete(key); err != nil {
+		return err
 	}
 
-	delCount := len(listOutput.Contents)
-	if delCount == 0 {
-		store.log("DeleteAll: nothing to delete")
-		return nil
+	listInput := &s3.ListObjectsV2Input{
+		Bucket: aws.String(store.Bucket),
+		Prefix: aws.String(makeDirPrefix(key)),
 	}
 
-	// delete all listed keys
-	store.log(fmt.Sprintf("DeleteAll: deleting %d keys", delCount))
-	objIdentifiers := make([]*s3.ObjectIdentifier, 0)
-	for _, e := range listOutput.Contents {
-		store.log(fmt.Sprintf("DeleteAll: delete %s", aws.StringValue(e.Key)))
-		objIdentifiers = append(objIdentifiers, &s3.ObjectIdentifier{Key: e.Key})
-	}
+	// each page holds at most 1000 keys, which is also the limit of DeleteObjects
+	delCount := 0
+	var delErr error
+	err := store.S3Client.ListObjectsV2Pages(listInput, func(listOutput *s3.ListObjectsV2Output, lastPage bool) bool {
+		if len(listOutput.Contents) == 0 {
+			return true
+		}
 
-	// delete all objects
-	delInput := &s3.DeleteObjectsInput{
-		Bucket: aws.String(store.Bucket),
-		Delete: &s3.Delete{
-			Objects: objIdentifiers,
-		},
-	}
+		objIdentifiers := make([]*s3.ObjectIdentifier, 0)
+		for _, e := range listOutput.Contents {
+			store.log(fmt.Sprintf("DeleteAll: delete %s", aws.StringValue(e.Key)))
+			objIdentifiers = append(objIdentifiers, &s3.ObjectIdentifier{Key: e.Key})
+		}
 
-	delOutput, err := store.S3Client.DeleteObjects(delInput)
+		delInput := &s3.DeleteObjectsInput{
+			Bucket: aws.String(store.Bucket),
+			Delete: &s3.Delete{
+				Objects: objIdentifiers,
+			},
+		}
+
+		delOutput, err := store.S3Client.DeleteObjects(delInput)
+		if err != nil {
+			delErr = err
+			return false
+		}
+		if len(delOutput.Errors) > 0 {
+			e := delOutput.Errors[0]
+			delErr = fmt.Errorf("failed to delete %d keys, first error on '%s': %s", len(delOutput.Errors), aws.StringValue(e.Key), aws.StringValue(e.Message))
+			return false
+		}
+		delCount += len(delOutput.Deleted)
+		return true
+	})
 	if err != nil {
 		return store.makeError("delete", key, err)
 	}
-	store.log(fmt.Sprintf("DeleteAll: %d keys deleted", len(delOutput.Deleted)))
+	if delErr != nil {
+		return store.makeError("delete", key, delErr)
+	}
+	store.log(fmt.Sprintf("DeleteAll: %d keys deleted", delCount))
 
 	return nil
 }
@@ -0,0 +1,188 @@
+package store_test
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/javefang/kaptain/pkg/store"
+	"github.com/javefang/kaptain/pkg/store/storetest"
+)
+
+// fakeS3 is a local stand-in for the S3 API, it implements the requests made by S3Store
+// with path style addressing
+type fakeS3 struct {
+	lock    sync.Mutex
+	objects map[string][]byte // by bucket/key
+}
+
+type listBucketResult struct {
+	XMLName        xml.Name       `xml:"ListBucketResult"`
+	Prefix         string         `xml:"Prefix"`
+	KeyCount       int            `xml:"KeyCount"`
+	IsTruncated    bool           `xml:"IsTruncated"`
+	Contents       []listObject   `xml:"Contents"`
+	CommonPrefixes []commonPrefix `xml:"CommonPrefixes"`
+}
+
+type listObject struct {
+	Key  string `xml:"Key"`
+	ETag string `xml:"ETag"`
+	Size int    `xml:"Size"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type deleteRequest struct {
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+}
+
+type deleteResult struct {
+	XMLName xml.Name `xml:"DeleteResult"`
+	Deleted []struct {
+		Key string `xml:"Key"`
+	} `xml:"Deleted"`
+}
+
+func makeETag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	bucket, key := parts[0], ""
+	if len(parts) == 2 {
+		key = parts[1]
+	}
+	query := r.URL.Query()
+
+	switch {
+	case key == "" && r.Method == "GET":
+		f.list(w, bucket, query.Get("prefix"), query.Get("delimiter"))
+	case key == "" && r.Method == "POST" && query["delete"] != nil:
+		f.deleteObjects(w, r, bucket)
+	case r.Method == "PUT":
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		f.objects[bucket+"/"+key] = data
+		w.Header().Set("ETag", makeETag(data))
+	case r.Method == "GET" || r.Method == "HEAD":
+		data, exists := f.objects[bucket+"/"+key]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == "GET" {
+				fmt.Fprint(w, "<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>")
+			}
+			return
+		}
+		w.Header().Set("ETag", makeETag(data))
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		if r.Method == "GET" {
+			w.Write(data)
+		}
+	case r.Method == "DELETE":
+		delete(f.objects, bucket+"/"+key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, bucket string, prefix string, delimiter string) {
+	result := listBucketResult{Prefix: prefix}
+	seen := map[string]bool{}
+
+	keys := []string{}
+	for k := range f.objects {
+		if strings.HasPrefix(k, bucket+"/"+prefix) {
+			keys = append(keys, strings.TrimPrefix(k, bucket+"/"))
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		rest := strings.TrimPrefix(k, prefix)
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			p := prefix + rest[:i+len(delimiter)]
+			if !seen[p] {
+				seen[p] = true
+				result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: p})
+			}
+			continue
+		}
+		data := f.objects[bucket+"/"+k]
+		result.Contents = append(result.Contents, listObject{Key: k, ETag: makeETag(data), Size: len(data)})
+	}
+	result.KeyCount = len(result.Contents) + len(result.CommonPrefixes)
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+func (f *fakeS3) deleteObjects(w http.ResponseWriter, r *http.Request, bucket string) {
+	var req deleteRequest
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result := deleteResult{}
+	for _, o := range req.Objects {
+		delete(f.objects, bucket+"/"+o.Key)
+		result.Deleted = append(result.Deleted, struct {
+			Key string `xml:"Key"`
+		}{o.Key})
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+func TestS3Store(t *testing.T) {
+	server := httptest.NewServer(&fakeS3{objects: map[string][]byte{}})
+	defer server.Close()
+
+	sess, err := session.NewSession(&aws.Config{
+		Region:           aws.String("eu-west-1"),
+		Endpoint:         aws.String(server.URL),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials("id", "secret", ""),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s3Client := s3.New(sess)
+
+	// every store has its own bucket
+	count := 0
+	storetest.Run(t, func(t *testing.T) store.Store {
+		count++
+		return &store.S3Store{
+			Bucket:   fmt.Sprintf("kaptain-%d", count),
+			S3Client: s3Client,
+		}
+	})
+}
@@ -1,5 +1,22 @@
 package store
 
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+var errDeleteRoot = fmt.Errorf("refusing to delete the root of the store")
+
+// Store is a key value store organised like a filesystem, where '/' separates the
+// directories of a key.
+//
+// All implementations must behave the same (see package storetest):
+//   - List returns the sorted names of the direct children of a key, both values and
+//     directories, without trailing slashes. Listing a missing key returns no names.
+//   - Exists returns false without an error when a key is missing.
+//   - Get returns an error when a key is missing.
+//   - DeleteAll deletes a key and every key under it, but refuses to delete the root.
 type Store interface {
 	List(key string) ([]string, error)
 	Exists(key string) (bool, error)
@@ -8,3 +25,34 @@ type Store interface {
 	Delete(key string) error
 	DeleteAll(key string) error
 }
+
+// Versioner is implemented by stores that return the version of a value without reading
+// it, such as the ETag of an S3 object. The version changes whenever the value changes.
+type Versioner interface {
+	GetVersion(key string) (string, error)
+}
+
+// Creator is implemented by stores that can create a value only if its key does not exist
+// yet, atomically, so concurrent writers cannot overwrite each other. Create returns
+// ErrExists if the key exists.
+type Creator interface {
+	Create(key string, data []byte) error
+}
+
+// ErrExists is returned by Create when the key already exists
+var ErrExists = fmt.Errorf("key already exists")
+
+// cleanKey normalises a key so that 'a/b', '/a/b' and 'a//b' refer to the same entry
+func cleanKey(key string) string {
+	return strings.TrimPrefix(path.Clean("/"+key), "/")
+}
+
+// makeDirPrefix returns the prefix shared by all keys under the given key, treating the
+// key as a directory. The empty key is the root and has an empty prefix.
+func makeDirPrefix(key string) string {
+	k := cleanKey(key)
+	if k == "" {
+		return ""
+	}
+	return k + "/"
+}
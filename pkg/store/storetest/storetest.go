@@ -0,0 +1,228 @@
+// Package storetest provides a conformance suite for implementations of store.Store.
+//
+// A backend is tested by calling Run from a test with a factory that returns a new,
+// empty store for every sub-test. Stores must not be shared between runs, so that the
+// tests pass with -count greater than 1:
+//
+//	var count int
+//
+//	func TestMyStore(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) store.Store {
+//			count++
+//			return store.CreateStoreFromUrlOrDie(fmt.Sprintf("mem://%s-%d", t.Name(), count))
+//		})
+//	}
+package storetest
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/javefang/kaptain/pkg/store"
+)
+
+// Factory returns a new and empty store
+type Factory func(t *testing.T) store.Store
+
+// Run checks that the stores created by the factory fulfil the store.Store contract
+func Run(t *testing.T, newStore Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s store.Store)
+	}{
+		{"SetAndGet", testSetAndGet},
+		{"GetMissing", testGetMissing},
+		{"Exists", testExists},
+		{"List", testList},
+		{"ListMissing", testListMissing},
+		{"Delete", testDelete},
+		{"DeleteAll", testDeleteAll},
+		{"DeleteAllRoot", testDeleteAllRoot},
+		{"Version", testVersion},
+		{"Create", testCreate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+// the key layout used by api.ClusterRegistry
+var clusterKeys = []string{
+	"dev/cluster.yaml",
+	"dev/roles/etcd.yaml",
+	"dev/roles/master.yaml",
+	"dev2/cluster.yaml",
+}
+
+func setAll(t *testing.T, s store.Store, keys []string) {
+	for _, k := range keys {
+		if err := s.Set(k, []byte(k)); err != nil {
+			t.Fatalf("Set(%q) failed: %v", k, err)
+		}
+	}
+}
+
+func assertExists(t *testing.T, s store.Store, key string, expected bool) {
+	exists, err := s.Exists(key)
+	if err != nil {
+		t.Fatalf("Exists(%q) failed: %v", key, err)
+	}
+	if exists != expected {
+		t.Errorf("Exists(%q) = %v, expected %v", key, exists, expected)
+	}
+}
+
+func assertList(t *testing.T, s store.Store, key string, expected []string) {
+	names, err := s.List(key)
+	if err != nil {
+		t.Fatalf("List(%q) failed: %v", key, err)
+	}
+	if len(names) == 0 && len(expected) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("List(%q) = %v, expected %v", key, names, expected)
+	}
+}
+
+func testSetAndGet(t *testing.T, s store.Store) {
+	key := "dev/cluster.yaml"
+	for _, data := range [][]byte{[]byte("first"), []byte("second")} {
+		if err := s.Set(key, data); err != nil {
+			t.Fatalf("Set(%q) failed: %v", key, err)
+		}
+
+		actual, err := s.Get(key)
+		if err != nil {
+			t.Fatalf("Get(%q) failed: %v", key, err)
+		}
+		if !bytes.Equal(actual, data) {
+			t.Errorf("Get(%q) = %q, expected %q", key, actual, data)
+		}
+	}
+}
+
+func testGetMissing(t *testing.T, s store.Store) {
+	if _, err := s.Get("missing/cluster.yaml"); err == nil {
+		t.Errorf("Get on a missing key should return an error")
+	}
+}
+
+func testExists(t *testing.T, s store.Store) {
+	assertExists(t, s, "dev/cluster.yaml", false)
+
+	setAll(t, s, clusterKeys)
+
+	assertExists(t, s, "dev/cluster.yaml", true)
+	assertExists(t, s, "dev/roles/etcd.yaml", true)
+	assertExists(t, s, "dev/roles/worker.yaml", false)
+}
+
+func testList(t *testing.T, s store.Store) {
+	setAll(t, s, clusterKeys)
+
+	assertList(t, s, "", []string{"dev", "dev2"})
+	assertList(t, s, "dev", []string{"cluster.yaml", "roles"})
+	assertList(t, s, "dev/", []string{"cluster.yaml", "roles"})
+	assertList(t, s, "dev/roles", []string{"etcd.yaml", "master.yaml"})
+	assertList(t, s, "dev2", []string{"cluster.yaml"})
+}
+
+func testListMissing(t *testing.T, s store.Store) {
+	assertList(t, s, "", nil)
+	assertList(t, s, "missing", nil)
+}
+
+func testDelete(t *testing.T, s store.Store) {
+	setAll(t, s, clusterKeys)
+
+	if err := s.Delete("dev/roles/etcd.yaml"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	assertExists(t, s, "dev/roles/etcd.yaml", false)
+	assertExists(t, s, "dev/roles/master.yaml", true)
+
+	if err := s.Delete("dev/roles/etcd.yaml"); err != nil {
+		t.Errorf("Delete on a missing key should succeed: %v", err)
+	}
+}
+
+func testDeleteAll(t *testing.T, s store.Store) {
+	setAll(t, s, clusterKeys)
+
+	if err := s.DeleteAll("dev"); err != nil {
+		t.Fatalf("DeleteAll failed: %v", err)
+	}
+
+	assertExists(t, s, "dev/cluster.yaml", false)
+	assertExists(t, s, "dev/roles/etcd.yaml", false)
+	assertExists(t, s, "dev/roles/master.yaml", false)
+	assertList(t, s, "dev", nil)
+
+	// keys sharing the prefix but not the directory must be kept
+	assertExists(t, s, "dev2/cluster.yaml", true)
+	assertList(t, s, "", []string{"dev2"})
+
+	if err := s.DeleteAll("missing"); err != nil {
+		t.Errorf("DeleteAll on a missing key should succeed: %v", err)
+	}
+}
+
+func testDeleteAllRoot(t *testing.T, s store.Store) {
+	setAll(t, s, clusterKeys)
+
+	if err := s.DeleteAll(""); err == nil {
+		t.Errorf("DeleteAll on the root should return an error")
+	}
+	assertExists(t, s, "dev/cluster.yaml", true)
+}
+
+func testVersion(t *testing.T, s store.Store) {
+	versioner, ok := s.(store.Versioner)
+	if !ok {
+		t.Skip("store has no versions")
+	}
+
+	key := "dev/roles/etcd.yaml"
+	versions := map[string]bool{}
+	for _, data := range [][]byte{[]byte("first"), []byte("second")} {
+		if err := s.Set(key, data); err != nil {
+			t.Fatalf("Set(%q) failed: %v", key, err)
+		}
+		version, err := versioner.GetVersion(key)
+		if err != nil {
+			t.Fatalf("GetVersion(%q) failed: %v", key, err)
+		}
+		if versions[version] {
+			t.Errorf("GetVersion(%q) = %q, expected a new version after Set", key, version)
+		}
+		versions[version] = true
+	}
+}
+
+func testCreate(t *testing.T, s store.Store) {
+	creator, ok := s.(store.Creator)
+	if !ok {
+		t.Skip("store cannot create keys atomically")
+	}
+
+	key := "dev/revisions/1/revision.yaml"
+	if err := creator.Create(key, []byte("first")); err != nil {
+		t.Fatalf("Create(%q) failed: %v", key, err)
+	}
+	if err := creator.Create(key, []byte("second")); err != store.ErrExists {
+		t.Errorf("Create(%q) on an existing key returned %v, expected ErrExists", key, err)
+	}
+	actual, err := s.Get(key)
+	if err != nil {
+		t.Fatalf("Get(%q) failed: %v", key, err)
+	}
+	if string(actual) != "first" {
+		t.Errorf("Get(%q) = %q, expected the value of the first Create", key, actual)
+	}
+	assertList(t, s, "dev/revisions/1", []string{"revision.yaml"})
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"path"
+	"sort"
 	"strings"
 
 	vaultapi "github.com/hashicorp/vault/api"
@@ -22,7 +23,7 @@ const dataField = "data"
 var errKeyNotExists = fmt.Errorf("vault key not exists")
 var errInvalidValue = fmt.Errorf("vault value is malformed")
 
-func createVaultStoreOrDie(vaultPath string, roleId string, secretId string) Store {
+func createVaultStore(vaultPath string, roleId string, secretId string) (Store, error) {
 	logCtx := log.Fields{
 		"vaultPath": vaultPath,
 		"roleId":    roleId,
@@ -36,7 +37,7 @@ func createVaultStoreOrDie(vaultPath string, roleId string, secretId string) Sto
 	log.WithFields(logCtx).Debug("Creating vault client")
 	client, err := vaultapi.NewClient(&vaultConfig)
 	if err != nil {
-		panic(fmt.Errorf("failed to create vault client: %v", err))
+		return nil, fmt.Errorf("failed to create vault client: %v", err)
 	}
 
 	// log in with role id
@@ -47,7 +48,10 @@ func createVaultStoreOrDie(vaultPath string, roleId string, secretId string) Sto
 	secret, err := client.Logical().Write("auth/approle/login", creds)
 	if err != nil {
 		log.WithFields(logCtx).Errorf("Authentication with role ID failed: %v", err)
-		panic(fmt.Errorf("Authentication failed with Vault: %v", err))
+		return nil, fmt.Errorf("Authentication failed with Vault: %v", err)
+	}
+	if secret == nil || secret.Auth == nil {
+		return nil, fmt.Errorf("Authentication failed with Vault: no client token returned")
 	}
 	log.WithFields(logCtx).Debug("Authentication succeeded, client token set")
 	client.SetToken(secret.Auth.ClientToken)
@@ -55,7 +59,7 @@ func createVaultStoreOrDie(vaultPath string, roleId string, secretId string) Sto
 	return &VaultStore{
 		vaultClient: client,
 		vaultPath:   vaultPath,
-	}
+	}, nil
 }
 
 func (store *VaultStore) makeAbsolutePath(relPath string) string {
@@ -74,11 +78,17 @@ func (store *VaultStore) List(key string) ([]string, error) {
 		return nil, store.makeError("list", key, err)
 	}
 
-	// remove all trailing slashes
-	sanitisedKeys := make([]string, len(keys))
-	for i, k := range keys {
-		sanitisedKeys[i] = strings.TrimSuffix(k, "/")
+	// remove all trailing slashes, a key can be both a value and a directory
+	seen := map[string]bool{}
+	sanitisedKeys := make([]string, 0, len(keys))
+	for _, k := range keys {
+		name := strings.TrimSuffix(k, "/")
+		if !seen[name] {
+			seen[name] = true
+			sanitisedKeys = append(sanitisedKeys, name)
+		}
 	}
+	sort.Strings(sanitisedKeys)
 
 	return sanitisedKeys, nil
 }
@@ -152,18 +162,23 @@ func (store *VaultStore) Delete(key string) error {
 func (store *VaultStore) DeleteAll(key string) error {
 	store.log(fmt.Sprintf("DeleteAll key %s", key))
 
-	// list all keys to delete
+	if cleanKey(key) == "" {
+		return store.makeError("deleteAll", key, errDeleteRoot)
+	}
+
+	// list all keys to delete, including the key itself
 	keysToDel, err := store.listRecurse(key, 0)
 	if err != nil {
 		return store.makeError("deleteAll", key, err)
 	}
+	keysToDel = append(keysToDel, key)
 	log.Debugf("DeleteAll: deleting %d keys", len(keysToDel))
 
 	// delete all listed keys
 	for _, k := range keysToDel {
 		if err := store.Delete(k); err != nil {
 			// warning only if one key failed to delete
-			log.Warnf("failed to delete %d: %v", k, err)
+			log.Warnf("failed to delete %s: %v", k, err)
 		}
 	}
 
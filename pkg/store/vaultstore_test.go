@@ -0,0 +1,128 @@
+package store_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/javefang/kaptain/pkg/store"
+	"github.com/javefang/kaptain/pkg/store/storetest"
+)
+
+// fakeVault is a fake of the Vault HTTP API, it implements the approle login and the
+// generic secret backend used by VaultStore
+type fakeVault struct {
+	lock    sync.Mutex
+	secrets map[string]map[string]interface{} // by path under secret/
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if r.URL.Path == "/v1/auth/approle/login" {
+		writeJSON(w, map[string]interface{}{
+			"auth": map[string]interface{}{"client_token": "test-token"},
+		})
+		return
+	}
+	if r.Header.Get("X-Vault-Token") != "test-token" {
+		w.WriteHeader(http.StatusForbidden)
+		writeJSON(w, map[string]interface{}{"errors": []string{"permission denied"}})
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, "/v1/secret/") {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, map[string]interface{}{"errors": []string{}})
+		return
+	}
+	secretPath := strings.Trim(path.Clean(strings.TrimPrefix(r.URL.Path, "/v1/secret/")), "/")
+
+	switch {
+	case r.Method == "LIST" || (r.Method == "GET" && r.URL.Query().Get("list") == "true"):
+		f.list(w, secretPath)
+	case r.Method == "GET":
+		data, exists := f.secrets[secretPath]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			writeJSON(w, map[string]interface{}{"errors": []string{}})
+			return
+		}
+		writeJSON(w, map[string]interface{}{"data": data})
+	case r.Method == "PUT" || r.Method == "POST":
+		data := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]interface{}{"errors": []string{err.Error()}})
+			return
+		}
+		f.secrets[secretPath] = data
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "DELETE":
+		delete(f.secrets, secretPath)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// list returns the children of the path, with a trailing slash for directories
+func (f *fakeVault) list(w http.ResponseWriter, secretPath string) {
+	seen := map[string]bool{}
+	keys := []string{}
+	for p := range f.secrets {
+		if !strings.HasPrefix(p, secretPath+"/") {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimPrefix(p, secretPath+"/"), "/", 2)
+		name := parts[0]
+		if len(parts) == 2 {
+			name += "/"
+		}
+		if !seen[name] {
+			seen[name] = true
+			keys = append(keys, name)
+		}
+	}
+	if len(keys) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, map[string]interface{}{"errors": []string{}})
+		return
+	}
+	sort.Strings(keys)
+	writeJSON(w, map[string]interface{}{
+		"data": map[string]interface{}{"keys": keys},
+	})
+}
+
+func TestVaultStore(t *testing.T) {
+	server := httptest.NewServer(&fakeVault{secrets: map[string]map[string]interface{}{}})
+	defer server.Close()
+
+	vaultAddr := os.Getenv("VAULT_ADDR")
+	os.Setenv("VAULT_ADDR", server.URL)
+	defer os.Setenv("VAULT_ADDR", vaultAddr)
+
+	// every store has its own path
+	count := 0
+	storetest.Run(t, func(t *testing.T) store.Store {
+		count++
+		s, err := store.CreateStoreFromUrl(fmt.Sprintf("vault://kaptain/%d?role_id=role&secret_id=secret", count))
+		if err != nil {
+			t.Fatalf("failed to create vault store: %v", err)
+		}
+		return s
+	})
+}
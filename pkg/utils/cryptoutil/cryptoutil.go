@@ -0,0 +1,162 @@
+package cryptoutil
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+const keySize = 32
+const nonceSize = 24
+
+// SealedData is data encrypted with a data key, the data key is wrapped by a key provider
+// and stored next to the data
+type SealedData struct {
+	KeyProvider string `json:"keyProvider"` // url of the key provider that wrapped the data key
+	WrappedKey  string `json:"wrappedKey"`  // base64 encoded data key, wrapped by the key provider
+	Data        string `json:"data"`        // base64 encoded nonce and NaCl secretbox of the data
+}
+
+// Envelope seals data with a data key wrapped by a key provider and opens sealed data with
+// the key provider that sealed it. An envelope without a key provider only opens data of
+// key providers that need no local secret, such as AWS KMS.
+type Envelope struct {
+	keyProvider KeyProvider
+	openedKeys  map[string]*[keySize]byte // data keys already unwrapped, by wrapped key
+}
+
+// NewEnvelope creates an envelope of the key provider, which may be nil
+func NewEnvelope(keyProvider KeyProvider) *Envelope {
+	return &Envelope{
+		keyProvider: keyProvider,
+		openedKeys:  map[string]*[keySize]byte{},
+	}
+}
+
+// CanSeal returns true if the envelope has a key provider to seal data with
+func (e *Envelope) CanSeal() bool {
+	return e.keyProvider != nil
+}
+
+// Seal encrypts the data with a new data key, so the data key of one sealed data, such as
+// the files of a role, never opens another
+func (e *Envelope) Seal(data []byte) (*SealedData, error) {
+	if e.keyProvider == nil {
+		return nil, fmt.Errorf("failed to seal data: no key provider")
+	}
+
+	dataKey, err := makeKey()
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, err := e.keyProvider.WrapKey(dataKey[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key with %s: %v", e.keyProvider, err)
+	}
+
+	box, err := sealBox(data, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SealedData{
+		KeyProvider: e.keyProvider.String(),
+		WrappedKey:  base64.StdEncoding.EncodeToString(wrappedKey),
+		Data:        base64.StdEncoding.EncodeToString(box),
+	}, nil
+}
+
+// Open decrypts sealed data
+func (e *Envelope) Open(sealed *SealedData) ([]byte, error) {
+	dataKey, err := e.openDataKey(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	box, err := base64.StdEncoding.DecodeString(sealed.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sealed data: %v", err)
+	}
+	return openBox(box, dataKey)
+}
+
+func (e *Envelope) openDataKey(sealed *SealedData) (*[keySize]byte, error) {
+	if dataKey, exists := e.openedKeys[sealed.WrappedKey]; exists {
+		return dataKey, nil
+	}
+
+	keyProvider, err := e.getKeyProvider(sealed.KeyProvider)
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, err := base64.StdEncoding.DecodeString(sealed.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode wrapped data key: %v", err)
+	}
+	key, err := keyProvider.UnwrapKey(wrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key with %s: %v", keyProvider, err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("failed to unwrap data key with %s: invalid key size %d", keyProvider, len(key))
+	}
+
+	dataKey := new([keySize]byte)
+	copy(dataKey[:], key)
+	e.openedKeys[sealed.WrappedKey] = dataKey
+
+	// data opened without a key provider of the envelope stays encrypted when sealed again
+	if e.keyProvider == nil {
+		e.keyProvider = keyProvider
+	}
+	return dataKey, nil
+}
+
+// getKeyProvider returns the key provider to unwrap a data key of the key provider url. Key
+// files may be at a different path on each host, so the key provider of the envelope is used
+// unless the url needs no local secret.
+func (e *Envelope) getKeyProvider(keyProviderUrl string) (KeyProvider, error) {
+	if e.keyProvider != nil && e.keyProvider.String() == keyProviderUrl {
+		return e.keyProvider, nil
+	}
+	if isRemoteKeyProviderUrl(keyProviderUrl) {
+		return CreateKeyProviderFromUrl(keyProviderUrl)
+	}
+	if e.keyProvider != nil {
+		return e.keyProvider, nil
+	}
+	return nil, fmt.Errorf("data is encrypted by %s, an encryption key is required to decrypt it", keyProviderUrl)
+}
+
+func makeKey() (*[keySize]byte, error) {
+	key := new([keySize]byte)
+	if _, err := io.ReadFull(rand.Reader, key[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %v", err)
+	}
+	return key, nil
+}
+
+// sealBox encrypts the data with a NaCl secretbox, the random nonce is prepended to the box
+func sealBox(data []byte, key *[keySize]byte) ([]byte, error) {
+	var nonce [nonceSize]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	return secretbox.Seal(nonce[:], data, &nonce, key), nil
+}
+
+func openBox(box []byte, key *[keySize]byte) ([]byte, error) {
+	if len(box) < nonceSize {
+		return nil, fmt.Errorf("failed to decrypt data: data is too short")
+	}
+	var nonce [nonceSize]byte
+	copy(nonce[:], box[:nonceSize])
+	data, ok := secretbox.Open(nil, box[nonceSize:], &nonce, key)
+	if !ok {
+		return nil, fmt.Errorf("failed to decrypt data: wrong key or corrupted data")
+	}
+	return data, nil
+}
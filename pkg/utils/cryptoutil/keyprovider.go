@@ -0,0 +1,189 @@
+package cryptoutil
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// key provider url schemes
+const (
+	FileKeyProviderScheme       = "file"
+	PassphraseKeyProviderScheme = "passphrase"
+	AWSKMSKeyProviderScheme     = "awskms"
+)
+
+const defaultPassphraseEnv = "KAPTAIN_PASSPHRASE"
+const saltSize = 16
+
+// KeyProvider wraps and unwraps the data keys of sealed data
+type KeyProvider interface {
+	WrapKey(dataKey []byte) ([]byte, error)
+	UnwrapKey(wrappedKey []byte) ([]byte, error)
+	String() string
+}
+
+// CreateKeyProviderFromUrl creates the key provider of the url, the scheme selects the
+// backend, e.g. file:///etc/kaptain/cluster.key, passphrase://KAPTAIN_PASSPHRASE or
+// awskms://alias/kaptain?region=eu-west-1
+func CreateKeyProviderFromUrl(keyProviderUrl string) (KeyProvider, error) {
+	scheme, rest, err := splitKeyProviderUrl(keyProviderUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	switch scheme {
+	case FileKeyProviderScheme:
+		return createFileKeyProvider(rest)
+	case PassphraseKeyProviderScheme:
+		return createPassphraseKeyProvider(rest)
+	case AWSKMSKeyProviderScheme:
+		// KMS key ids may be ARNs, which are not valid url hosts
+		keyID, query := rest, ""
+		if i := strings.Index(rest, "?"); i >= 0 {
+			keyID, query = rest[:i], rest[i+1:]
+		}
+		queries, err := url.ParseQuery(query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key provider url '%s': %v", keyProviderUrl, err)
+		}
+		return createAWSKMSKeyProvider(keyID, queries.Get("region"), queries.Get("assume_role"))
+	default:
+		return nil, fmt.Errorf("failed to create key provider '%s': unknown scheme '%s'", keyProviderUrl, scheme)
+	}
+}
+
+// ParseAWSKMSKeyProviderUrl returns the key id and region of an awskms key provider url,
+// the last value is false if the url is of another key provider
+func ParseAWSKMSKeyProviderUrl(keyProviderUrl string) (string, string, bool) {
+	scheme, rest, err := splitKeyProviderUrl(keyProviderUrl)
+	if err != nil || scheme != AWSKMSKeyProviderScheme {
+		return "", "", false
+	}
+	keyID, query := rest, ""
+	if i := strings.Index(rest, "?"); i >= 0 {
+		keyID, query = rest[:i], rest[i+1:]
+	}
+	queries, _ := url.ParseQuery(query)
+	return keyID, queries.Get("region"), true
+}
+
+func splitKeyProviderUrl(keyProviderUrl string) (string, string, error) {
+	parts := strings.SplitN(keyProviderUrl, "://", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid key provider url '%s' (e.g. file:///etc/kaptain/cluster.key, passphrase://KAPTAIN_PASSPHRASE, awskms://alias/kaptain)", keyProviderUrl)
+	}
+	return strings.ToLower(parts[0]), parts[1], nil
+}
+
+// isRemoteKeyProviderUrl returns true if the key provider of the url holds its key itself,
+// so it can unwrap data keys on any host with access to it
+func isRemoteKeyProviderUrl(keyProviderUrl string) bool {
+	scheme, _, err := splitKeyProviderUrl(keyProviderUrl)
+	return err == nil && scheme == AWSKMSKeyProviderScheme
+}
+
+// FileKeyProvider wraps data keys with a key read from a file, which contains a base64
+// encoded 32 byte key
+type FileKeyProvider struct {
+	path string
+	key  *[keySize]byte
+}
+
+func createFileKeyProvider(path string) (KeyProvider, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %v", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("invalid key file %s: must contain a base64 encoded %d byte key", path, keySize)
+	}
+
+	provider := &FileKeyProvider{path: path, key: new([keySize]byte)}
+	copy(provider.key[:], key)
+	return provider, nil
+}
+
+func (p *FileKeyProvider) WrapKey(dataKey []byte) ([]byte, error) {
+	return sealBox(dataKey, p.key)
+}
+
+func (p *FileKeyProvider) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	return openBox(wrappedKey, p.key)
+}
+
+func (p *FileKeyProvider) String() string {
+	return fmt.Sprintf("%s://%s", FileKeyProviderScheme, p.path)
+}
+
+// PassphraseKeyProvider wraps data keys with a key derived from a passphrase with scrypt,
+// the passphrase is read from an environment variable
+type PassphraseKeyProvider struct {
+	env        string
+	passphrase []byte
+}
+
+func createPassphraseKeyProvider(env string) (KeyProvider, error) {
+	if env == "" {
+		env = defaultPassphraseEnv
+	}
+	passphrase := os.Getenv(env)
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase not set, set it in environment variable %s", env)
+	}
+	return &PassphraseKeyProvider{env: env, passphrase: []byte(passphrase)}, nil
+}
+
+// WrapKey seals the data key with a key derived with a new salt, the salt is prepended
+func (p *PassphraseKeyProvider) WrapKey(dataKey []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %v", err)
+	}
+	key, err := p.deriveKey(salt)
+	if err != nil {
+		return nil, err
+	}
+	box, err := sealBox(dataKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return append(salt, box...), nil
+}
+
+func (p *PassphraseKeyProvider) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	if len(wrappedKey) < saltSize {
+		return nil, fmt.Errorf("wrapped key is too short")
+	}
+	key, err := p.deriveKey(wrappedKey[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := openBox(wrappedKey[saltSize:], key)
+	if err != nil {
+		return nil, fmt.Errorf("wrong passphrase or corrupted key")
+	}
+	return dataKey, nil
+}
+
+func (p *PassphraseKeyProvider) deriveKey(salt []byte) (*[keySize]byte, error) {
+	derived, err := scrypt.Key(p.passphrase, salt, 1<<15, 8, 1, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key from passphrase: %v", err)
+	}
+	key := new([keySize]byte)
+	copy(key[:], derived)
+	return key, nil
+}
+
+func (p *PassphraseKeyProvider) String() string {
+	return fmt.Sprintf("%s://%s", PassphraseKeyProviderScheme, p.env)
+}
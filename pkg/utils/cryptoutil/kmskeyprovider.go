@@ -0,0 +1,83 @@
+package cryptoutil
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	log "github.com/sirupsen/logrus"
+)
+
+// AWSKMSKeyProvider wraps data keys with a key of AWS KMS, which never leaves KMS
+type AWSKMSKeyProvider struct {
+	keyID         string
+	region        string
+	assumeRoleARN string
+	kmsClient     *kms.KMS
+}
+
+func createAWSKMSKeyProvider(keyID string, region string, assumeRoleARN string) (KeyProvider, error) {
+	if keyID == "" {
+		return nil, fmt.Errorf("KMS key id cannot be empty")
+	}
+
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %v", err)
+	}
+	conf := &aws.Config{}
+	if region != "" {
+		conf.Region = aws.String(region)
+	}
+	if assumeRoleARN != "" {
+		conf.Credentials = stscreds.NewCredentials(sess, assumeRoleARN)
+	}
+
+	return &AWSKMSKeyProvider{
+		keyID:         keyID,
+		region:        region,
+		assumeRoleARN: assumeRoleARN,
+		kmsClient:     kms.New(sess, conf),
+	}, nil
+}
+
+func (p *AWSKMSKeyProvider) WrapKey(dataKey []byte) ([]byte, error) {
+	log.WithField("keyId", p.keyID).Debug("KMS: Encrypt data key")
+	resp, err := p.kmsClient.Encrypt(&kms.EncryptInput{
+		KeyId:     aws.String(p.keyID),
+		Plaintext: dataKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.CiphertextBlob, nil
+}
+
+// UnwrapKey decrypts the data key, the ciphertext identifies the KMS key that encrypted it
+func (p *AWSKMSKeyProvider) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	log.WithField("keyId", p.keyID).Debug("KMS: Decrypt data key")
+	resp, err := p.kmsClient.Decrypt(&kms.DecryptInput{
+		CiphertextBlob: wrappedKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
+
+func (p *AWSKMSKeyProvider) String() string {
+	queries := url.Values{}
+	if p.region != "" {
+		queries.Set("region", p.region)
+	}
+	if p.assumeRoleARN != "" {
+		queries.Set("assume_role", p.assumeRoleARN)
+	}
+	if len(queries) == 0 {
+		return fmt.Sprintf("%s://%s", AWSKMSKeyProviderScheme, p.keyID)
+	}
+	return fmt.Sprintf("%s://%s?%s", AWSKMSKeyProviderScheme, p.keyID, queries.Encode())
+}
@@ -0,0 +1,185 @@
+package diffutil
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const contextLines = 3
+
+type opKind byte
+
+const (
+	opEqual  opKind = ' '
+	opDelete opKind = '-'
+	opInsert opKind = '+'
+)
+
+// op is a single line of an edit script. aPos and bPos are the number of lines of a and b
+// before the line.
+type op struct {
+	kind opKind
+	line string
+	aPos int
+	bPos int
+}
+
+// Unified returns the unified diff between a and b with 3 lines of context, or an empty
+// string if there is no difference
+func Unified(aName string, bName string, a []byte, b []byte) string {
+	if bytes.Equal(a, b) {
+		return ""
+	}
+
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n", aName)
+	fmt.Fprintf(&buf, "+++ %s\n", bName)
+	for _, hunk := range makeHunks(ops) {
+		writeHunk(&buf, ops[hunk[0]:hunk[1]])
+	}
+
+	return buf.String()
+}
+
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// diffLines computes the edit script from a to b based on the longest common subsequence
+func diffLines(a []string, b []string) []op {
+	// skip common prefix and suffix, they are usually most of the file
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	midA := a[prefix : len(a)-suffix]
+	midB := b[prefix : len(b)-suffix]
+
+	// lcs[i][j] is the length of the LCS of midA[i:] and midB[j:]
+	lcs := make([][]int, len(midA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(midB)+1)
+	}
+	for i := len(midA) - 1; i >= 0; i-- {
+		for j := len(midB) - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := make([]op, 0, len(a)+len(b))
+	aPos, bPos := 0, 0
+	emit := func(kind opKind, line string) {
+		ops = append(ops, op{kind: kind, line: line, aPos: aPos, bPos: bPos})
+		if kind != opInsert {
+			aPos++
+		}
+		if kind != opDelete {
+			bPos++
+		}
+	}
+
+	for _, line := range a[:prefix] {
+		emit(opEqual, line)
+	}
+	i, j := 0, 0
+	for i < len(midA) && j < len(midB) {
+		switch {
+		case midA[i] == midB[j]:
+			emit(opEqual, midA[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			emit(opDelete, midA[i])
+			i++
+		default:
+			emit(opInsert, midB[j])
+			j++
+		}
+	}
+	for ; i < len(midA); i++ {
+		emit(opDelete, midA[i])
+	}
+	for ; j < len(midB); j++ {
+		emit(opInsert, midB[j])
+	}
+	for _, line := range a[len(a)-suffix:] {
+		emit(opEqual, line)
+	}
+
+	return ops
+}
+
+// makeHunks returns the [start, end) ranges of ops to print, merging changes that are
+// close enough to share their context
+func makeHunks(ops []op) [][2]int {
+	hunks := [][2]int{}
+	for i, o := range ops {
+		if o.kind == opEqual {
+			continue
+		}
+
+		start := max(i-contextLines, 0)
+		end := min(i+1+contextLines, len(ops))
+		if len(hunks) > 0 && start <= hunks[len(hunks)-1][1] {
+			hunks[len(hunks)-1][1] = end
+		} else {
+			hunks = append(hunks, [2]int{start, end})
+		}
+	}
+	return hunks
+}
+
+func writeHunk(buf *bytes.Buffer, ops []op) {
+	aLen, bLen := 0, 0
+	for _, o := range ops {
+		if o.kind != opInsert {
+			aLen++
+		}
+		if o.kind != opDelete {
+			bLen++
+		}
+	}
+
+	fmt.Fprintf(buf, "@@ -%s +%s @@\n", formatRange(ops[0].aPos, aLen), formatRange(ops[0].bPos, bLen))
+	for _, o := range ops {
+		fmt.Fprintf(buf, "%c%s\n", o.kind, o.line)
+	}
+}
+
+// formatRange formats a hunk range, an empty range refers to the line before it
+func formatRange(pos int, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", pos)
+	}
+	return fmt.Sprintf("%d,%d", pos+1, length)
+}
+
+func max(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
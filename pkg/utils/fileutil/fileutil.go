@@ -2,18 +2,23 @@ package fileutil
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"os/user"
 	"path"
+	"strconv"
+	"syscall"
 	"text/template"
 
 	log "github.com/sirupsen/logrus"
 	bindata "github.com/javefang/kaptain/data"
-	"github.com/javefang/kaptain/pkg/api"
 )
 
-// TODO: this should probabaly be configurable
+// mode of files written without one
 const defaultFileMode = 0644
 
 func GetAsset(assetPath string) ([]byte, error) {
@@ -41,29 +46,149 @@ func RenderTemplate(templatePath string, args interface{}) ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
-func WriteAll(prefix string, files []*api.ClusterFile) error {
-	for _, file := range files {
-		fullPath := path.Join(prefix, file.Path)
+func EnsureDirExists(dir string) error {
+	return os.MkdirAll(dir, 0700)
+}
 
-		data, err := file.GetData()
-		if err != nil {
-			return fmt.Errorf("Failed to decode data for %s: %v", fullPath, err)
+// MakeParentDirs creates the missing parent dirs of a file with the mode, regardless of umask
+func MakeParentDirs(file string, mode os.FileMode) error {
+	missing := []string{}
+	for dir := path.Dir(file); ; dir = path.Dir(dir) {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return err
 		}
-
-		log.Infof("writing file: %s (len: %d)", fullPath, len(data))
-		if err = Write(data, fullPath); err != nil {
-			return fmt.Errorf("Error writing file %s: %v", fullPath, err)
+		missing = append(missing, dir)
+		if dir == path.Dir(dir) {
+			break
 		}
 	}
 
+	// create from the top, so each dir gets the mode
+	for i := len(missing) - 1; i >= 0; i-- {
+		log.Debugf("Creating dir %s (mode: %04o)", missing[i], mode)
+		if err := os.Mkdir(missing[i], mode); err != nil && !os.IsExist(err) {
+			return err
+		}
+		if err := os.Chmod(missing[i], mode); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func EnsureDirExists(dir string) error {
-	return os.MkdirAll(dir, 0700)
-}
-
 func Write(data []byte, outfile string) error {
 	log.Debugf("Writing file %s (data length: %d)", outfile, len(data))
 	return ioutil.WriteFile(outfile, data, defaultFileMode)
 }
+
+// WriteTemp writes the data to a new temp file next to outfile, which is renamed to
+// outfile once all files are written. The temp file gets the mode before the data is
+// written, so a key never ends up in a world-readable file.
+func WriteTemp(data []byte, outfile string, mode os.FileMode) (string, error) {
+	log.Debugf("Writing temp file of %s (data length: %d)", outfile, len(data))
+	f, err := ioutil.TempFile(path.Dir(outfile), fmt.Sprintf(".%s.", path.Base(outfile)))
+	if err != nil {
+		return "", err
+	}
+	tmpFile := f.Name()
+
+	err = f.Chmod(mode)
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFile)
+		return "", err
+	}
+	return tmpFile, nil
+}
+
+// ReadChecksum returns the hex encoded SHA-256 and the mode of a file, or an empty checksum
+// if the file does not exist
+func ReadChecksum(file string) (string, os.FileMode, error) {
+	info, err := os.Stat(file)
+	if os.IsNotExist(err) {
+		return "", 0, nil
+	} else if err != nil {
+		return "", 0, err
+	}
+	if !info.Mode().IsRegular() {
+		return "", 0, fmt.Errorf("%s is not a regular file", file)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), info.Mode().Perm(), nil
+}
+
+// ReadOwner returns the uid and gid of a file
+func ReadOwner(file string) (int, int, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return 0, 0, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, fmt.Errorf("owner of %s is not known on this platform", file)
+	}
+	return int(stat.Uid), int(stat.Gid), nil
+}
+
+// LookupOwner returns the uid and gid of an owner and group, each is a name or numeric id,
+// or -1 if empty
+func LookupOwner(owner string, group string) (int, int, error) {
+	uid, gid := -1, -1
+	if owner != "" {
+		id, err := lookupID(owner, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+		uid = id
+	}
+	if group != "" {
+		id, err := lookupID(group, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+		gid = id
+	}
+	return uid, gid, nil
+}
+
+func lookupID(nameOrID string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(nameOrID); err == nil {
+		return id, nil
+	}
+	id, err := lookup(nameOrID)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(id)
+}
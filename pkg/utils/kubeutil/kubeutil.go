@@ -15,6 +15,9 @@ import (
 	"github.com/javefang/kaptain/pkg/utils/fileutil"
 )
 
+// mode of the kubeconfig files written by kaptain
+const kubeConfigMode = 0600
+
 // ValidateAndWriteToBuffer converts kubeconfig object to bytes
 func ValidateAndWriteToBuffer(config *clientcmdapi.Config) ([]byte, error) {
 	if err := clientcmd.Validate(*config); err != nil {
@@ -42,6 +45,17 @@ func KubeApply(context string, name string, data []byte) error {
 	return cmd.Run()
 }
 
+// KubeDelete deletes a resource from the cluster, it is not an error if it does not exist
+func KubeDelete(context string, kind string, namespace string, name string) error {
+	log.Debugf("Running kubectl delete for %s '%s' under context '%s'", kind, name, context)
+
+	cmd := exec.Command("kubectl", "--context", context, "--namespace", namespace, "delete", kind, name, "--ignore-not-found")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
 // KubeWaitForApiserver blocks and retries until apiserver is available
 func KubeWaitForApiserver(context string) {
 	log.Debugf("Running kubectl to check if apiserver is ready")
@@ -63,18 +77,36 @@ func KubeWaitForApiserver(context string) {
 
 // GetKubeConfig returns the kubeconfig for a specific user
 func GetKubeConfig(c *api.Cluster, user string) (*clientcmdapi.Config, error) {
-	// parse config from file if file already exist, otherwise, create empty config
+	tokenSecret, exists := c.Secrets.TokenSecrets[user]
+	if !exists {
+		return nil, fmt.Errorf("user %s not found", user)
+	}
+
+	authInfo := clientcmdapi.NewAuthInfo()
+	authInfo.Token = tokenSecret.Token
+
+	return makeKubeConfig(c, user, authInfo)
+}
+
+// GetClientCertKubeConfig returns the kubeconfig for a user authenticating with a client cert
+func GetClientCertKubeConfig(c *api.Cluster, user string, certData []byte, keyData []byte) (*clientcmdapi.Config, error) {
+	authInfo := clientcmdapi.NewAuthInfo()
+	authInfo.ClientCertificateData = certData
+	authInfo.ClientKeyData = keyData
+
+	return makeKubeConfig(c, user, authInfo)
+}
+
+func makeKubeConfig(c *api.Cluster, user string, authInfo *clientcmdapi.AuthInfo) (*clientcmdapi.Config, error) {
 	config := clientcmdapi.NewConfig()
 
 	// prepare data
 	clusterName := c.Name
 	authInfoName := fmt.Sprintf("%s-%s", clusterName, user)
 	apiserverURL := fmt.Sprintf("https://%s", c.Spec.MasterPublicName)
-	apiserverCAData := c.Secrets.PKIs["kube-ca"].GetCertData()
-
-	tokenSecret, exists := c.Secrets.TokenSecrets[user]
-	if !exists {
-		return nil, fmt.Errorf("user %s not found", user)
+	apiserverCAData, err := c.Secrets.GetServerCABundle("kube-ca")
+	if err != nil {
+		return nil, err
 	}
 
 	// set Cluster
@@ -84,8 +116,6 @@ func GetKubeConfig(c *api.Cluster, user string) (*clientcmdapi.Config, error) {
 	config.Clusters[clusterName] = cluster
 
 	// set AuthInfo
-	authInfo := clientcmdapi.NewAuthInfo()
-	authInfo.Token = tokenSecret.Token
 	config.AuthInfos[authInfoName] = authInfo
 
 	// set Context
@@ -108,6 +138,52 @@ func ExportKubeConfig(c *api.Cluster, filename string, user string, overwrite bo
 		return fmt.Errorf("failed to export kube config: %v", err)
 	}
 
+	return mergeKubeConfig(c, newConfig, filename, user, overwrite)
+}
+
+// ExportClientCertKubeConfig exports the kubeconfig of a user authenticating with a client
+// cert to a file on disk
+func ExportClientCertKubeConfig(c *api.Cluster, filename string, user string, certData []byte, keyData []byte, overwrite bool) error {
+	// prepare new config
+	newConfig, err := GetClientCertKubeConfig(c, user, certData, keyData)
+	if err != nil {
+		return fmt.Errorf("failed to export kube config: %v", err)
+	}
+
+	return mergeKubeConfig(c, newConfig, filename, user, overwrite)
+}
+
+// CheckMergeKubeConfig returns an error if the kubeconfig of the user cannot be merged into
+// the kubeconfig file, so credentials are only issued once they can be exported
+func CheckMergeKubeConfig(c *api.Cluster, filename string, user string, overwrite bool) error {
+	config, err := clientcmd.LoadFromFile(filename)
+	if err != nil {
+		// a new kubeconfig file is created
+		return nil
+	}
+	return checkMergeKubeConfig(config, c.Name, fmt.Sprintf("%s-%s", c.Name, user), filename, overwrite)
+}
+
+// checkMergeKubeConfig returns an error if the cluster, user or context already exists in
+// the kubeconfig and cannot be overwritten
+func checkMergeKubeConfig(config *clientcmdapi.Config, clusterName string, authInfoName string, filename string, overwrite bool) error {
+	if overwrite {
+		return nil
+	}
+	if config.Clusters[clusterName] != nil {
+		return fmt.Errorf("failed to set cluster '%s' in '%s': already exists", clusterName, filename)
+	}
+	if config.AuthInfos[authInfoName] != nil {
+		return fmt.Errorf("failed to set authInfo '%s' in '%s': already exists", authInfoName, filename)
+	}
+	if config.Contexts[clusterName] != nil {
+		return fmt.Errorf("failed to set context '%s' in '%s': already exists", clusterName, filename)
+	}
+	return nil
+}
+
+// mergeKubeConfig merges the cluster, user and context of newConfig into the kubeconfig file
+func mergeKubeConfig(c *api.Cluster, newConfig *clientcmdapi.Config, filename string, user string, overwrite bool) error {
 	// parse config from file if file already exist, otherwise, create empty config
 	config, err := clientcmd.LoadFromFile(filename)
 	if err != nil {
@@ -119,22 +195,11 @@ func ExportKubeConfig(c *api.Cluster, filename string, user string, overwrite bo
 	clusterName := c.Name
 	authInfoName := fmt.Sprintf("%s-%s", clusterName, user)
 
-	// set Cluster
-	if config.Clusters[clusterName] != nil && !overwrite {
-		return fmt.Errorf("failed to set cluster '%s' in '%s': already exists", clusterName, filename)
+	if err := checkMergeKubeConfig(config, clusterName, authInfoName, filename, overwrite); err != nil {
+		return err
 	}
 	config.Clusters[clusterName] = newConfig.Clusters[clusterName]
-
-	// set AuthInfo
-	if config.AuthInfos[authInfoName] != nil && !overwrite {
-		return fmt.Errorf("failed to set authInfo '%s' in '%s': already exists", clusterName, filename)
-	}
 	config.AuthInfos[authInfoName] = newConfig.AuthInfos[authInfoName]
-
-	// set Context
-	if config.Contexts[clusterName] != nil && !overwrite {
-		return fmt.Errorf("failed to set context '%s' in '%s': already exists", clusterName, filename)
-	}
 	config.Contexts[clusterName] = newConfig.Contexts[clusterName]
 
 	// set current context
@@ -150,7 +215,14 @@ func ExportKubeConfig(c *api.Cluster, filename string, user string, overwrite bo
 		return err
 	}
 
-	if err := fileutil.Write(data, filename); err != nil {
+	// the kubeconfig holds the token or private key of its users, it is written to a temp
+	// file of mode 0600 that replaces the file, so it is never readable by other users
+	tmpFile, err := fileutil.WriteTemp(data, filename, kubeConfigMode)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFile, filename); err != nil {
+		os.Remove(tmpFile)
 		return err
 	}
 
@@ -1,23 +1,36 @@
 package pkiutil
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"fmt"
-	"log"
 	"math/big"
 	"net"
-	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type SigningProfile uint8
 
 const keyPEMType = "RSA PRIVATE KEY"
+const ecKeyPEMType = "EC PRIVATE KEY"
+const pkcs8KeyPEMType = "PRIVATE KEY"
 const certPEMType = "CERTIFICATE"
+const publicKeyPEMType = "PUBLIC KEY"
+
+type KeyAlgorithm string
+
+const (
+	RSA   KeyAlgorithm = "rsa"
+	ECDSA KeyAlgorithm = "ecdsa"
+)
 
 const (
 	None SigningProfile = iota
@@ -27,41 +40,64 @@ const (
 )
 
 type CSRParams struct {
-	Subject  pkix.Name
-	AltNames []string
-	ValidFor time.Duration
-	Profile  SigningProfile
+	Subject      pkix.Name
+	AltNames     []string
+	ValidFor     time.Duration
+	Profile      SigningProfile
+	KeyAlgorithm KeyAlgorithm // default RSA
+	KeySize      int          // RSA modulus or ECDSA curve size in bits, default 2048 for RSA and 256 for ECDSA
 }
 
 type CertCombo struct {
 	Cert *x509.Certificate
-	Key  *rsa.PrivateKey
+	Key  crypto.Signer
 }
 
 const defaultRSAKeyLength = 2048
+const defaultECDSAKeyLength = 256
+
+func InitCA(params CSRParams) (*CertCombo, error) {
+	priv, err := makePrivateKey(params.KeyAlgorithm, params.KeySize)
+	if err != nil {
+		return nil, err
+	}
 
-func InitCA(params CSRParams) *CertCombo {
-	priv := makePrivateKey()
-	cert := makeCACertOrDie(params, priv)
+	cert, err := makeCACert(params, priv)
+	if err != nil {
+		return nil, err
+	}
 
 	return &CertCombo{
 		Cert: cert,
 		Key:  priv,
-	}
+	}, nil
 }
 
-func MakeCert(params CSRParams, caCombo *CertCombo) *CertCombo {
-	priv := makePrivateKey()
+// MakeCert creates a new key and has the signer sign the CSR of the params with it
+func MakeCert(params CSRParams, signer Signer) (*CertCombo, error) {
+	priv, err := makePrivateKey(params.KeyAlgorithm, params.KeySize)
+	if err != nil {
+		return nil, err
+	}
 
-	csr := makeCSROrDie(params, priv)
-	cert := signCertOrDie(params, csr, caCombo)
+	csr, err := makeCSR(params, priv)
+	if err != nil {
+		return nil, err
+	}
 
-	// TODO: validate key and cert?
+	cert, err := signer.Sign(params, csr)
+	if err != nil {
+		return nil, err
+	}
 
-	return &CertCombo{
+	certCombo := &CertCombo{
 		Cert: cert,
 		Key:  priv,
 	}
+	if !certCombo.KeyMatchesCert() {
+		return nil, fmt.Errorf("failed to sign certificate: signed certificate does not match the key of the CSR")
+	}
+	return certCombo, nil
 }
 
 func (certCombo *CertCombo) ExtractCertData() []byte {
@@ -69,9 +105,53 @@ func (certCombo *CertCombo) ExtractCertData() []byte {
 	return convertToPEM(certPEMType, derBytes)
 }
 
-func (certCombo *CertCombo) ExtractKeyData() []byte {
-	derBytes := x509.MarshalPKCS1PrivateKey(certCombo.Key)
-	return convertToPEM(keyPEMType, derBytes)
+func (certCombo *CertCombo) ExtractKeyData() ([]byte, error) {
+	return encodePrivateKeyPEM(certCombo.Key)
+}
+
+// MakeKeyPair returns the PEM data of a new private key and its public key, for keys that
+// are used without a cert such as the service account signing key
+func MakeKeyPair(algorithm KeyAlgorithm, size int) ([]byte, []byte, error) {
+	priv, err := makePrivateKey(algorithm, size)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privPEM, err := encodePrivateKeyPEM(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	pubPEM, err := EncodePublicKeyPEM(priv.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return privPEM, pubPEM, nil
+}
+
+// EncodePublicKeyPEM returns the PKIX PEM data of a public key
+func EncodePublicKeyPEM(pub crypto.PublicKey) ([]byte, error) {
+	derBytes, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %v", err)
+	}
+	return convertToPEM(publicKeyPEMType, derBytes), nil
+}
+
+func encodePrivateKeyPEM(priv crypto.Signer) ([]byte, error) {
+	switch key := priv.(type) {
+	case *rsa.PrivateKey:
+		// PKCS#1 keeps the key data of existing RSA clusters unchanged
+		return convertToPEM(keyPEMType, x509.MarshalPKCS1PrivateKey(key)), nil
+	case *ecdsa.PrivateKey:
+		derBytes, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal EC private key: %v", err)
+		}
+		return convertToPEM(ecKeyPEMType, derBytes), nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", priv)
+	}
 }
 
 func (certCombo *CertCombo) SetCertPEMData(certPEM []byte) error {
@@ -87,42 +167,128 @@ func (certCombo *CertCombo) SetCertPEMData(certPEM []byte) error {
 	return nil
 }
 
+// SetKeyPEMData parses a PKCS#1 RSA, SEC 1 EC or PKCS#8 private key
 func (certCombo *CertCombo) SetKeyPEMData(keyPEM []byte) error {
-	derBytes, err := convertFromPEM(keyPEMType, keyPEM)
-	if err != nil {
-		return fmt.Errorf("Invalid PEM data: %v", err)
+	p, rest := pem.Decode(keyPEM)
+	if p == nil {
+		return fmt.Errorf("Invalid PEM data: no PEM block found")
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("Invalid PEM data: unparsed bytes")
+	}
+
+	var key interface{}
+	var err error
+	switch p.Type {
+	case keyPEMType:
+		key, err = x509.ParsePKCS1PrivateKey(p.Bytes)
+	case ecKeyPEMType:
+		key, err = x509.ParseECPrivateKey(p.Bytes)
+	case pkcs8KeyPEMType:
+		key, err = x509.ParsePKCS8PrivateKey(p.Bytes)
+	default:
+		return fmt.Errorf("Invalid PEM data: invalid PEM block type %s", p.Type)
 	}
-	key, err := x509.ParsePKCS1PrivateKey(derBytes)
 	if err != nil {
 		return fmt.Errorf("Failed to parse key: %v", err)
 	}
-	certCombo.Key = key
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return fmt.Errorf("Failed to parse key: unsupported private key type %T", key)
+	}
+	certCombo.Key = signer
 	return nil
 }
 
-func makePrivateKey() *rsa.PrivateKey {
-	priv, err := rsa.GenerateKey(rand.Reader, defaultRSAKeyLength)
+// ParseKeyType parses a key type such as "rsa-4096" or "ecdsa-p256" into its algorithm and size
+func ParseKeyType(keyType string) (KeyAlgorithm, int, error) {
+	parts := strings.SplitN(strings.ToLower(keyType), "-", 2)
+	if len(parts) != 2 {
+		return "", 0, fmt.Errorf("invalid key type '%s' (e.g. rsa-2048, rsa-4096, ecdsa-p256, ecdsa-p384)", keyType)
+	}
+
+	algorithm := KeyAlgorithm(parts[0])
+	size, err := strconv.Atoi(strings.TrimPrefix(parts[1], "p"))
 	if err != nil {
-		log.Fatalf("failed to generate ECDSA private key: %v", err)
-		os.Exit(2)
+		return "", 0, fmt.Errorf("invalid key type '%s' (e.g. rsa-2048, rsa-4096, ecdsa-p256, ecdsa-p384)", keyType)
+	}
+
+	if err := ValidateKeyParams(algorithm, size); err != nil {
+		return "", 0, err
 	}
-	return priv
+	return algorithm, size, nil
+}
+
+// ValidateKeyParams returns an error if keys of the algorithm and size cannot be generated
+func ValidateKeyParams(algorithm KeyAlgorithm, size int) error {
+	switch algorithm {
+	case RSA:
+		if size < 2048 {
+			return fmt.Errorf("RSA key size must be at least 2048, got %d", size)
+		}
+	case ECDSA:
+		if getCurve(size) == nil {
+			return fmt.Errorf("ECDSA key size must be one of 256, 384, got %d", size)
+		}
+	default:
+		return fmt.Errorf("unsupported key algorithm '%s' (must be one of %s, %s)", algorithm, RSA, ECDSA)
+	}
+	return nil
 }
 
-func makeSerialNumber() *big.Int {
+func getCurve(size int) elliptic.Curve {
+	switch size {
+	case 256:
+		return elliptic.P256()
+	case 384:
+		return elliptic.P384()
+	default:
+		return nil
+	}
+}
+
+func makePrivateKey(algorithm KeyAlgorithm, size int) (crypto.Signer, error) {
+	var priv crypto.Signer
+	var err error
+
+	switch algorithm {
+	case ECDSA:
+		if size == 0 {
+			size = defaultECDSAKeyLength
+		}
+		curve := getCurve(size)
+		if curve == nil {
+			return nil, fmt.Errorf("unsupported ECDSA key size %d", size)
+		}
+		priv, err = ecdsa.GenerateKey(curve, rand.Reader)
+	case RSA, "":
+		if size == 0 {
+			size = defaultRSAKeyLength
+		}
+		priv, err = rsa.GenerateKey(rand.Reader, size)
+	default:
+		return nil, fmt.Errorf("unsupported key algorithm '%s'", algorithm)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s private key: %v", algorithm, err)
+	}
+	return priv, nil
+}
+
+func makeSerialNumber() (*big.Int, error) {
 	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
 	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
 	if err != nil {
-		log.Fatalf("failed to generate serial number: %s", err)
-		os.Exit(2)
+		return nil, fmt.Errorf("failed to generate serial number: %v", err)
 	}
-	return serialNumber
+	return serialNumber, nil
 }
 
-func makeCSROrDie(params CSRParams, priv *rsa.PrivateKey) *x509.CertificateRequest {
+func makeCSR(params CSRParams, priv crypto.Signer) (*x509.CertificateRequest, error) {
 	template := x509.CertificateRequest{
-		Subject:            params.Subject,
-		SignatureAlgorithm: x509.SHA256WithRSA,
+		Subject: params.Subject,
 	}
 
 	// set DNS names and IP addresses
@@ -136,30 +302,32 @@ func makeCSROrDie(params CSRParams, priv *rsa.PrivateKey) *x509.CertificateReque
 
 	csrBytes, err := x509.CreateCertificateRequest(rand.Reader, &template, priv)
 	if err != nil {
-		panic(fmt.Errorf("failed to make CSR: %v", err))
+		return nil, fmt.Errorf("failed to make CSR: %v", err)
 	}
 
 	csr, err := x509.ParseCertificateRequest(csrBytes)
 	if err != nil {
-		panic(fmt.Errorf("failed to parse CSR (this should not happen): %v", err))
+		return nil, fmt.Errorf("failed to parse CSR (this should not happen): %v", err)
 	}
 
-	return csr
+	return csr, nil
 }
 
-func signCertOrDie(params CSRParams, csr *x509.CertificateRequest, caCombo *CertCombo) *x509.Certificate {
+func signCert(params CSRParams, csr *x509.CertificateRequest, caCombo *CertCombo) (*x509.Certificate, error) {
 	// check csr
 	if err := csr.CheckSignature(); err != nil {
-		panic(fmt.Errorf("invalid CSR signature: %v", err))
+		return nil, fmt.Errorf("invalid CSR signature: %v", err)
 	}
 
 	notBefore := time.Now()
 	notAfter := notBefore.Add(params.ValidFor)
-	serialNumber := makeSerialNumber()
+	serialNumber, err := makeSerialNumber()
+	if err != nil {
+		return nil, err
+	}
 
+	// the signature algorithm is chosen by the type of the CA key, which may differ from the CSR key
 	template := x509.Certificate{
-		Signature:          csr.Signature,
-		SignatureAlgorithm: csr.SignatureAlgorithm,
 		PublicKeyAlgorithm: csr.PublicKeyAlgorithm,
 		PublicKey:          csr.PublicKey,
 		Subject:            csr.Subject,
@@ -182,21 +350,24 @@ func signCertOrDie(params CSRParams, csr *x509.CertificateRequest, caCombo *Cert
 
 	crtBytes, err := x509.CreateCertificate(rand.Reader, &template, caCombo.Cert, csr.PublicKey, caCombo.Key)
 	if err != nil {
-		panic(fmt.Errorf("failed to sign certificate: %v", err))
+		return nil, fmt.Errorf("failed to sign certificate: %v", err)
 	}
 
 	cert, err := x509.ParseCertificate(crtBytes)
 	if err != nil {
-		panic(fmt.Errorf("failed to parse certificate (this should not happen): %s", err))
+		return nil, fmt.Errorf("failed to parse certificate (this should not happen): %v", err)
 	}
 
-	return cert
+	return cert, nil
 }
 
-func makeCACertOrDie(params CSRParams, priv *rsa.PrivateKey) *x509.Certificate {
+func makeCACert(params CSRParams, priv crypto.Signer) (*x509.Certificate, error) {
 	notBefore := time.Now()
 	notAfter := notBefore.Add(params.ValidFor)
-	serialNumber := makeSerialNumber()
+	serialNumber, err := makeSerialNumber()
+	if err != nil {
+		return nil, err
+	}
 
 	template := x509.Certificate{
 		SerialNumber:          serialNumber,
@@ -208,17 +379,17 @@ func makeCACertOrDie(params CSRParams, priv *rsa.PrivateKey) *x509.Certificate {
 		IsCA: true,
 	}
 
-	crtBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	crtBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, priv.Public(), priv)
 	if err != nil {
-		panic(fmt.Errorf("failed to sign CA cert: %v", err))
+		return nil, fmt.Errorf("failed to sign CA cert: %v", err)
 	}
 
 	cert, err := x509.ParseCertificate(crtBytes)
 	if err != nil {
-		panic(fmt.Errorf("failed to parse CA cert (this should not happen): %v", err))
+		return nil, fmt.Errorf("failed to parse CA cert (this should not happen): %v", err)
 	}
 
-	return cert
+	return cert, nil
 }
 
 func convertToPEM(pemBlockType string, derBytes []byte) []byte {
@@ -227,6 +398,9 @@ func convertToPEM(pemBlockType string, derBytes []byte) []byte {
 
 func convertFromPEM(pemBlockType string, data []byte) ([]byte, error) {
 	p, rest := pem.Decode(data)
+	if p == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
 	if len(rest) > 0 {
 		return nil, fmt.Errorf("unparsed bytes")
 	}
@@ -235,3 +409,119 @@ func convertFromPEM(pemBlockType string, data []byte) ([]byte, error) {
 	}
 	return p.Bytes, nil
 }
+
+func (profile SigningProfile) String() string {
+	switch profile {
+	case Server:
+		return "server"
+	case Peer:
+		return "peer"
+	case Client:
+		return "client"
+	default:
+		return "none"
+	}
+}
+
+// GetSigningProfile returns the profile a cert was signed with, based on its extended key usages
+func GetSigningProfile(cert *x509.Certificate) SigningProfile {
+	server, client := false, false
+	for _, usage := range cert.ExtKeyUsage {
+		switch usage {
+		case x509.ExtKeyUsageServerAuth:
+			server = true
+		case x509.ExtKeyUsageClientAuth:
+			client = true
+		}
+	}
+
+	switch {
+	case server && client:
+		return Peer
+	case server:
+		return Server
+	case client:
+		return Client
+	default:
+		return None
+	}
+}
+
+// DescribePublicKey returns the algorithm and size of a public key, e.g. "RSA 2048"
+func DescribePublicKey(pub interface{}) string {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("ECDSA %s", k.Curve.Params().Name)
+	default:
+		return "unknown"
+	}
+}
+
+// ParseCertsPEM parses all certs of PEM data, such as a CA chain
+func ParseCertsPEM(data []byte) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	for {
+		var p *pem.Block
+		p, data = pem.Decode(data)
+		if p == nil {
+			break
+		}
+		if p.Type != certPEMType {
+			return nil, fmt.Errorf("Invalid PEM data: invalid PEM block type %s", p.Type)
+		}
+		cert, err := x509.ParseCertificate(p.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(strings.TrimSpace(string(data))) > 0 {
+		return nil, fmt.Errorf("Invalid PEM data: unparsed bytes")
+	}
+	return certs, nil
+}
+
+// VerifyCAChain verifies a CA chains to a self signed root, through the intermediates of
+// the chain. A CA without a chain must be a root itself.
+func VerifyCAChain(ca *x509.Certificate, chain []*x509.Certificate, now time.Time) error {
+	roots := x509.NewCertPool()
+	intermediates := x509.NewCertPool()
+	for _, cert := range append([]*x509.Certificate{ca}, chain...) {
+		if cert.CheckSignatureFrom(cert) == nil {
+			roots.AddCert(cert)
+		} else if cert != ca {
+			intermediates.AddCert(cert)
+		}
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	if _, err := ca.Verify(opts); err != nil {
+		return fmt.Errorf("CA does not chain to a root: %v", err)
+	}
+	return nil
+}
+
+// KeyMatchesCert returns true if the key is the private key of the cert
+func (certCombo *CertCombo) KeyMatchesCert() bool {
+	if certCombo.Key == nil {
+		return false
+	}
+
+	switch pub := certCombo.Cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		key, ok := certCombo.Key.Public().(*rsa.PublicKey)
+		return ok && pub.N.Cmp(key.N) == 0 && pub.E == key.E
+	case *ecdsa.PublicKey:
+		key, ok := certCombo.Key.Public().(*ecdsa.PublicKey)
+		return ok && pub.Curve == key.Curve && pub.X.Cmp(key.X) == 0 && pub.Y.Cmp(key.Y) == 0
+	default:
+		return false
+	}
+}
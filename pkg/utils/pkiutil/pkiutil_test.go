@@ -0,0 +1,150 @@
+package pkiutil
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+)
+
+// makeTestCA returns a new CA and the PEM data of its cert and key
+func makeTestCA(t *testing.T, algorithm KeyAlgorithm) (*CertCombo, []byte, []byte) {
+	ca, err := InitCA(CSRParams{
+		Subject:      pkix.Name{CommonName: "test-ca"},
+		ValidFor:     time.Hour,
+		KeyAlgorithm: algorithm,
+	})
+	if err != nil {
+		t.Fatalf("InitCA failed: %v", err)
+	}
+	keyPEM, err := ca.ExtractKeyData()
+	if err != nil {
+		t.Fatalf("ExtractKeyData failed: %v", err)
+	}
+	return ca, ca.ExtractCertData(), keyPEM
+}
+
+// truncate cuts PEM data in the middle of its base64 body
+func truncate(data []byte) []byte {
+	return data[:len(data)/2]
+}
+
+// corruptBase64 replaces the first line of the base64 body of PEM data with invalid characters
+func corruptBase64(data []byte) []byte {
+	lines := strings.Split(string(data), "\n")
+	lines[1] = strings.Repeat("!", len(lines[1]))
+	return []byte(strings.Join(lines, "\n"))
+}
+
+func retype(data []byte, pemType string) []byte {
+	p, _ := pem.Decode(data)
+	return pem.EncodeToMemory(&pem.Block{Type: pemType, Bytes: p.Bytes})
+}
+
+func TestSetCertPEMData(t *testing.T) {
+	ca, certPEM, keyPEM := makeTestCA(t, RSA)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"valid", certPEM, ""},
+		{"empty", []byte{}, "no PEM block found"},
+		{"truncated", truncate(certPEM), "no PEM block found"},
+		{"bad base64", corruptBase64(certPEM), "no PEM block found"},
+		{"wrong block type", keyPEM, "invalid PEM block type"},
+		{"trailing bytes", append(append([]byte{}, certPEM...), "garbage"...), "unparsed bytes"},
+		{"two certs", append(append([]byte{}, certPEM...), certPEM...), "unparsed bytes"},
+		{"not a cert", retype(keyPEM, certPEMType), "Failed to parse certificate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certCombo := &CertCombo{}
+			err := certCombo.SetCertPEMData(tt.data)
+			assertError(t, err, tt.wantErr)
+			if err == nil && !bytes.Equal(certCombo.Cert.Raw, ca.Cert.Raw) {
+				t.Errorf("SetCertPEMData parsed a different cert")
+			}
+		})
+	}
+}
+
+func TestSetKeyPEMData(t *testing.T) {
+	_, certPEM, rsaKeyPEM := makeTestCA(t, RSA)
+	_, _, ecKeyPEM := makeTestCA(t, ECDSA)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"rsa", rsaKeyPEM, ""},
+		{"ecdsa", ecKeyPEM, ""},
+		{"empty", []byte{}, "no PEM block found"},
+		{"truncated", truncate(rsaKeyPEM), "no PEM block found"},
+		{"bad base64", corruptBase64(ecKeyPEM), "no PEM block found"},
+		{"wrong block type", certPEM, "invalid PEM block type CERTIFICATE"},
+		{"trailing bytes", append(append([]byte{}, rsaKeyPEM...), "garbage"...), "unparsed bytes"},
+		{"rsa labelled ec", retype(rsaKeyPEM, ecKeyPEMType), "Failed to parse key"},
+		{"ec labelled pkcs8", retype(ecKeyPEM, pkcs8KeyPEMType), "Failed to parse key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certCombo := &CertCombo{}
+			err := certCombo.SetKeyPEMData(tt.data)
+			assertError(t, err, tt.wantErr)
+			if err == nil && certCombo.Key == nil {
+				t.Errorf("SetKeyPEMData set no key")
+			}
+		})
+	}
+}
+
+func TestParseCertsPEM(t *testing.T) {
+	_, certPEM, keyPEM := makeTestCA(t, RSA)
+	_, otherCertPEM, _ := makeTestCA(t, ECDSA)
+	chainPEM := append(append([]byte{}, certPEM...), otherCertPEM...)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		wantCerts int
+		wantErr   string
+	}{
+		{"empty", []byte{}, 0, ""},
+		{"whitespace", []byte("\n  \n"), 0, ""},
+		{"one cert", certPEM, 1, ""},
+		{"chain", chainPEM, 2, ""},
+		{"truncated", append(append([]byte{}, certPEM...), truncate(otherCertPEM)...), 0, "unparsed bytes"},
+		{"bad base64", corruptBase64(certPEM), 0, "unparsed bytes"},
+		{"wrong block type", append(append([]byte{}, certPEM...), keyPEM...), 0, "invalid PEM block type"},
+		{"trailing bytes", append(append([]byte{}, chainPEM...), "garbage"...), 0, "unparsed bytes"},
+		{"not a cert", retype(keyPEM, certPEMType), 0, "Failed to parse certificate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certs, err := ParseCertsPEM(tt.data)
+			assertError(t, err, tt.wantErr)
+			if err == nil && len(certs) != tt.wantCerts {
+				t.Errorf("ParseCertsPEM returned %d certs, expected %d", len(certs), tt.wantCerts)
+			}
+		})
+	}
+}
+
+func assertError(t *testing.T, err error, wantErr string) {
+	switch {
+	case wantErr == "" && err != nil:
+		t.Errorf("unexpected error: %v", err)
+	case wantErr != "" && err == nil:
+		t.Errorf("expected an error containing %q", wantErr)
+	case wantErr != "" && !strings.Contains(err.Error(), wantErr):
+		t.Errorf("error %q does not contain %q", err, wantErr)
+	}
+}
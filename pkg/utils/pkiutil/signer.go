@@ -0,0 +1,111 @@
+package pkiutil
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// signer url schemes
+const (
+	LocalSignerScheme    = "local"
+	VaultPKISignerScheme = "vault-pki"
+)
+
+// Signer signs the CSRs of new certs with a CA
+type Signer interface {
+	// Sign returns the cert of the CSR, with the profile and validity of the params
+	Sign(params CSRParams, csr *x509.CertificateRequest) (*x509.Certificate, error)
+	// GetCA returns the cert of the CA and the certs that issued it, up to the root
+	GetCA() (*x509.Certificate, []*x509.Certificate, error)
+}
+
+// LocalSigner signs certs with a CA cert and key held by kaptain
+type LocalSigner struct {
+	ca *CertCombo
+}
+
+// NewLocalSigner returns a signer of the CA cert and key
+func NewLocalSigner(ca *CertCombo) Signer {
+	return &LocalSigner{ca: ca}
+}
+
+func (signer *LocalSigner) Sign(params CSRParams, csr *x509.CertificateRequest) (*x509.Certificate, error) {
+	if signer.ca == nil || signer.ca.Cert == nil || signer.ca.Key == nil {
+		return nil, fmt.Errorf("failed to sign certificate: CA cert and key are required")
+	}
+	return signCert(params, csr, signer.ca)
+}
+
+func (signer *LocalSigner) GetCA() (*x509.Certificate, []*x509.Certificate, error) {
+	if signer.ca == nil || signer.ca.Cert == nil {
+		return nil, nil, fmt.Errorf("CA cert is required")
+	}
+	return signer.ca.Cert, nil, nil
+}
+
+// CreateSignerFromUrl creates the signer of the url, the scheme selects the backend. An
+// empty url is the local signer of the CA, other backends hold the CA key themselves.
+func CreateSignerFromUrl(signerUrl string, ca *CertCombo) (Signer, error) {
+	parsedURL, err := parseSignerUrl(signerUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parsedURL.Scheme {
+	case LocalSignerScheme:
+		return NewLocalSigner(ca), nil
+	case VaultPKISignerScheme:
+		mount, role := splitVaultPKIPath(parsedURL)
+		verbatim := parsedURL.Query().Get("verbatim") == "true"
+		return createVaultPKISigner(mount, role, verbatim)
+	default:
+		return nil, fmt.Errorf("failed to create signer '%s': unknown scheme '%s'", signerUrl, parsedURL.Scheme)
+	}
+}
+
+// ValidateSignerUrl returns an error if no signer can be created from the url
+func ValidateSignerUrl(signerUrl string) error {
+	_, err := parseSignerUrl(signerUrl)
+	return err
+}
+
+// IsLocalSignerUrl returns true if the url is signed with a CA key held by kaptain
+func IsLocalSignerUrl(signerUrl string) bool {
+	parsedURL, err := parseSignerUrl(signerUrl)
+	return err == nil && parsedURL.Scheme == LocalSignerScheme
+}
+
+func parseSignerUrl(signerUrl string) (*url.URL, error) {
+	if signerUrl == "" {
+		return &url.URL{Scheme: LocalSignerScheme}, nil
+	}
+
+	parsedURL, err := url.Parse(signerUrl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signer url '%s': %v", signerUrl, err)
+	}
+
+	switch parsedURL.Scheme {
+	case LocalSignerScheme:
+	case VaultPKISignerScheme:
+		if mount, role := splitVaultPKIPath(parsedURL); mount == "" || role == "" {
+			return nil, fmt.Errorf("invalid signer url '%s' (must be %s://<mount>/<role>)", signerUrl, VaultPKISignerScheme)
+		}
+	default:
+		return nil, fmt.Errorf("invalid signer url '%s': unknown scheme '%s' (must be one of %s, %s)", signerUrl, parsedURL.Scheme, LocalSignerScheme, VaultPKISignerScheme)
+	}
+	return parsedURL, nil
+}
+
+// splitVaultPKIPath splits the path of a vault-pki url into the mount of the PKI secrets
+// engine and the role, which is the last element
+func splitVaultPKIPath(parsedURL *url.URL) (string, string) {
+	fullPath := strings.Trim(parsedURL.Host+parsedURL.Path, "/")
+	i := strings.LastIndex(fullPath, "/")
+	if i < 0 {
+		return fullPath, ""
+	}
+	return fullPath[:i], fullPath[i+1:]
+}
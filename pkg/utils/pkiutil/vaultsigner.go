@@ -0,0 +1,191 @@
+package pkiutil
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	log "github.com/sirupsen/logrus"
+)
+
+const csrPEMType = "CERTIFICATE REQUEST"
+
+// VaultPKISigner signs certs with a role of the PKI secrets engine of Vault, the CA key
+// never leaves Vault. The Vault address and token are read from VAULT_ADDR and VAULT_TOKEN.
+type VaultPKISigner struct {
+	vaultClient *vaultapi.Client
+	mount       string
+	role        string
+	verbatim    bool // sign with sign-verbatim, which keeps the subject of the CSR
+}
+
+func createVaultPKISigner(mount string, role string, verbatim bool) (Signer, error) {
+	vaultConfig := *vaultapi.DefaultConfig()
+	vaultConfig.ReadEnvironment()
+
+	client, err := vaultapi.NewClient(&vaultConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault client: %v", err)
+	}
+
+	return &VaultPKISigner{
+		vaultClient: client,
+		mount:       mount,
+		role:        role,
+		verbatim:    verbatim,
+	}, nil
+}
+
+func (signer *VaultPKISigner) Sign(params CSRParams, csr *x509.CertificateRequest) (*x509.Certificate, error) {
+	endpoint := "sign"
+	if signer.verbatim {
+		endpoint = "sign-verbatim"
+	}
+	reqPath := fmt.Sprintf("%s/%s/%s", signer.mount, endpoint, signer.role)
+
+	reqData := map[string]interface{}{
+		"csr":                  string(convertToPEM(csrPEMType, csr.Raw)),
+		"common_name":          csr.Subject.CommonName,
+		"ttl":                  fmt.Sprintf("%ds", int64(params.ValidFor/time.Second)),
+		"format":               "pem",
+		"exclude_cn_from_sans": true,
+	}
+	if signer.verbatim {
+		reqData["ext_key_usage"] = getExtKeyUsageNames(params.Profile)
+	}
+
+	log.WithField("path", reqPath).Debugf("VAULT_SIGNER: Sign CSR of %s", csr.Subject)
+	secret, err := signer.vaultClient.Logical().Write(reqPath, reqData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign certificate with %s: %v", signer, err)
+	}
+	cert, err := readVaultCert(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign certificate with %s: %v", signer, err)
+	}
+
+	if err := checkSignedCert(params, csr, cert); err != nil {
+		return nil, fmt.Errorf("certificate signed by %s is invalid: %v", signer, err)
+	}
+	return cert, nil
+}
+
+func (signer *VaultPKISigner) GetCA() (*x509.Certificate, []*x509.Certificate, error) {
+	caPath := fmt.Sprintf("%s/cert/ca", signer.mount)
+	secret, err := signer.vaultClient.Logical().Read(caPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CA of %s: %v", signer, err)
+	}
+	ca, err := readVaultCert(secret)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CA of %s: %v", signer, err)
+	}
+
+	// the chain of a root CA is empty, the chain of an intermediate may include itself
+	chainPath := fmt.Sprintf("%s/cert/ca_chain", signer.mount)
+	secret, err = signer.vaultClient.Logical().Read(chainPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CA chain of %s: %v", signer, err)
+	}
+	chain := []*x509.Certificate{}
+	if secret != nil && secret.Data["certificate"] != nil {
+		certs, err := ParseCertsPEM([]byte(fmt.Sprintf("%v", secret.Data["certificate"])))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read CA chain of %s: %v", signer, err)
+		}
+		for _, c := range certs {
+			if !bytes.Equal(c.Raw, ca.Raw) {
+				chain = append(chain, c)
+			}
+		}
+	}
+
+	return ca, chain, nil
+}
+
+func (signer *VaultPKISigner) String() string {
+	url := fmt.Sprintf("%s://%s/%s", VaultPKISignerScheme, signer.mount, signer.role)
+	if signer.verbatim {
+		url += "?verbatim=true"
+	}
+	return url
+}
+
+// readVaultCert parses the PEM cert of the certificate field of a Vault response
+func readVaultCert(secret *vaultapi.Secret) (*x509.Certificate, error) {
+	if secret == nil || secret.Data["certificate"] == nil {
+		return nil, fmt.Errorf("no certificate returned")
+	}
+	certPEM, ok := secret.Data["certificate"].(string)
+	if !ok {
+		return nil, fmt.Errorf("certificate is malformed")
+	}
+	certs, err := ParseCertsPEM([]byte(certPEM))
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate returned")
+	}
+	return certs[0], nil
+}
+
+// checkSignedCert verifies a cert signed by a role whose settings may override the CSR has
+// the subject, SANs and key usages kubernetes needs
+func checkSignedCert(params CSRParams, csr *x509.CertificateRequest, cert *x509.Certificate) error {
+	if cert.Subject.CommonName != csr.Subject.CommonName {
+		return fmt.Errorf("common name is %q instead of %q, the role must keep the common name of the CSR", cert.Subject.CommonName, csr.Subject.CommonName)
+	}
+	if certNames, csrNames := sortedNames(cert.DNSNames), sortedNames(csr.DNSNames); !reflect.DeepEqual(certNames, csrNames) {
+		return fmt.Errorf("DNS names are %v instead of %v, the role must use the SANs of the CSR (use_csr_sans)", certNames, csrNames)
+	}
+	if certIPs, csrIPs := sortedIPs(cert.IPAddresses), sortedIPs(csr.IPAddresses); !reflect.DeepEqual(certIPs, csrIPs) {
+		return fmt.Errorf("IP addresses are %v instead of %v, the role must use the SANs of the CSR (use_csr_sans)", certIPs, csrIPs)
+	}
+	if strings.Join(cert.Subject.Organization, ",") != strings.Join(csr.Subject.Organization, ",") {
+		return fmt.Errorf("organisations are %v instead of %v, the role must keep the subject of the CSR (use ?verbatim=true to sign with sign-verbatim)", cert.Subject.Organization, csr.Subject.Organization)
+	}
+
+	profile := GetSigningProfile(cert)
+	switch {
+	case params.Profile == None:
+	case profile == params.Profile, profile == Peer:
+	default:
+		return fmt.Errorf("signing profile is %s instead of %s, check the server_flag and client_flag of the role", profile, params.Profile)
+	}
+	return nil
+}
+
+func sortedNames(names []string) []string {
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+func sortedIPs(ips []net.IP) []string {
+	names := make([]string, len(ips))
+	for i, ip := range ips {
+		names[i] = ip.String()
+	}
+	return sortedNames(names)
+}
+
+// getExtKeyUsageNames returns the Vault names of the extended key usages of a profile
+func getExtKeyUsageNames(profile SigningProfile) []string {
+	switch profile {
+	case Server:
+		return []string{"ServerAuth"}
+	case Client:
+		return []string{"ClientAuth"}
+	case Peer:
+		return []string{"ServerAuth", "ClientAuth"}
+	default:
+		return []string{}
+	}
+}
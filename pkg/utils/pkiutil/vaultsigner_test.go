@@ -0,0 +1,328 @@
+package pkiutil
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeVaultPKI is a fake of the PKI secrets engine of Vault mounted at pki, its roles sign
+// with the CA and the profile of the role
+type fakeVaultPKI struct {
+	ca       *CertCombo
+	caPEM    string // returned by cert/ca, the PEM of the CA if empty
+	chainPEM string // returned by cert/ca_chain
+
+	profile          SigningProfile // of the server_flag and client_flag of the role
+	dropOrganisation bool           // the role does not keep the subject of the CSR
+	dropSANs         bool           // the role does not use the SANs of the CSR
+	commonName       string         // the common name of the role, the one of the CSR if empty
+
+	paths []string
+	body  map[string]interface{} // of the last sign request
+}
+
+func (f *fakeVaultPKI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.paths = append(f.paths, r.URL.Path)
+
+	if r.Header.Get("X-Vault-Token") != "test-token" {
+		writeVaultError(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/v1/pki/cert/ca":
+		caPEM := f.caPEM
+		if caPEM == "" {
+			caPEM = string(f.ca.ExtractCertData())
+		}
+		writeVaultData(w, map[string]interface{}{"certificate": caPEM})
+	case r.Method == "GET" && r.URL.Path == "/v1/pki/cert/ca_chain":
+		writeVaultData(w, map[string]interface{}{"certificate": f.chainPEM})
+	case strings.HasPrefix(r.URL.Path, "/v1/pki/sign/") || strings.HasPrefix(r.URL.Path, "/v1/pki/sign-verbatim/"):
+		f.sign(w, r)
+	default:
+		writeVaultError(w, http.StatusNotFound, "unsupported path")
+	}
+}
+
+func (f *fakeVaultPKI) sign(w http.ResponseWriter, r *http.Request) {
+	f.body = map[string]interface{}{}
+	if err := json.NewDecoder(r.Body).Decode(&f.body); err != nil {
+		writeVaultError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	p, _ := pem.Decode([]byte(f.body["csr"].(string)))
+	if p == nil || p.Type != csrPEMType {
+		writeVaultError(w, http.StatusBadRequest, "invalid csr")
+		return
+	}
+	csr, err := x509.ParseCertificateRequest(p.Bytes)
+	if err != nil {
+		writeVaultError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if f.dropOrganisation {
+		csr.Subject.Organization = nil
+	}
+	if f.dropSANs {
+		csr.DNSNames, csr.IPAddresses = nil, nil
+	}
+	if f.commonName != "" {
+		csr.Subject.CommonName = f.commonName
+	}
+
+	// sign-verbatim takes the key usages of the request instead of the role
+	profile := f.profile
+	if usages, exists := f.body["ext_key_usage"]; exists {
+		profile = getProfileOfExtKeyUsageNames(usages)
+	}
+
+	cert, err := signCert(CSRParams{ValidFor: time.Hour, Profile: profile}, csr, f.ca)
+	if err != nil {
+		writeVaultError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeVaultData(w, map[string]interface{}{
+		"certificate": string(convertToPEM(certPEMType, cert.Raw)),
+		"issuing_ca":  string(f.ca.ExtractCertData()),
+	})
+}
+
+func getProfileOfExtKeyUsageNames(usages interface{}) SigningProfile {
+	for _, profile := range []SigningProfile{Server, Client, Peer, None} {
+		names := []interface{}{}
+		for _, name := range getExtKeyUsageNames(profile) {
+			names = append(names, name)
+		}
+		if reflect.DeepEqual(usages, names) {
+			return profile
+		}
+	}
+	return None
+}
+
+func writeVaultData(w http.ResponseWriter, data map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func writeVaultError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{message}})
+}
+
+// withFakeVault creates the signer of the url against a fake of the PKI secrets engine
+func withFakeVault(t *testing.T, f *fakeVaultPKI, signerUrl string, test func(signer Signer)) {
+	if f.ca == nil {
+		f.ca, _, _ = makeTestCA(t, RSA)
+	}
+	server := httptest.NewServer(f)
+	defer server.Close()
+
+	for name, value := range map[string]string{"VAULT_ADDR": server.URL, "VAULT_TOKEN": "test-token"} {
+		old, exists := os.LookupEnv(name)
+		os.Setenv(name, value)
+		if exists {
+			defer os.Setenv(name, old)
+		} else {
+			defer os.Unsetenv(name)
+		}
+	}
+
+	signer, err := CreateSignerFromUrl(signerUrl, nil)
+	if err != nil {
+		t.Fatalf("CreateSignerFromUrl(%q) failed: %v", signerUrl, err)
+	}
+	test(signer)
+}
+
+var testVaultParams = CSRParams{
+	Subject:  pkix.Name{CommonName: "admin", Organization: []string{"system:masters"}},
+	ValidFor: time.Hour,
+	Profile:  Client,
+}
+
+var testVaultServerParams = CSRParams{
+	Subject:  pkix.Name{CommonName: "kubernetes"},
+	AltNames: []string{"kubernetes.default", "master-0.example.com", "10.0.0.1", "127.0.0.1"},
+	ValidFor: time.Hour,
+	Profile:  Server,
+}
+
+func TestVaultPKISignerSign(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		fake     fakeVaultPKI
+		params   CSRParams
+		wantPath string
+		wantErr  string
+	}{
+		{
+			name:     "sign",
+			url:      "vault-pki://pki/kube",
+			fake:     fakeVaultPKI{profile: Client},
+			params:   testVaultParams,
+			wantPath: "/v1/pki/sign/kube",
+		},
+		{
+			name:     "sign verbatim",
+			url:      "vault-pki://pki/kube?verbatim=true",
+			fake:     fakeVaultPKI{profile: None},
+			params:   testVaultParams,
+			wantPath: "/v1/pki/sign-verbatim/kube",
+		},
+		{
+			name:     "peer cert for a client profile",
+			url:      "vault-pki://pki/kube",
+			fake:     fakeVaultPKI{profile: Peer},
+			params:   testVaultParams,
+			wantPath: "/v1/pki/sign/kube",
+		},
+		{
+			name:     "organisation mismatch",
+			url:      "vault-pki://pki/kube",
+			fake:     fakeVaultPKI{profile: Client, dropOrganisation: true},
+			params:   testVaultParams,
+			wantPath: "/v1/pki/sign/kube",
+			wantErr:  "organisations are [] instead of [system:masters]",
+		},
+		{
+			name:     "server cert with SANs",
+			url:      "vault-pki://pki/kube",
+			fake:     fakeVaultPKI{profile: Server},
+			params:   testVaultServerParams,
+			wantPath: "/v1/pki/sign/kube",
+		},
+		{
+			name:     "SANs dropped",
+			url:      "vault-pki://pki/kube",
+			fake:     fakeVaultPKI{profile: Server, dropSANs: true},
+			params:   testVaultServerParams,
+			wantPath: "/v1/pki/sign/kube",
+			wantErr:  "DNS names are [] instead of [kubernetes.default master-0.example.com]",
+		},
+		{
+			name:     "common name overridden",
+			url:      "vault-pki://pki/kube",
+			fake:     fakeVaultPKI{profile: Client, commonName: "vault-role"},
+			params:   testVaultParams,
+			wantPath: "/v1/pki/sign/kube",
+			wantErr:  `common name is "vault-role" instead of "admin"`,
+		},
+		{
+			name:     "profile mismatch",
+			url:      "vault-pki://pki/kube",
+			fake:     fakeVaultPKI{profile: Server},
+			params:   testVaultParams,
+			wantPath: "/v1/pki/sign/kube",
+			wantErr:  "signing profile is server instead of client",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tt.fake
+			withFakeVault(t, &f, tt.url, func(signer Signer) {
+				certCombo, err := MakeCert(tt.params, signer)
+				assertError(t, err, tt.wantErr)
+
+				if len(f.paths) != 1 || f.paths[0] != tt.wantPath {
+					t.Errorf("requested %v, expected [%s]", f.paths, tt.wantPath)
+				}
+				if f.body["common_name"] != tt.params.Subject.CommonName {
+					t.Errorf("common_name is %v, expected %s", f.body["common_name"], tt.params.Subject.CommonName)
+				}
+				_, hasUsages := f.body["ext_key_usage"]
+				if verbatim := strings.Contains(tt.url, "verbatim=true"); hasUsages != verbatim {
+					t.Errorf("ext_key_usage sent is %v, expected %v", hasUsages, verbatim)
+				}
+				if err != nil {
+					return
+				}
+
+				if err := certCombo.Cert.CheckSignatureFrom(f.ca.Cert); err != nil {
+					t.Errorf("cert is not signed by the CA: %v", err)
+				}
+				if certCombo.Cert.Subject.CommonName != tt.params.Subject.CommonName {
+					t.Errorf("cert CN is %s, expected %s", certCombo.Cert.Subject.CommonName, tt.params.Subject.CommonName)
+				}
+			})
+		})
+	}
+}
+
+func TestVaultPKISignerGetCA(t *testing.T) {
+	root, rootPEM, _ := makeTestCA(t, RSA)
+	intermediate, err := MakeCert(CSRParams{Subject: pkix.Name{CommonName: "intermediate"}, ValidFor: time.Hour}, NewLocalSigner(root))
+	if err != nil {
+		t.Fatalf("failed to make intermediate: %v", err)
+	}
+	intermediatePEM := intermediate.ExtractCertData()
+
+	tests := []struct {
+		name      string
+		fake      fakeVaultPKI
+		wantCA    *x509.Certificate
+		wantChain []*x509.Certificate
+		wantErr   string
+	}{
+		{
+			name:      "root with empty ca_chain",
+			fake:      fakeVaultPKI{ca: root},
+			wantCA:    root.Cert,
+			wantChain: []*x509.Certificate{},
+		},
+		{
+			name:      "intermediate with itself in ca_chain",
+			fake:      fakeVaultPKI{ca: intermediate, chainPEM: string(intermediatePEM) + string(rootPEM)},
+			wantCA:    intermediate.Cert,
+			wantChain: []*x509.Certificate{root.Cert},
+		},
+		{
+			name:    "malformed ca_chain",
+			fake:    fakeVaultPKI{ca: root, chainPEM: string(rootPEM) + "garbage"},
+			wantErr: "failed to read CA chain",
+		},
+		{
+			name:    "empty CA",
+			fake:    fakeVaultPKI{ca: root, caPEM: "\n"},
+			wantErr: "no certificate returned",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tt.fake
+			withFakeVault(t, &f, "vault-pki://pki/kube", func(signer Signer) {
+				ca, chain, err := signer.GetCA()
+				assertError(t, err, tt.wantErr)
+				if err != nil {
+					return
+				}
+
+				if !ca.Equal(tt.wantCA) {
+					t.Errorf("CA is %s, expected %s", ca.Subject, tt.wantCA.Subject)
+				}
+				if len(chain) != len(tt.wantChain) {
+					t.Fatalf("chain has %d certs, expected %d", len(chain), len(tt.wantChain))
+				}
+				for i := range chain {
+					if !chain[i].Equal(tt.wantChain[i]) {
+						t.Errorf("chain[%d] is %s, expected %s", i, chain[i].Subject, tt.wantChain[i].Subject)
+					}
+				}
+			})
+		})
+	}
+}
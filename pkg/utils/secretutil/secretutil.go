@@ -1,13 +1,39 @@
 package secretutil
 
-import "math/rand"
+import (
+	"crypto/rand"
+	"fmt"
+)
 
 const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+const lowerLetterBytes = "abcdefghijklmnopqrstuvwxyz0123456789"
 
-func MakeRandomToken(tokenLength int) string {
-	b := make([]byte, tokenLength)
-	for i := range b {
-		b[i] = letterBytes[rand.Intn(len(letterBytes))]
+// MakeRandomToken returns a token of random letters and digits read from crypto/rand
+func MakeRandomToken(tokenLength int) (string, error) {
+	return makeRandomString(tokenLength, letterBytes)
+}
+
+// MakeRandomLowerToken returns a token of random lower case letters and digits read from
+// crypto/rand, as required for the ID and secret of Kubernetes bootstrap tokens
+func MakeRandomLowerToken(tokenLength int) (string, error) {
+	return makeRandomString(tokenLength, lowerLetterBytes)
+}
+
+func makeRandomString(tokenLength int, letterBytes string) (string, error) {
+	// random bytes at or above this value are discarded, so every letter is equally likely
+	maxUnbiasedByte := 256 - 256%len(letterBytes)
+
+	b := make([]byte, 0, tokenLength)
+	buf := make([]byte, tokenLength)
+	for len(b) < tokenLength {
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("failed to generate random token: %v", err)
+		}
+		for _, r := range buf {
+			if int(r) < maxUnbiasedByte && len(b) < tokenLength {
+				b = append(b, letterBytes[int(r)%len(letterBytes)])
+			}
+		}
 	}
-	return string(b)
+	return string(b), nil
 }
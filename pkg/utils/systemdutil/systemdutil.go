@@ -0,0 +1,55 @@
+package systemdutil
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// states sent to systemd, see sd_notify(3)
+const (
+	NotifyReady    = "READY=1"
+	NotifyStopping = "STOPPING=1"
+	NotifyWatchdog = "WATCHDOG=1"
+)
+
+// Notify sends a state to systemd, it returns false if the process is not run by a
+// systemd service of Type=notify
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+
+	// a socket name starting with '@' is in the abstract namespace, which net handles
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to systemd: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("failed to notify systemd: %v", err)
+	}
+	return true, nil
+}
+
+// NotifyStatus sends a status line to systemd, shown by systemctl status
+func NotifyStatus(status string) (bool, error) {
+	return Notify(fmt.Sprintf("STATUS=%s", status))
+}
+
+// WatchdogInterval returns the interval after which systemd restarts the service unless
+// it is notified, or false if the watchdog is not enabled for the process
+func WatchdogInterval() (time.Duration, bool) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, false
+	}
+	return time.Duration(usec) * time.Microsecond, true
+}
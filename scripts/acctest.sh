@@ -5,29 +5,39 @@ set -euxo pipefail
 CLUSTER_NAME=dev.acctest.aws
 TESTDIR=/tmp/kaptain_test
 
+# use a local store so the test does not need cloud credentials
+export KAPTAIN_STORE="${KAPTAIN_STORE:-file:///tmp/kaptain_test_store}"
+
 rm -rf /tmp/kaptain_test*
 
 kaptain create -n $CLUSTER_NAME
 
-mkdir -p $TESTDIR/etcd/etc/pki/tls/certs
-mkdir -p $TESTDIR/etcd/etc/pki/tls/private
-mkdir -p $TESTDIR/master/etc/{sysconfig,docker}
-mkdir -p $TESTDIR/master/etc/kubernetes/manifests
-mkdir -p $TESTDIR/master/var/lib/{kubelet,kube-proxy,kubernetes}
-mkdir -p $TESTDIR/worker/etc/{sysconfig,docker}
-mkdir -p $TESTDIR/worker/var/lib/{kubelet,kube-proxy}
+# sailor exits with 2 when it changed files, and with 0 when run again
+provision() {
+  local rc=0
+  sailor provision --name $CLUSTER_NAME "$@" || rc=$?
+  [ $rc -eq 2 ]
+  sailor provision --name $CLUSTER_NAME "$@"
+}
 
-sailor provision --name $CLUSTER_NAME --prefix="$TESTDIR/etcd" --role=etcd
-sailor provision --name $CLUSTER_NAME --prefix="$TESTDIR/master" --role=master
-sailor provision --name $CLUSTER_NAME --prefix="$TESTDIR/worker" --role=worker
+provision --prefix="$TESTDIR/etcd" --role=etcd --hostname=etcd-k8s-0
+provision --prefix="$TESTDIR/master" --role=master
+provision --prefix="$TESTDIR/worker" --role=worker
 
 kaptain delete -n $CLUSTER_NAME
 
 # compare generated files
 cat << 'EOF' > /tmp/kaptain_test_expected
+/tmp/kaptain_test/etcd/etc/etcd/etcd-k8s-0.conf.yaml
+/tmp/kaptain_test/etcd/etc/etcd/etcd-k8s-1.conf.yaml
+/tmp/kaptain_test/etcd/etc/etcd/etcd-k8s-2.conf.yaml
 /tmp/kaptain_test/etcd/etc/pki/tls/certs/etcd-ca.pem
+/tmp/kaptain_test/etcd/etc/pki/tls/certs/etcd-peer.pem
 /tmp/kaptain_test/etcd/etc/pki/tls/certs/etcd-server.pem
+/tmp/kaptain_test/etcd/etc/pki/tls/private/etcd-peer-key.pem
 /tmp/kaptain_test/etcd/etc/pki/tls/private/etcd-server-key.pem
+/tmp/kaptain_test/etcd/var/lib/sailor/sailor.lock
+/tmp/kaptain_test/etcd/var/lib/sailor/state.json
 /tmp/kaptain_test/master/etc/docker/daemon.json
 /tmp/kaptain_test/master/etc/kubernetes/manifests/kube-apiserver.yaml
 /tmp/kaptain_test/master/etc/kubernetes/manifests/kube-controller-manager.yaml
@@ -39,15 +49,23 @@ cat << 'EOF' > /tmp/kaptain_test_expected
 /tmp/kaptain_test/master/var/lib/kube-proxy/kubeconfig
 /tmp/kaptain_test/master/var/lib/kubelet/kubeconfig
 /tmp/kaptain_test/master/var/lib/kubernetes/ca-key.pem
+/tmp/kaptain_test/master/var/lib/kubernetes/ca-signing.pem
 /tmp/kaptain_test/master/var/lib/kubernetes/ca.pem
 /tmp/kaptain_test/master/var/lib/kubernetes/etcd-ca.pem
 /tmp/kaptain_test/master/var/lib/kubernetes/etcd-client-key.pem
 /tmp/kaptain_test/master/var/lib/kubernetes/etcd-client.pem
+/tmp/kaptain_test/master/var/lib/kubernetes/front-proxy-ca.pem
+/tmp/kaptain_test/master/var/lib/kubernetes/front-proxy-client-key.pem
+/tmp/kaptain_test/master/var/lib/kubernetes/front-proxy-client.pem
 /tmp/kaptain_test/master/var/lib/kubernetes/kube-controller-manager.kubeconfig
 /tmp/kaptain_test/master/var/lib/kubernetes/kube-scheduler.kubeconfig
 /tmp/kaptain_test/master/var/lib/kubernetes/kubernetes-key.pem
 /tmp/kaptain_test/master/var/lib/kubernetes/kubernetes.pem
+/tmp/kaptain_test/master/var/lib/kubernetes/service-account-key.pem
+/tmp/kaptain_test/master/var/lib/kubernetes/service-account.pem
 /tmp/kaptain_test/master/var/lib/kubernetes/token.csv
+/tmp/kaptain_test/master/var/lib/sailor/sailor.lock
+/tmp/kaptain_test/master/var/lib/sailor/state.json
 /tmp/kaptain_test/worker/etc/docker/daemon.json
 /tmp/kaptain_test/worker/etc/sysconfig/docker
 /tmp/kaptain_test/worker/etc/sysconfig/kube-proxy-kaptain
@@ -55,8 +73,14 @@ cat << 'EOF' > /tmp/kaptain_test_expected
 /tmp/kaptain_test/worker/etc/sysconfig/kubelet-kaptain-extra
 /tmp/kaptain_test/worker/var/lib/kube-proxy/kubeconfig
 /tmp/kaptain_test/worker/var/lib/kubelet/bootstrap.kubeconfig
+/tmp/kaptain_test/worker/var/lib/sailor/sailor.lock
+/tmp/kaptain_test/worker/var/lib/sailor/state.json
 EOF
 
-find $TESTDIR -type f > /tmp/kaptain_test_actual
+find $TESTDIR -type f | LC_ALL=C sort > /tmp/kaptain_test_actual
 
 diff /tmp/kaptain_test_expected /tmp/kaptain_test_actual
+
+# keys, tokens and kubeconfigs are only readable by their owner
+find $TESTDIR -type f -perm /077 \( -name '*-key.pem' -o -name 'token.csv' -o -name '*kubeconfig' \) > /tmp/kaptain_test_readable
+diff /dev/null /tmp/kaptain_test_readable